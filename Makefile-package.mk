@@ -32,12 +32,14 @@ prep-pkg-env:
 	fi
 	@echo "=== Main === [ prep-pkg-env ]: preparing a clean packaging environment..."
 	@rm -rf $(SOURCE_DIR)
-	@mkdir -p $(SOURCE_DIR)/var/db/newrelic-infra/newrelic-integrations/bin $(SOURCE_DIR)/etc/newrelic-infra/integrations.d
+	@mkdir -p $(SOURCE_DIR)/var/db/newrelic-infra/newrelic-integrations/bin $(SOURCE_DIR)/var/db/newrelic-infra/newrelic-integrations/snmp-profiles $(SOURCE_DIR)/etc/newrelic-infra/integrations.d
 	@echo "=== Main === [ prep-pkg-env ]: adding built binaries and configuration and definition files..."
 	@cp $(BINS_DIR)/$(BINARY_NAME) $(SOURCE_DIR)/var/db/newrelic-infra/newrelic-integrations/bin
 	@chmod 755 $(SOURCE_DIR)/var/db/newrelic-infra/newrelic-integrations/bin/*
 	@cp ./*.yml $(SOURCE_DIR)/var/db/newrelic-infra/newrelic-integrations/
 	@chmod 644 $(SOURCE_DIR)/var/db/newrelic-infra/newrelic-integrations/*.yml
+	@cp ./profiles/*.yml $(SOURCE_DIR)/var/db/newrelic-infra/newrelic-integrations/snmp-profiles/
+	@chmod 644 $(SOURCE_DIR)/var/db/newrelic-infra/newrelic-integrations/snmp-profiles/*.yml
 	@cp ./*.sample $(SOURCE_DIR)/etc/newrelic-infra/integrations.d/
 	@chmod 644 $(SOURCE_DIR)/etc/newrelic-infra/integrations.d/*.sample
 
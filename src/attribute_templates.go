@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/template"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/log"
+)
+
+// attributeTemplate composes an attribute from the values of a metric set
+type attributeTemplate struct {
+	name     string
+	template *template.Template
+}
+
+// parseAttributeTemplates compiles the attribute templates of a metric set,
+// sorted by name so they are rendered in a stable order
+func parseAttributeTemplates(attributes map[string]string) ([]*attributeTemplate, error) {
+	var templates []*attributeTemplate
+	for name, text := range attributes {
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %v", name, err)
+		}
+		templates = append(templates, &attributeTemplate{name: name, template: tmpl})
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].name < templates[j].name })
+	return templates, nil
+}
+
+// setTemplateAttributes renders the attribute templates of a metric set from
+// the values collected in it. An attribute referring to a value that was not
+// collected is not reported
+func setTemplateAttributes(templates []*attributeTemplate, ms *metric.Set) {
+	for _, t := range templates {
+		var b bytes.Buffer
+		if err := t.template.Execute(&b, ms.Metrics); err != nil {
+			log.Debug("unable to render attribute %s: %v", t.name, err)
+			continue
+		}
+		if err := ms.SetMetric(t.name, b.String(), metric.ATTRIBUTE); err != nil {
+			log.Error(err.Error())
+		}
+	}
+}
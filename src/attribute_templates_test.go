@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+)
+
+func TestTemplateAttributes(t *testing.T) {
+	templates, err := parseAttributeTemplates(map[string]string{
+		"location": "{{.sysLocation}} / {{.sysName}}",
+		"contact":  "{{.sysContact}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := metric.NewSet("TestSample", nil)
+	ms.Metrics["sysLocation"] = "DC1"
+	ms.Metrics["sysName"] = "core-1"
+	setTemplateAttributes(templates, ms)
+	if ms.Metrics["location"] != "DC1 / core-1" {
+		t.Errorf("unexpected location %v", ms.Metrics["location"])
+	}
+	if _, ok := ms.Metrics["contact"]; ok {
+		t.Error("attribute of a missing value reported")
+	}
+	if _, err := parseAttributeTemplates(map[string]string{"broken": "{{.sysName"}); err == nil {
+		t.Error("expected error for an invalid template")
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/soniah/gosnmp"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// browseMaxVariables is the number of variables a walk of the browser lists
+const browseMaxVariables = 500
+
+// browseHelp lists the commands of the browser
+const browseHelp = `walk <oid>       walk the subtree of a numeric OID or MIB name
+list             list the variables walked, * marks the marked ones
+mark <n>...      mark variables by number, range such as 3-7, or OID of a subtree
+unmark <n>...    unmark variables the same way
+save [file]      append the marked variables to a collection file as metric sets
+quit             leave the browser
+`
+
+// errWalkLimit stops a walk once it read the maximum number of variables
+var errWalkLimit = errors.New("walk limit reached")
+
+// snmpwalkTypes are the names snmpwalk gives the PDU types, as walkMetric
+// reads them to propose metric types
+var snmpwalkTypes = map[gosnmp.Asn1BER]string{
+	gosnmp.Integer:          "INTEGER",
+	gosnmp.OctetString:      "STRING",
+	gosnmp.ObjectIdentifier: "OID",
+	gosnmp.IPAddress:        "IpAddress",
+	gosnmp.BitString:        "BITS",
+	gosnmp.Counter32:        "Counter32",
+	gosnmp.Counter64:        "Counter64",
+	gosnmp.Gauge32:          "Gauge32",
+	gosnmp.TimeTicks:        "Timeticks",
+}
+
+// collectItem matches the first device of a block collect list, with its indent
+var collectItem = regexp.MustCompile(`(?m)^collect:[ \t]*\n(?:[ \t]*(?:#.*)?\n)*([ \t]*)- `)
+
+// walkSubtree walks the subtree of a numeric OID or MIB name on the target,
+// with GETBULK requests or GETNEXT requests for SNMPv1. It stops after max
+// variables, unless max is 0, and reports whether the walk was truncated
+func walkSubtree(oid string, max int) ([]gosnmp.SnmpPDU, bool, error) {
+	rootOid, err := resolveOid(oid)
+	if err != nil {
+		return nil, false, err
+	}
+	var variables []gosnmp.SnmpPDU
+	walkFn := func(pdu gosnmp.SnmpPDU) error {
+		if max > 0 && len(variables) == max {
+			return errWalkLimit
+		}
+		variables = append(variables, pdu)
+		return nil
+	}
+	walk := theSNMP.BulkWalk
+	if theSNMP.Version == gosnmp.Version1 {
+		walk = theSNMP.Walk
+	}
+	err = walk(rootOid, walkFn)
+	if err == errWalkLimit {
+		return variables, true, nil
+	}
+	return variables, false, err
+}
+
+// browse connects to the target and runs the browser on the standard input
+// and output, from the subtree of root. Marked variables are saved to the
+// first collection file of the arguments unless another file is named
+func browse(root string) error {
+	if err := loadMibDirs(); err != nil {
+		return fmt.Errorf("failed to load MIB files: %v", err)
+	}
+	files, err := collectionFilePaths(args.CollectionFiles)
+	if err != nil {
+		return err
+	}
+	var file string
+	if len(files) > 0 {
+		file = files[0]
+	}
+	if err := connect(targetHost, targetPort); err != nil {
+		return err
+	}
+	defer disconnect()
+	return runBrowser(os.Stdin, os.Stdout, root, file, func(oid string) ([]gosnmp.SnmpPDU, bool, error) {
+		return walkSubtree(oid, browseMaxVariables)
+	})
+}
+
+// pduDisplayValue renders the value of a variable the way snmpwalk does:
+// strings quoted, object identifiers and enumerations named after the loaded
+// MIBs and TimeTicks with the duration they count
+func pduDisplayValue(pdu gosnmp.SnmpPDU) string {
+	switch pdu.Type {
+	case gosnmp.OctetString:
+		b, _ := pdu.Value.([]byte)
+		return strconv.Quote(octetStringValue(b))
+	case gosnmp.ObjectIdentifier:
+		oid, _ := pdu.Value.(string)
+		return mibs.translate(oid)
+	case gosnmp.IPAddress, gosnmp.OpaqueFloat, gosnmp.OpaqueDouble:
+		return fmt.Sprint(pdu.Value)
+	case gosnmp.TimeTicks:
+		ticks := gosnmp.ToBigInt(pdu.Value).Uint64()
+		return fmt.Sprintf("%d (%s)", ticks, formatUptime(ticks))
+	case gosnmp.Integer:
+		code := gosnmp.ToBigInt(pdu.Value).Int64()
+		if label, ok := mibs.enumeration(pdu.Name)[int(code)]; ok {
+			return fmt.Sprintf("%s(%d)", label, code)
+		}
+		return strconv.FormatInt(code, 10)
+	case gosnmp.Null, gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView:
+		return ""
+	}
+	return gosnmp.ToBigInt(pdu.Value).String()
+}
+
+// browser walks subtrees of the target and keeps the variables marked to be
+// appended to a collection file, across walks
+type browser struct {
+	*wizard
+	walk      func(oid string) ([]gosnmp.SnmpPDU, bool, error)
+	variables []gosnmp.SnmpPDU
+	marked    map[string]gosnmp.SnmpPDU
+}
+
+// runBrowser lists the variables of the subtree of root on the target and
+// reads the commands of the user, to walk other subtrees and mark variables
+// appended to a collection file, file by default, as proposed by -from_walk
+func runBrowser(in io.Reader, out io.Writer, root string, file string, walk func(oid string) ([]gosnmp.SnmpPDU, bool, error)) error {
+	b := &browser{
+		wizard: &wizard{in: bufio.NewReader(in), out: out},
+		walk:   walk,
+		marked: make(map[string]gosnmp.SnmpPDU),
+	}
+	b.walkTo(root)
+	for {
+		fmt.Fprint(b.out, "browse> ")
+		line, err := b.in.ReadString('\n')
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			if err != nil {
+				fmt.Fprintln(b.out)
+				break
+			}
+			continue
+		}
+		switch command, operands := fields[0], fields[1:]; command {
+		case "walk", "w":
+			if len(operands) != 1 {
+				fmt.Fprintln(b.out, "walk takes the OID of the subtree to walk")
+				continue
+			}
+			b.walkTo(operands[0])
+		case "list", "l":
+			b.list()
+		case "mark", "m":
+			b.mark(operands, true)
+		case "unmark", "u":
+			b.mark(operands, false)
+		case "save", "s":
+			target := file
+			if len(operands) > 0 {
+				target = operands[0]
+			}
+			if err := b.save(target); err != nil {
+				fmt.Fprintf(b.out, "nothing was saved: %v\n", err)
+			}
+		case "help", "h", "?":
+			fmt.Fprint(b.out, browseHelp)
+		case "quit", "q", "exit":
+			if len(b.marked) > 0 && !b.confirm(fmt.Sprintf("%d marked variables are not saved, quit anyway?", len(b.marked))) {
+				continue
+			}
+			return nil
+		default:
+			fmt.Fprintf(b.out, "unknown command %s, help lists the commands\n", command)
+		}
+	}
+	if len(b.marked) > 0 {
+		return fmt.Errorf("%d marked variables were not saved", len(b.marked))
+	}
+	return nil
+}
+
+// walkTo walks a subtree and lists its variables
+func (b *browser) walkTo(oid string) {
+	variables, truncated, err := b.walk(oid)
+	if err != nil {
+		fmt.Fprintf(b.out, "failed to walk %s: %v\n", oid, err)
+		return
+	}
+	b.variables = variables
+	b.list()
+	if truncated {
+		fmt.Fprintf(b.out, "only the first %d variables are listed, walk a narrower subtree for the others\n", len(variables))
+	}
+}
+
+// list prints the numbered variables of the last walk with their names,
+// types and values
+func (b *browser) list() {
+	if len(b.variables) == 0 {
+		fmt.Fprintln(b.out, "no variables")
+		return
+	}
+	for i, pdu := range b.variables {
+		mark := " "
+		if _, ok := b.marked[normalizeOid(pdu.Name)]; ok {
+			mark = "*"
+		}
+		fmt.Fprintf(b.out, "%4d %s %-40s %-16s %s\n", i+1, mark, mibs.translate(pdu.Name), pduTypeName(pdu.Type), pduDisplayValue(pdu))
+	}
+}
+
+// mark marks, or unmarks, the variables of the last walk selected by their
+// numbers, ranges of numbers or the OID of a subtree
+func (b *browser) mark(selections []string, marked bool) {
+	if len(selections) == 0 {
+		fmt.Fprintln(b.out, "select the variables by number, range such as 3-7, or OID of a subtree")
+		return
+	}
+	var selected []gosnmp.SnmpPDU
+	for _, selection := range selections {
+		first, last, err := parseBrowseRange(selection)
+		if err == nil {
+			if first < 1 || last > len(b.variables) || first > last {
+				fmt.Fprintf(b.out, "%s is not in 1-%d\n", selection, len(b.variables))
+				return
+			}
+			selected = append(selected, b.variables[first-1:last]...)
+			continue
+		}
+		prefix, err := resolveOid(selection)
+		if err != nil {
+			fmt.Fprintf(b.out, "%s is neither a number, a range nor an OID: %v\n", selection, err)
+			return
+		}
+		for _, pdu := range b.variables {
+			if oidHasPrefix(pdu.Name, prefix) {
+				selected = append(selected, pdu)
+			}
+		}
+	}
+	for _, pdu := range selected {
+		if marked {
+			b.marked[normalizeOid(pdu.Name)] = pdu
+		} else {
+			delete(b.marked, normalizeOid(pdu.Name))
+		}
+	}
+	fmt.Fprintf(b.out, "%d variables marked\n", len(b.marked))
+}
+
+// parseBrowseRange parses a variable number, or a range such as 3-7
+func parseBrowseRange(selection string) (int, int, error) {
+	bounds := strings.SplitN(selection, "-", 2)
+	first, err := strconv.Atoi(bounds[0])
+	if err != nil || len(bounds) == 1 {
+		return first, first, err
+	}
+	last, err := strconv.Atoi(bounds[1])
+	return first, last, err
+}
+
+// save appends the marked variables to a collection file as the metric sets
+// proposed for them, scalars and the tables they form, under a device
+func (b *browser) save(file string) error {
+	if len(b.marked) == 0 {
+		return fmt.Errorf("no variable is marked")
+	}
+	if file = strings.TrimSpace(file); file == "" {
+		return fmt.Errorf("name the collection file, such as save /etc/newrelic-infra/integrations.d/snmp-metrics.yml")
+	}
+	var oids []string
+	for oid := range b.marked {
+		oids = append(oids, oid)
+	}
+	sort.Slice(oids, func(i, j int) bool {
+		return compareOids(oids[i], oids[j]) < 0
+	})
+	var variables []*walkVariable
+	for _, oid := range oids {
+		pdu := b.marked[oid]
+		valueType, ok := snmpwalkTypes[pdu.Type]
+		if !ok {
+			valueType = pduTypeName(pdu.Type)
+		}
+		variables = append(variables, &walkVariable{arcs: oidArcs(oid), valueType: valueType, value: pduDisplayValue(pdu)})
+	}
+	device := b.ask("Device", targetHost)
+	metricSets := walkMetricSets(variables)
+	if err := appendMetricSets(file, device, metricSets); err != nil {
+		return err
+	}
+	fmt.Fprintf(b.out, "appended %d metric sets to %s\n", len(metricSets), file)
+	b.marked = make(map[string]gosnmp.SnmpPDU)
+	return nil
+}
+
+// appendMetricSets appends a device with metric sets to the collect list of
+// a YAML collection file, keeping the rest of the file and its comments, or
+// writes a new collection file. The collect list must be the last key of the
+// file and a block list
+func appendMetricSets(file string, device string, metricSets []generatedMetricSet) error {
+	devices := []generatedDevice{{Device: device, MetricSets: metricSets}}
+	src, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		out, err := yaml.Marshal(generatedCollection{Collect: devices})
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(file, setFormatVersion(out, false), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		return fmt.Errorf("metric sets can only be appended to YAML collection files")
+	}
+	var keys yaml.MapSlice
+	if err := yaml.Unmarshal(src, &keys); err != nil {
+		return err
+	}
+	if len(keys) == 0 || keys[len(keys)-1].Key != "collect" {
+		return fmt.Errorf("collect is not the last key of %s", file)
+	}
+	indent := ""
+	if match := collectItem.FindSubmatch(src); match != nil {
+		indent = string(match[1])
+	} else if keys[len(keys)-1].Value != nil {
+		return fmt.Errorf("the collect list of %s is not a block list", file)
+	}
+	out, err := yaml.Marshal(devices)
+	if err != nil {
+		return err
+	}
+	appended := string(src)
+	if appended != "" && !strings.HasSuffix(appended, "\n") {
+		appended += "\n"
+	}
+	for _, line := range strings.SplitAfter(string(out), "\n") {
+		if line != "" {
+			appended += indent + line
+		}
+	}
+	var appendedKeys yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(appended), &appendedKeys); err != nil {
+		return fmt.Errorf("the metric sets can not be appended to %s: %v", file, err)
+	}
+	return ioutil.WriteFile(file, []byte(appended), info.Mode().Perm())
+}
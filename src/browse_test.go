@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/soniah/gosnmp"
+)
+
+func TestRunBrowser(t *testing.T) {
+	dir, err := ioutil.TempDir("", "browse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "snmp-metrics.yml")
+	existing := `# core switches
+collect:
+  # the system group
+  - device: core
+    metric_sets:
+      - {name: system, type: scalar, event_type: SNMPSample, metrics: [{metric_name: sysName, oid: .1.3.6.1.2.1.1.5.0, metric_type: attribute}]}
+`
+	if err := ioutil.WriteFile(file, []byte(existing), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	subtrees := map[string][]gosnmp.SnmpPDU{
+		".1.3.6.1.2.1.2.2": {
+			{Name: ".1.3.6.1.2.1.2.2.1.2.1", Type: gosnmp.OctetString, Value: []byte("eth0")},
+			{Name: ".1.3.6.1.2.1.2.2.1.2.2", Type: gosnmp.OctetString, Value: []byte("eth1")},
+			{Name: ".1.3.6.1.2.1.2.2.1.10.1", Type: gosnmp.Counter32, Value: uint(1200)},
+			{Name: ".1.3.6.1.2.1.2.2.1.10.2", Type: gosnmp.Counter32, Value: uint(3400)},
+		},
+		".1.3.6.1.2.1.1.3": {
+			{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(8640000)},
+		},
+	}
+	var walked []string
+	walk := func(oid string) ([]gosnmp.SnmpPDU, bool, error) {
+		walked = append(walked, oid)
+		return subtrees[oid], false, nil
+	}
+	commands := strings.Join([]string{"mark .1.3.6.1.2.1.2.2.1.2", "walk .1.3.6.1.2.1.1.3", "mark 1", "bogus", "unmark 1", "walk .1.3.6.1.2.1.2.2", "mark 3-4", "save", "edge", "quit"}, "\n") + "\n"
+	var out bytes.Buffer
+	if err := runBrowser(strings.NewReader(commands), &out, ".1.3.6.1.2.1.2.2", file, walk); err != nil {
+		t.Fatal(err)
+	}
+	if len(walked) != 3 {
+		t.Errorf("expected 3 walks, got %v", walked)
+	}
+	for _, expected := range []string{
+		`.1.3.6.1.2.1.2.2.1.2.1`, `OctetString`, `"eth0"`,
+		`8640000 (1d 00:00:00)`,
+		"unknown command bogus",
+		"appended 1 metric sets to " + file,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the output\n%s", expected, out.String())
+		}
+	}
+
+	saved, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(saved), existing) || !strings.Contains(string(saved), "\n  - device: edge\n") {
+		t.Errorf("expected the device appended to the collect list\n%s", saved)
+	}
+	parser, err := loadCollectionFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collections, err := parseCollection(parser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collections) != 2 || collections[1].Device != "edge" {
+		t.Fatalf("unexpected collections %+v", collections)
+	}
+	table := collections[1].MetricSets[len(collections[1].MetricSets)-1]
+	if table.Type != "table" || table.RootOid != ".1.3.6.1.2.1.2.2" || len(table.Metrics) != 2 || table.Metrics[0].metricType != attribute || table.Metrics[1].metricType != rate {
+		t.Errorf("unexpected table metric set %+v", table)
+	}
+
+	//a new file is written with the marked variables
+	newFile := filepath.Join(dir, "new.yml")
+	commands = "mark 1\nsave " + newFile + "\n\nq\n"
+	out.Reset()
+	if err := runBrowser(strings.NewReader(commands), &out, ".1.3.6.1.2.1.1.3", "", walk); err != nil {
+		t.Fatal(err)
+	}
+	parser, err = loadCollectionFile(newFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parser.FormatVersion != currentFormatVersion || parser.Collect[0].Device != targetHost || parser.Collect[0].MetricSets[0].Metrics[0].Oid != ".1.3.6.1.2.1.1.3.0" {
+		t.Errorf("unexpected new collection file %+v", parser)
+	}
+
+	//marked variables are not lost silently
+	if err := runBrowser(strings.NewReader("mark 1\n"), &out, ".1.3.6.1.2.1.1.3", "", walk); err == nil {
+		t.Error("expected error for marked variables not saved")
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/log"
+)
+
+// collectionFileExtensions are the extensions of the files read from a
+// directory of collection_files
+var collectionFileExtensions = map[string]bool{
+	".yml":  true,
+	".yaml": true,
+	".json": true,
+}
+
+// collectionFilePaths expands the comma separated collection_files into the
+// files to collect. A directory stands for the collection files it holds and
+// a glob, such as `/etc/nri-snmp/collections.d/*.yml`, for the files it
+// matches, each in name order and without hidden files. Relative paths are
+// returned unexpanded, to be rejected by the caller
+func collectionFilePaths(list string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	for _, path := range strings.Split(list, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			add(path)
+			continue
+		}
+		if strings.ContainsAny(path, "*?[") {
+			matches, err := filepath.Glob(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid collection files pattern %s: %v", path, err)
+			}
+			if len(matches) == 0 {
+				log.Warn("no collection file matches %s", path)
+			}
+			sort.Strings(matches)
+			for _, match := range matches {
+				//as in the shell, hidden files are only matched explicitly
+				if strings.HasPrefix(filepath.Base(match), ".") && !strings.HasPrefix(filepath.Base(path), ".") {
+					continue
+				}
+				add(match)
+			}
+			continue
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			//not a directory, missing files are reported when they are read
+			add(path)
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || strings.HasPrefix(name, ".") || !collectionFileExtensions[strings.ToLower(filepath.Ext(name))] {
+				continue
+			}
+			add(filepath.Join(path, name))
+		}
+	}
+	return files, nil
+}
+
+// configuredCollectionFiles returns the collection files of the arguments and
+// the files of the profiles they select by name, without connecting to the
+// target. The profile selected by `profiles: auto` depends on the target and
+// is left out
+func configuredCollectionFiles() ([]string, error) {
+	files, err := collectionFilePaths(args.CollectionFiles)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncProfileRepository(); err != nil {
+		return nil, fmt.Errorf("failed to fetch the profile repository: %v", err)
+	}
+	var names []string
+	for _, name := range strings.Split(enabledProfiles(args.Profiles), ",") {
+		if name = strings.TrimSpace(name); name != autoProfile {
+			names = append(names, name)
+		}
+	}
+	profiles, err := profileFiles(strings.Join(names, ","))
+	if err != nil {
+		return nil, err
+	}
+	return append(files, profiles...), nil
+}
+
+// mergeIncludes merges the libraries included by a collection file into its
+// shared metric sets, inventory and type conversions. Libraries are merged in
+// the order they are included, the files of a glob in name order, and a
+// metric set redefines the one of the same name included before it, in
+// place. The metric sets and type conversions of the file itself override
+// the included ones. Only the shared `metric_sets`, `inventory` and
+// `type_conversions` of a library are included, after its own includes
+func mergeIncludes(file string, parser *collectionParser, includedBy []string) error {
+	if len(parser.Include) == 0 {
+		return nil
+	}
+	for _, including := range includedBy {
+		if including == file {
+			return fmt.Errorf("collection file %s includes itself through %s", file, strings.Join(includedBy, ", "))
+		}
+	}
+	var metricSets []metricSetParser
+	var inventory []inventoryParser
+	typeConversions := make(map[string]string)
+	metricGroups := make(map[string][]metricParser)
+	positions := make(map[string]int)
+	addMetricSets := func(sets []metricSetParser) {
+		for _, metricSet := range sets {
+			if position, ok := positions[metricSet.Name]; ok {
+				metricSets[position] = metricSet
+				continue
+			}
+			positions[metricSet.Name] = len(metricSets)
+			metricSets = append(metricSets, metricSet)
+		}
+	}
+	for _, include := range parser.Include {
+		libraries, err := includedFiles(file, strings.TrimSpace(include))
+		if err != nil {
+			return err
+		}
+		for _, library := range libraries {
+			included, err := parseYaml(library)
+			if _, located := err.(schemaErrors); located {
+				return err
+			}
+			if err != nil {
+				return fmt.Errorf("%s includes %s: %v", file, library, err)
+			}
+			if err := mergeIncludes(library, included, append(includedBy, file)); err != nil {
+				return err
+			}
+			if len(included.Collect) > 0 {
+				log.Warn("the devices of %s are not included by %s, only its shared metric sets", library, file)
+			}
+			addMetricSets(included.MetricSets)
+			inventory = append(inventory, included.Inventory...)
+			for pduType, metricType := range included.TypeConversions {
+				typeConversions[pduType] = metricType
+			}
+			for name, metrics := range included.MetricGroups {
+				metricGroups[name] = metrics
+			}
+		}
+	}
+	addMetricSets(parser.MetricSets)
+	parser.MetricSets = metricSets
+	parser.Inventory = append(inventory, parser.Inventory...)
+	for pduType, metricType := range parser.TypeConversions {
+		typeConversions[pduType] = metricType
+	}
+	if len(typeConversions) > 0 {
+		parser.TypeConversions = typeConversions
+	}
+	for name, metrics := range parser.MetricGroups {
+		metricGroups[name] = metrics
+	}
+	if len(metricGroups) > 0 {
+		parser.MetricGroups = metricGroups
+	}
+	return nil
+}
+
+// useMetricGroups adds the metrics of the groups each metric set of a
+// collection file uses, in order, before the metrics of the set. A metric of
+// the set replaces the metric of a group with the same name, or OID
+func useMetricGroups(file string, parser *collectionParser) error {
+	key := func(metric metricParser) string {
+		if metric.MetricName != "" {
+			return metric.MetricName
+		}
+		return normalizeOid(metric.Oid)
+	}
+	use := func(metricSets []metricSetParser) error {
+		for i := range metricSets {
+			metricSet := &metricSets[i]
+			if len(metricSet.Use) == 0 {
+				continue
+			}
+			own := make(map[string]bool)
+			for _, metric := range metricSet.Metrics {
+				own[key(metric)] = true
+			}
+			var metrics []metricParser
+			for _, name := range metricSet.Use {
+				group, ok := parser.MetricGroups[strings.TrimSpace(name)]
+				if !ok {
+					return fmt.Errorf("metric set %s of %s uses an unknown metric group %s", metricSet.Name, file, name)
+				}
+				for _, metric := range group {
+					if !own[key(metric)] {
+						metrics = append(metrics, metric)
+					}
+				}
+			}
+			metricSet.Metrics = append(metrics, metricSet.Metrics...)
+			metricSet.Use = nil
+		}
+		return nil
+	}
+	if err := use(parser.MetricSets); err != nil {
+		return err
+	}
+	for i := range parser.Collect {
+		if err := use(parser.Collect[i].MetricSets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadCollections parses the collection files into the collections of their
+// devices. A file failing to parse is skipped with a warning naming the file,
+// and the metric set at fault, so the other files are still collected
+func loadCollections(files []string) []*collection {
+	var collections []*collection
+	for _, file := range files {
+		// Check that the filepath is an absolute path
+		if !filepath.IsAbs(file) {
+			log.Warn("skipping collection definition file %s: metrics collection files must be specified as absolute paths", file)
+			continue
+		}
+		parser, err := loadCollectionFile(file)
+		if err != nil {
+			log.Warn("skipping collection definition file %s: %v", file, err)
+			continue
+		}
+		parsed, err := parseCollection(parser)
+		if err != nil {
+			log.Warn("skipping collection definition file %s: %v", file, err)
+			continue
+		}
+		collections = append(collections, parsed...)
+	}
+	return collections
+}
+
+// includedFiles resolves an include of a collection file, relative to the
+// directory of the file, into the files it names
+func includedFiles(file string, include string) ([]string, error) {
+	if !filepath.IsAbs(include) {
+		include = filepath.Join(filepath.Dir(file), include)
+	}
+	if !strings.ContainsAny(include, "*?[") {
+		return []string{include}, nil
+	}
+	matches, err := filepath.Glob(include)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include %s in %s: %v", include, file, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no file matches the include %s of %s", include, file)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// shareMetricSets adds the shared metric sets and inventory of a collection
+// file to each of its devices, before their own, except for the metric sets
+// a device redefines by name
+func shareMetricSets(parser *collectionParser) {
+	if len(parser.MetricSets) == 0 && len(parser.Inventory) == 0 {
+		return
+	}
+	for i := range parser.Collect {
+		device := &parser.Collect[i]
+		redefined := make(map[string]bool)
+		for _, metricSet := range device.MetricSets {
+			redefined[metricSet.Name] = true
+		}
+		var metricSets []metricSetParser
+		for _, metricSet := range parser.MetricSets {
+			if !redefined[metricSet.Name] {
+				metricSets = append(metricSets, metricSet)
+			}
+		}
+		device.MetricSets = append(metricSets, device.MetricSets...)
+		device.Inventory = append(append([]inventoryParser(nil), parser.Inventory...), device.Inventory...)
+	}
+}
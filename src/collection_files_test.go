@@ -0,0 +1,193 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCollectionFilePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "collections.d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"b-team.yml", "a-team.yaml", "c-team.json", "README.md", ".hidden.yml"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("collect: []\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	single := filepath.Join(dir, "b-team.yml")
+	files, err := collectionFilePaths(single + ", " + dir + "," + filepath.Join(dir, "*.y*ml") + ",relative.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		single,
+		filepath.Join(dir, "a-team.yaml"),
+		filepath.Join(dir, "c-team.json"),
+		"relative.yml",
+	}
+	if strings.Join(files, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected files %v", files)
+	}
+	if _, err := collectionFilePaths(filepath.Join(dir, "[")); err == nil {
+		t.Error("expected error for an invalid pattern")
+	}
+}
+
+func TestIncludeLibraries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "include")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "shared"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"shared/a-interfaces.yml": `type_conversions: {Counter32: rate, Gauge32: gauge}
+metric_sets:
+- {name: interfaces, type: table, event_type: SNMPInterfaceSample, root_oid: .1.3.6.1.2.1.2.2, metrics: [{metric_name: ifInOctets, oid: .1.3.6.1.2.1.2.2.1.10}]}
+- {name: system, type: scalar, event_type: SNMPSample, metrics: [{metric_name: sysName, oid: .1.3.6.1.2.1.1.5.0}]}
+`,
+		"shared/b-interfaces-hc.yml": `include: [../common.yml]
+metric_sets:
+- {name: interfaces, type: table, event_type: SNMPInterfaceSample, root_oid: .1.3.6.1.2.1.31.1.1, metrics: [{metric_name: ifHCInOctets, oid: .1.3.6.1.2.1.31.1.1.1.6}]}
+`,
+		"common.yml": `inventory:
+- {category: system, name: sysDescr, oid: .1.3.6.1.2.1.1.1.0}
+`,
+		"router.yml": `include: [shared/*.yml]
+type_conversions: {Counter32: delta}
+collect:
+- device: router
+  metric_sets:
+  - {name: system, type: scalar, event_type: SNMPSample, metrics: [{metric_name: sysDescr, oid: .1.3.6.1.2.1.1.1.0}]}
+- device: switch
+`,
+		"loop.yml": "include: [loop.yml]\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parser, err := loadCollectionFile(filepath.Join(dir, "router.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, device := parser.Collect[0], parser.Collect[1]
+	if len(router.MetricSets) != 2 || router.MetricSets[0].Metrics[0].MetricName != "ifHCInOctets" || router.MetricSets[1].Metrics[0].MetricName != "sysDescr" {
+		t.Errorf("unexpected metric sets of the router %+v", router.MetricSets)
+	}
+	if len(device.MetricSets) != 2 || device.MetricSets[1].Metrics[0].MetricName != "sysName" || len(device.Inventory) != 1 {
+		t.Errorf("unexpected metric sets of the switch %+v", device)
+	}
+	if parser.TypeConversions["Counter32"] != "delta" || parser.TypeConversions["Gauge32"] != "gauge" {
+		t.Errorf("unexpected type conversions %v", parser.TypeConversions)
+	}
+	if _, err := loadCollectionFile(filepath.Join(dir, "loop.yml")); err == nil {
+		t.Error("expected error for a file including itself")
+	}
+}
+
+func TestMetricGroups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "groups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"if-counters.yml": `defaults: {metric_type: rate}
+metric_groups:
+  standard-if-counters:
+  - {metric_name: ifInOctets, oid: .1.3.6.1.2.1.2.2.1.10}
+  - {metric_name: ifOutOctets, oid: .1.3.6.1.2.1.2.2.1.16}
+  if-status:
+  - {metric_name: ifOperStatus, oid: .1.3.6.1.2.1.2.2.1.8, metric_type: attribute}
+`,
+		"site.yml": `include: [if-counters.yml]
+collect:
+- device: site
+  metric_sets:
+  - name: interfaces
+    type: table
+    event_type: SNMPInterfaceSample
+    root_oid: .1.3.6.1.2.1.2.2
+    use: [standard-if-counters, if-status]
+    metrics:
+    - {metric_name: ifOutOctets, oid: .1.3.6.1.2.1.2.2.1.16, metric_type: delta}
+    - {metric_name: ifDescr, oid: .1.3.6.1.2.1.2.2.1.2, metric_type: attribute}
+`,
+		"unknown.yml": `collect:
+- device: site
+  metric_sets:
+  - {name: interfaces, type: table, event_type: SNMPInterfaceSample, root_oid: .1.3.6.1.2.1.2.2, use: [missing]}
+`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parser, err := loadCollectionFile(filepath.Join(dir, "site.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, metric := range parser.Collect[0].MetricSets[0].Metrics {
+		names = append(names, metric.MetricName+":"+metric.MetricType)
+	}
+	expected := "ifInOctets:rate ifOperStatus:attribute ifOutOctets:delta ifDescr:attribute"
+	if strings.Join(names, " ") != expected {
+		t.Errorf("expected metrics %s, got %s", expected, strings.Join(names, " "))
+	}
+	if _, err := loadCollectionFile(filepath.Join(dir, "unknown.yml")); err == nil || !strings.Contains(err.Error(), "unknown metric group missing") {
+		t.Errorf("expected error for an unknown metric group, got %v", err)
+	}
+}
+
+func TestLoadCollections(t *testing.T) {
+	dir, err := ioutil.TempDir("", "collections")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a-broken.yml": `collect:
+- device: broken
+  metric_sets:
+  - name: interfaces
+    type: table
+    event_type: SNMPInterfaceSample
+    metrics:
+    - {metric_name: ifInOctets, oid: .1.3.6.1.2.1.2.2.1.10, metric_type: counter}
+`,
+		"b-system.yml": `collect:
+- device: system
+  metric_sets:
+  - {name: system, type: scalar, event_type: SNMPSample, metrics: [{metric_name: sysName, oid: .1.3.6.1.2.1.1.5.0}]}
+`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parser, err := loadCollectionFile(filepath.Join(dir, "a-broken.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseCollection(parser); err == nil || !strings.Contains(err.Error(), "metric set interfaces") {
+		t.Errorf("expected an error naming the metric set, got %v", err)
+	}
+	collections := loadCollections([]string{filepath.Join(dir, "a-broken.yml"), "relative.yml", filepath.Join(dir, "b-system.yml")})
+	if len(collections) != 1 || collections[0].Device != "system" {
+		t.Errorf("expected only the collection of the valid file, got %+v", collections)
+	}
+}
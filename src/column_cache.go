@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/soniah/gosnmp"
+)
+
+// cachedValue is the serializable form of the value of a table cell
+type cachedValue struct {
+	Type  gosnmp.Asn1BER
+	Bytes []byte  `json:",omitempty"`
+	Text  string  `json:",omitempty"`
+	Int   int64   `json:",omitempty"`
+	Uint  uint64  `json:",omitempty"`
+	Float float64 `json:",omitempty"`
+}
+
+// columnCacheKey identifies the cached values of a table column of the target device
+func columnCacheKey(column string) string {
+	return fmt.Sprintf("column:%s:%d:%s", targetHost, targetPort, column)
+}
+
+// columnCacheTTL returns for how long the values of column can be reused by
+// the metric sets sharing a walk: the shortest cache_ttl among the indexes and
+// metrics reading it, or 0 if any of them is not cached
+func columnCacheTTL(metricSets []metricSet, column string) time.Duration {
+	var ttl time.Duration
+	used := false
+	apply := func(columnTTL time.Duration) {
+		if !used || columnTTL < ttl {
+			ttl = columnTTL
+		}
+		used = true
+	}
+	for _, metricSet := range metricSets {
+		for _, index := range metricSet.Index {
+			if index.oid == column {
+				apply(index.cacheTTL)
+			}
+		}
+		for _, metric := range metricSet.Metrics {
+			if metric.oid == column || metric.fallbackOid == column {
+				apply(metric.cacheTTL)
+			}
+		}
+	}
+	return ttl
+}
+
+// loadCachedColumn returns the values of column, keyed by index key, stored
+// no longer than ttl ago
+func loadCachedColumn(column string, ttl time.Duration) (map[string]gosnmp.SnmpPDU, bool) {
+	var values map[string]cachedValue
+	storedAt, ok := getState(columnCacheKey(column), &values)
+	if !ok || time.Since(storedAt) >= ttl {
+		return nil, false
+	}
+	pdus := make(map[string]gosnmp.SnmpPDU, len(values))
+	for indexKey, value := range values {
+		pdus[indexKey] = value.pdu(column + "." + indexKey)
+	}
+	return pdus, true
+}
+
+// storeCachedColumn stores the values of column, keyed by index key
+func storeCachedColumn(column string, pdus map[string]gosnmp.SnmpPDU) {
+	values := make(map[string]cachedValue, len(pdus))
+	for indexKey, pdu := range pdus {
+		values[indexKey] = newCachedValue(pdu)
+	}
+	setState(columnCacheKey(column), values)
+}
+
+// columnAnsweredKey identifies whether the target device has values for a
+// table column having a fallback_oid
+func columnAnsweredKey(column string) string {
+	return fmt.Sprintf("answered:%s:%d:%s", targetHost, targetPort, column)
+}
+
+// loadColumnAnswered returns whether the target device was found to have
+// values for column. ok is false if it was not probed yet
+func loadColumnAnswered(column string) (answered bool, ok bool) {
+	_, ok = getState(columnAnsweredKey(column), &answered)
+	return answered, ok
+}
+
+// storeColumnAnswered remembers whether the target device has values for column
+func storeColumnAnswered(column string, answered bool) {
+	setState(columnAnsweredKey(column), answered)
+}
+
+// forgetColumnAnswered has column probed again on the next run
+func forgetColumnAnswered(column string) {
+	deleteState(columnAnsweredKey(column))
+}
+
+func newCachedValue(pdu gosnmp.SnmpPDU) cachedValue {
+	value := cachedValue{Type: pdu.Type}
+	switch v := pdu.Value.(type) {
+	case []byte:
+		value.Bytes = v
+	case string:
+		value.Text = v
+	case int:
+		value.Int = int64(v)
+	case uint:
+		value.Uint = uint64(v)
+	case uint64:
+		value.Uint = v
+	case float32:
+		value.Float = float64(v)
+	case float64:
+		value.Float = v
+	}
+	return value
+}
+
+// pdu restores the PDU of the cell with the given OID, with the value types gosnmp uses
+func (value cachedValue) pdu(oid string) gosnmp.SnmpPDU {
+	pdu := gosnmp.SnmpPDU{Name: oid, Type: value.Type}
+	switch value.Type {
+	case gosnmp.OctetString, gosnmp.BitString, gosnmp.Opaque:
+		pdu.Value = value.Bytes
+	case gosnmp.ObjectIdentifier, gosnmp.IPAddress:
+		pdu.Value = value.Text
+	case gosnmp.Integer:
+		pdu.Value = int(value.Int)
+	case gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Uinteger32:
+		pdu.Value = uint(value.Uint)
+	case gosnmp.Counter64:
+		pdu.Value = value.Uint
+	case gosnmp.OpaqueFloat:
+		pdu.Value = float32(value.Float)
+	case gosnmp.OpaqueDouble:
+		pdu.Value = value.Float
+	}
+	return pdu
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/persist"
+	"github.com/soniah/gosnmp"
+)
+
+func TestCachedValueRoundTrip(t *testing.T) {
+	pdus := []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.2.2.1.2.1", Type: gosnmp.OctetString, Value: []byte("eth0")},
+		{Name: ".1.3.6.1.2.1.2.2.1.3.1", Type: gosnmp.Integer, Value: 6},
+		{Name: ".1.3.6.1.2.1.2.2.1.5.1", Type: gosnmp.Gauge32, Value: uint(1000000000)},
+		{Name: ".1.3.6.1.2.1.31.1.1.1.6.1", Type: gosnmp.Counter64, Value: uint64(1) << 40},
+		{Name: ".1.3.6.1.2.1.2.2.1.22.1", Type: gosnmp.ObjectIdentifier, Value: ".0.0"},
+	}
+	for _, pdu := range pdus {
+		encoded, err := json.Marshal(newCachedValue(pdu))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var value cachedValue
+		if err := json.Unmarshal(encoded, &value); err != nil {
+			t.Fatal(err)
+		}
+		if restored := value.pdu(pdu.Name); !reflect.DeepEqual(restored, pdu) {
+			t.Errorf("restored %#v, expected %#v", restored, pdu)
+		}
+	}
+}
+
+func TestColumnCacheTTL(t *testing.T) {
+	metricSets := []metricSet{
+		{Metrics: []*metricDef{{oid: ".1.2.1", cacheTTL: 3600e9}, {oid: ".1.2.2", cacheTTL: 600e9}}},
+		{Metrics: []*metricDef{{oid: ".1.2.2", cacheTTL: 1200e9}, {oid: ".1.2.3"}}},
+		{Metrics: []*metricDef{{oid: ".1.2.3", cacheTTL: 600e9}}},
+	}
+	cases := map[string]float64{".1.2.1": 3600, ".1.2.2": 600, ".1.2.3": 0}
+	for column, expected := range cases {
+		if ttl := columnCacheTTL(metricSets, column).Seconds(); ttl != expected {
+			t.Errorf("columnCacheTTL(%s) = %vs, expected %vs", column, ttl, expected)
+		}
+	}
+}
+
+// probingAgent answers the GETNEXT probing a column with a single varbind
+type probingAgent struct {
+	pdu      gosnmp.SnmpPDU
+	requests int
+}
+
+func (a *probingAgent) GetNext(oids []string) (*gosnmp.SnmpPacket, error) {
+	a.requests++
+	return &gosnmp.SnmpPacket{Variables: []gosnmp.SnmpPDU{a.pdu}}, nil
+}
+
+func TestUseFallback(t *testing.T) {
+	stateStore = persist.NewInMemoryStore()
+	defer func() { stateStore = nil }()
+	column := ".1.3.6.1.2.1.31.1.1.1.6"
+	cases := []struct {
+		name     string
+		pdu      gosnmp.SnmpPDU
+		fallback bool
+	}{
+		{"value", gosnmp.SnmpPDU{Name: column + ".1", Type: gosnmp.Counter64, Value: uint64(5)}, false},
+		{"null value", gosnmp.SnmpPDU{Name: column + ".1", Type: gosnmp.NoSuchInstance}, true},
+		{"no rows", gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.31.1.1.1.7.1", Type: gosnmp.Counter64, Value: uint64(5)}, true},
+		{"end of MIB", gosnmp.SnmpPDU{Name: column, Type: gosnmp.EndOfMibView}, true},
+	}
+	for _, c := range cases {
+		forgetColumnAnswered(column)
+		agent := &probingAgent{pdu: c.pdu}
+		for run := 0; run < 2; run++ {
+			if fallback := useFallback(agent, column); fallback != c.fallback {
+				t.Errorf("%s: useFallback = %v on run %d, expected %v", c.name, fallback, run, c.fallback)
+			}
+		}
+		if agent.requests != 1 {
+			t.Errorf("%s: %d probes, expected the answer to be remembered after 1", c.name, agent.requests)
+		}
+	}
+}
+
+func TestForgetEmptyFallbackChoices(t *testing.T) {
+	stateStore = persist.NewInMemoryStore()
+	defer func() { stateStore = nil }()
+	metricSets := []metricSet{{Metrics: []*metricDef{
+		{oid: ".1.2.1", fallbackOid: ".1.3.1"},
+		{oid: ".1.2.2", fallbackOid: ".1.3.2"},
+		{oid: ".1.2.3", fallbackOid: ".1.3.3"},
+	}}}
+	storeColumnAnswered(".1.2.1", true)
+	storeColumnAnswered(".1.2.2", false)
+	storeColumnAnswered(".1.2.3", false)
+	fallbacks := map[string]bool{".1.2.1": false, ".1.2.2": true, ".1.2.3": true}
+	valued := map[string]bool{".1.2.1": true, ".1.3.2": true}
+	forgetEmptyFallbackChoices(metricSets, fallbacks, valued)
+	for column, remembered := range map[string]bool{".1.2.1": true, ".1.2.2": true, ".1.2.3": false} {
+		if _, ok := loadColumnAnswered(column); ok != remembered {
+			t.Errorf("answer for %s remembered %v, expected %v", column, ok, remembered)
+		}
+	}
+}
@@ -1,22 +1,57 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/newrelic/infra-integrations-sdk/log"
+	"github.com/soniah/gosnmp"
 	yaml "gopkg.in/yaml.v2"
 )
 
 // collectionParser is a struct to aid the automatic
 // parsing of a collection yaml file
 type collectionParser struct {
-	Collect []struct {
-		Device     string            `yaml:"device"`
-		MetricSets []metricSetParser `yaml:"metric_sets"`
-		Inventory  []inventoryParser `yaml:"inventory"`
-	}
+	// FormatVersion is the version of the collection file format the file is written in, 1 when unset
+	FormatVersion int `yaml:"format_version"`
+	// TypeConversions are the metric types of the metrics without a metric_type, by PDU type
+	TypeConversions map[string]string `yaml:"type_conversions"`
+	// SysObjectIds are the sysObjectID prefixes of the devices a profile is selected for by `profiles: auto`
+	SysObjectIds []string `yaml:"sys_object_ids"`
+	// SysDescrs are regular expressions, one of which the sysDescr of the device must match for `profiles: auto` to select a profile
+	SysDescrs []string `yaml:"sys_descr"`
+	// Extends are the profiles, by name or absolute path, whose metric sets and inventory are collected before the ones of this file
+	Extends []string `yaml:"extends"`
+	// Variables are the default values of the `${NAME}` variables the file references
+	Variables map[string]string `yaml:"variables"`
+	// Include are the metric set libraries, by path relative to the file or glob, whose metric sets and inventory are shared by every device of the file
+	Include []string `yaml:"include"`
+	// Defaults apply to every metric set and metric of the file that does not set them
+	Defaults *defaultsParser `yaml:"defaults"`
+	// Enabled is false to keep the file, or profile, from being collected, it can still be extended and included
+	Enabled *bool `yaml:"enabled"`
+	// MetricGroups are named lists of metrics the metric sets of the file, and of the files including it, use
+	MetricGroups map[string][]metricParser `yaml:"metric_groups"`
+	// MetricSets and Inventory are shared by every device of the file, and by the files including it
+	MetricSets []metricSetParser `yaml:"metric_sets"`
+	Inventory  []inventoryParser `yaml:"inventory"`
+	Collect    []deviceParser
+}
+
+// deviceParser is a struct to aid the automatic
+// parsing of a collection yaml file
+type deviceParser struct {
+	Device     string            `yaml:"device"`
+	MetricSets []metricSetParser `yaml:"metric_sets"`
+	Inventory  []inventoryParser `yaml:"inventory"`
 }
 
 // metricSetParser is a struct to aid the automatic
@@ -27,22 +62,130 @@ type metricSetParser struct {
 	EventType string         `yaml:"event_type"`
 	Metrics   []metricParser `yaml:"metrics"`
 	RootOid   string         `yaml:"root_oid"`
+	Table     string         `yaml:"table"`
+	Augments  string         `yaml:"augments"`
 	Index     []indexParser  `yaml:"index"`
+	Indexes   []string       `yaml:"indexes"`
+
+	IndexComponents []indexComponentParser `yaml:"index_components"`
+	IndexTemplate   *indexTemplateParser   `yaml:"index_template"`
+	MaxRows         int                    `yaml:"max_rows"`
+	PageSize        int                    `yaml:"page_size"`
+	// Timeout of the requests of the metric set, such as `30s` for a slow table, instead of the timeout argument
+	Timeout string `yaml:"timeout"`
+	// Enabled is false to turn the metric set off without removing it
+	Enabled *bool `yaml:"enabled"`
+	// Use are the metric groups whose metrics are collected before the metrics of the set
+	Use []string `yaml:"use"`
+	// CollectAllColumns reports every column found under RootOid, not just the configured metrics
+	CollectAllColumns bool `yaml:"collect_all_columns"`
+
+	RowTags     map[string]map[string]string `yaml:"row_tags"`
+	RowTagsFile string                       `yaml:"row_tags_file"`
+	RowTagsKey  string                       `yaml:"row_tags_key"`
+	// Output is the shape of the reported table, `rows` or `pivot`
+	Output string `yaml:"output"`
+	// Derived metrics are computed from the other metrics of the set
+	Derived []derivedParser `yaml:"derived"`
+	// DiscontinuityOid is a TimeTicks column, such as ifCounterDiscontinuityTime, that changes when the counters of a row are reset
+	DiscontinuityOid string `yaml:"discontinuity_oid"`
+	// Aggregates summarize a column across the rows of a table
+	Aggregates []aggregateParser `yaml:"aggregates"`
+	// Attributes are composed from the collected values with Go templates, e.g. "{{.sysLocation}} / {{.sysName}}"
+	Attributes map[string]string `yaml:"attributes"`
 }
 
 // metricParser is a struct to aid the automatic
 // parsing of a collection yaml file
 type metricParser struct {
-	Oid        string `yaml:"oid"`
-	MetricType string `yaml:"metric_type"`
+	Oid        string         `yaml:"oid"`
+	MetricType string         `yaml:"metric_type"`
+	MetricName string         `yaml:"metric_name"`
+	Format     string         `yaml:"format"`
+	Bits       map[int]string `yaml:"bits"`
+	// Values maps the integer codes of an enumeration to the labels reported instead
+	Values   map[int]string `yaml:"values"`
+	EmitCode bool           `yaml:"emit_code"`
+
+	NullPolicy   string `yaml:"null_policy"`
+	DefaultValue string `yaml:"default_value"`
+	CacheTTL     string `yaml:"cache_ttl"`
+	// FallbackOid is read when the device does not return Oid, e.g. a Counter32 column standing in for its HC Counter64 variant
+	FallbackOid string `yaml:"fallback_oid"`
+	// Extract is a regular expression whose first group is parsed as the numeric value of a string
+	Extract string `yaml:"extract"`
+	// Scale and Offset convert numeric values as value*scale+offset before they are reported
+	Scale  *float64 `yaml:"scale"`
+	Offset float64  `yaml:"offset"`
+	Unit   string   `yaml:"unit"`
+	// Precision is the number of decimals float values are rounded to, overriding float_precision
+	Precision *int `yaml:"precision"`
+	// Mask and Shift extract a field packed in an integer value as (value & mask) >> shift
+	Mask  *uint64 `yaml:"mask"`
+	Shift uint    `yaml:"shift"`
+	// LookupFile is a CSV or YAML file mapping values to the labels reported in LookupAttribute
+	LookupFile      string `yaml:"lookup_file"`
+	LookupAttribute string `yaml:"lookup_attribute"`
+}
+
+// derivedParser is a struct to aid the automatic
+// parsing of a collection yaml file
+type derivedParser struct {
+	MetricName string           `yaml:"metric_name"`
+	Expression string           `yaml:"expression"`
+	MetricType string           `yaml:"metric_type"`
+	PercentOf  *percentOfParser `yaml:"percent_of"`
+}
+
+// aggregateParser is a struct to aid the automatic
+// parsing of a collection yaml file
+type aggregateParser struct {
 	MetricName string `yaml:"metric_name"`
+	Column     string `yaml:"column"`
+	Function   string `yaml:"function"`
+	MetricType string `yaml:"metric_type"`
+}
+
+// percentOfParser is a struct to aid the automatic
+// parsing of a collection yaml file
+type percentOfParser struct {
+	Used            string `yaml:"used"`
+	Total           string `yaml:"total"`
+	AllocationUnits string `yaml:"allocation_units"`
 }
 
 // indexParser is a struct to aid the automatic
 // parsing of a collection yaml file
 type indexParser struct {
-	Oid  string `yaml:"oid"`
-	Name string `yaml:"metric_name"`
+	Oid       string `yaml:"oid"`
+	Name      string `yaml:"metric_name"`
+	Transform string `yaml:"transform"`
+	CacheTTL  string `yaml:"cache_ttl"`
+}
+
+// indexComponentParser is a struct to aid the automatic
+// parsing of a collection yaml file
+type indexComponentParser struct {
+	Name   string `yaml:"name"`
+	Type   string `yaml:"type"`
+	Length int    `yaml:"length"`
+}
+
+// indexTemplateParser is a struct to aid the automatic
+// parsing of a collection yaml file
+type indexTemplateParser struct {
+	Name   string `yaml:"name"`
+	Format string `yaml:"format"`
+}
+
+// defaultsParser is a struct to aid the automatic
+// parsing of a collection yaml file
+type defaultsParser struct {
+	MetricType   string   `yaml:"metric_type"`
+	Scale        *float64 `yaml:"scale"`
+	NullPolicy   string   `yaml:"null_policy"`
+	DefaultValue string   `yaml:"default_value"`
+	Timeout      string   `yaml:"timeout"`
 }
 
 // inventoryParser is a struct to aid the automatic
@@ -60,17 +203,46 @@ type collection struct {
 	Device     string
 	MetricSets []metricSet
 	Inventory  []inventoryItem
+	// TypeConversions are the metric types of the metrics without a metric_type, by PDU type
+	TypeConversions map[gosnmp.Asn1BER]metricSourceType
 }
 
 // metricSet is a validated and simplified
 // representation of the requested dataset
 type metricSet struct {
-	Name      string
-	Type      string
-	EventType string
-	Metrics   []*metricDef
-	RootOid   string
-	Index     []*index
+	Name            string
+	Type            string
+	EventType       string
+	Metrics         []*metricDef
+	RootOid         string
+	Augments        string
+	Index           []*index
+	RowFilter       *rowFilter
+	IndexComponents []*indexComponent
+	IndexTemplate   *indexTemplate
+	MaxRows         int
+	// PageSize is the number of rows requested per GETBULK when walking the table
+	PageSize int
+	// Timeout of the requests of the metric set, 0 for the timeout of the connection
+	Timeout time.Duration
+	// Disabled metric sets are parsed, and can be augmented, but are not collected
+	Disabled bool
+	// CollectAllColumns reports every column found under RootOid, not just the configured metrics
+	CollectAllColumns bool
+	// RowTags are static attributes added to the matching rows
+	RowTags *rowTags
+	// ColumnTree matches walked OIDs to the index and metric columns
+	ColumnTree *oidTree
+	// Output is the shape of the reported table: a metric set per row, or per column when pivoted
+	Output string
+	// Derived metrics are computed from the values of the other metrics of a row or scalar set
+	Derived []*derivedMetric
+	// DiscontinuityOid is the column whose changes discard the rate baselines of a row
+	DiscontinuityOid string
+	// Aggregates summarize columns across the rows of a table
+	Aggregates []*aggregate
+	// Attributes are templates rendered from the values of each reported metric set
+	Attributes []*attributeTemplate
 }
 
 // metricDef is a storage struct containing
@@ -80,13 +252,71 @@ type metricDef struct {
 	oid        string
 	metricName string
 	metricType metricSourceType
+	format     string
+	bits       map[int]string
+
+	nullPolicy   nullPolicy
+	defaultValue string
+	// defaultNumber is defaultValue parsed once for the metrics reported as numbers
+	defaultNumber float64
+	// cacheTTL is how long the walked values of a table column are reused before it is walked again
+	cacheTTL time.Duration
+	// fallbackOid is reported under the same name when oid has no value
+	fallbackOid string
+	// extract pulls the number to report out of a string value
+	extract *regexp.Regexp
+	// values maps enumeration codes to labels, emitCode also reports the raw code
+	values   map[int]string
+	emitCode bool
+	// scale and offset convert numeric values before they are reported, a scale of 0 is no scaling
+	scale  float64
+	offset float64
+	// unit is reported along the metric as the `<metricName>Unit` attribute
+	unit string
+	// mask and shift extract a packed field from integer values, a mask of 0 is no mask
+	mask  uint64
+	shift uint
+	// lookup maps values to the label reported as the lookupAttribute attribute
+	lookup          map[string]string
+	lookupAttribute string
+	// hidden metrics are collected for derived metrics but not reported
+	hidden bool
+	// precision is the number of decimals float values are rounded to, nil for float_precision
+	precision *int
+}
+
+// derivedMetric is a storage struct containing
+// a metric computed from the other metrics of a set
+type derivedMetric struct {
+	metricName string
+	expression expression
+	metricType metricSourceType
+	percentOf  *percentOf
+}
+
+// aggregate is a storage struct containing a summary of a column
+// across the rows of a table. column is nil when counting rows
+type aggregate struct {
+	metricName string
+	column     *metricDef
+	function   string
+	metricType metricSourceType
+}
+
+// percentOf holds the column OIDs of a utilization computed as used/total
+type percentOf struct {
+	used            string
+	total           string
+	allocationUnits string
 }
 
 // index is a storage struct containing
 // the information representing a table index
 type index struct {
-	oid  string
-	name string
+	oid       string
+	name      string
+	transform indexTransform
+	cacheTTL  time.Duration
 }
 
 // inventoryItem is a storage struct containing
@@ -105,40 +335,388 @@ var (
 		"delta":     delta,
 		"attribute": attribute,
 		"rate":      rate,
+		"pdelta":    pdelta,
+		"prate":     prate,
 	}
 )
 
+var (
+	// metricFormats are the accepted values of a metric `format`
+	metricFormats = map[string]bool{
+		"bits":          true,
+		"uptime":        true,
+		"date_and_time": true,
+		"mac":           true,
+		"reverse_dns":   true,
+		"hex":           true,
+		"base64":        true,
+		"length":        true,
+		"present":       true,
+		"numeric":       true,
+		"truthvalue":    true,
+	}
+
+	// nullPolicies maps the string used in yaml to a null policy
+	nullPolicies = map[string]nullPolicy{
+		"skip":    nullSkip,
+		"zero":    nullZero,
+		"default": nullDefault,
+		"flag":    nullFlag,
+	}
+)
+
+// nullPolicy decides what is reported for a metric whose value is Null,
+// NoSuchObject, NoSuchInstance or absent from the response. Metrics are skipped by default
+type nullPolicy int
+
+const (
+	nullSkip    nullPolicy = 0
+	nullZero    nullPolicy = 1
+	nullDefault nullPolicy = 2
+	nullFlag    nullPolicy = 3
+)
+
+// metricSourceType is the configured metric_type of a metric. Metrics without
+// one are reported as gauges, auto infers the type from the PDU type
 type metricSourceType int
 
 const (
+	unset     metricSourceType = 0
 	auto      metricSourceType = 1
 	gauge     metricSourceType = 2
 	delta     metricSourceType = 3
 	rate      metricSourceType = 4
 	attribute metricSourceType = 5
+	pdelta    metricSourceType = 6
+	prate     metricSourceType = 7
 )
 
+// parseMetricType validates a metric_type, suggesting the closest accepted
+// type for a typo
+func parseMetricType(name string) (metricSourceType, error) {
+	name = strings.TrimSpace(name)
+	if mt, ok := metricTypes[strings.ToLower(name)]; ok {
+		return mt, nil
+	}
+	var accepted []string
+	closest, distance := "", 3
+	for t := range metricTypes {
+		accepted = append(accepted, t)
+		if d := editDistance(strings.ToLower(name), t); d < distance {
+			closest, distance = t, d
+		}
+	}
+	sort.Strings(accepted)
+	if closest != "" {
+		return 0, fmt.Errorf("Invalid metric type %s, did you mean %s? Accepted types are %s", name, closest, strings.Join(accepted, ", "))
+	}
+	return 0, fmt.Errorf("Invalid metric type %s, accepted types are %s", name, strings.Join(accepted, ", "))
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
 // parseYaml reads a yaml file and parses it into a collectionParser.
-// It validates syntax only and not content
+// It validates syntax and schema only and not content. Errors are returned
+// for the callers to report, each of them once
 func parseYaml(filename string) (*collectionParser, error) {
 	// Read the file
 	yamlFile, err := ioutil.ReadFile(filename)
 	if err != nil {
-		log.Error("Failed to open %s: %s", filename, err)
 		return nil, err
 	}
-	// Parse the file
-	var c collectionParser
-	if err := yaml.Unmarshal(yamlFile, &c); err != nil {
-		log.Error("Failed to parse collection: %s", err)
+	// Upgrade the files of older format versions, nri-snmp -migrate_config upgrades them on disk
+	yamlFile, version, err := migrateFormat(yamlFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if version < currentFormatVersion {
+		log.Debug("%s is read as format_version %d and upgraded to %d", filename, version, currentFormatVersion)
+	}
+	yamlFile, err = expandVariables(yamlFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand the variables of %s: %v", filename, err)
+	}
+	src := yamlFile
+	isJSON := strings.EqualFold(filepath.Ext(filename), ".json")
+	if isJSON {
+		yamlFile, err = jsonToYaml(yamlFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the JSON collection %s: %v", filename, err)
+		}
+	}
+	// Parse the file, rejecting the keys and values outside of the schema
+	c, err := unmarshalCollection(filename, src, yamlFile, isJSON)
+	if err != nil {
 		return nil, err
 	}
-	return &c, nil
+	applyDefaults(c)
+	return c, nil
+}
+
+// applyDefaults sets the defaults of a collection file on its own metric sets,
+// metric groups and metrics, before they are merged with the ones of other
+// files. A metric without a null_policy takes both the default null_policy
+// and default_value
+func applyDefaults(c *collectionParser) {
+	defaults := c.Defaults
+	if defaults == nil {
+		return
+	}
+	apply := func(metricSets []metricSetParser) {
+		for i := range metricSets {
+			metricSet := &metricSets[i]
+			if metricSet.Timeout == "" {
+				metricSet.Timeout = defaults.Timeout
+			}
+			for j := range metricSet.Metrics {
+				metric := &metricSet.Metrics[j]
+				if metric.MetricType == "" {
+					metric.MetricType = defaults.MetricType
+				}
+				if metric.Scale == nil {
+					metric.Scale = defaults.Scale
+				}
+				if metric.NullPolicy == "" {
+					metric.NullPolicy = defaults.NullPolicy
+					if metric.DefaultValue == "" {
+						metric.DefaultValue = defaults.DefaultValue
+					}
+				}
+			}
+		}
+	}
+	apply(c.MetricSets)
+	for name, metrics := range c.MetricGroups {
+		group := []metricSetParser{{Metrics: metrics}}
+		apply(group)
+		c.MetricGroups[name] = group[0].Metrics
+	}
+	for i := range c.Collect {
+		apply(c.Collect[i].MetricSets)
+	}
+}
+
+// jsonToYaml converts a collection defined in JSON to the YAML it is parsed
+// from, so both share the same field names. Syntax errors carry the line of
+// the JSON file
+func jsonToYaml(src []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(src))
+	//numbers are kept exact, masks do not fit a float64
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		if syntaxError, ok := err.(*json.SyntaxError); ok {
+			line := bytes.Count(src[:syntaxError.Offset], []byte("\n")) + 1
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		return nil, err
+	}
+	return yaml.Marshal(jsonNumbers(value))
+}
+
+// jsonNumbers replaces the numbers of a decoded JSON value by integers, when
+// they are, or floats. Object keys holding integers, such as the codes of
+// `values`, become integers, as they are in YAML
+func jsonNumbers(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		mapping := make(map[interface{}]interface{}, len(value))
+		for key, element := range value {
+			if number, err := strconv.Atoi(key); err == nil && strconv.Itoa(number) == key {
+				mapping[number] = jsonNumbers(element)
+				continue
+			}
+			mapping[key] = jsonNumbers(element)
+		}
+		return mapping
+	case []interface{}:
+		for i, element := range value {
+			value[i] = jsonNumbers(element)
+		}
+	case json.Number:
+		if number, err := value.Int64(); err == nil {
+			return number
+		}
+		if number, err := strconv.ParseUint(value.String(), 10, 64); err == nil {
+			return number
+		}
+		number, _ := value.Float64()
+		return number
+	}
+	return value
+}
+
+// parseMetric validates the definition of a single metric
+func parseMetric(metricParser metricParser) (*metricDef, error) {
+	//oids are resolved to absolute numeric oids starting with a leading dot, as required by gosnmp
+	metricOid, err := resolveOid(metricParser.Oid)
+	if err != nil {
+		return nil, err
+	}
+	newMetric := &metricDef{
+		metricName: metricParser.MetricName,
+		oid:        metricOid,
+		bits:       metricParser.Bits,
+		values:     metricParser.Values,
+		emitCode:   metricParser.EmitCode,
+		offset:     metricParser.Offset,
+		shift:      metricParser.Shift,
+		unit:       strings.TrimSpace(metricParser.Unit),
+	}
+	metricTypeString := metricParser.MetricType
+	if metricTypeString == "" {
+		newMetric.metricType = unset
+	} else {
+		mt, err := parseMetricType(metricTypeString)
+		if err != nil {
+			return nil, fmt.Errorf("%v for metric %s", err, metricOid)
+		}
+		newMetric.metricType = mt
+	}
+	if format := strings.TrimSpace(metricParser.Format); format != "" {
+		if !metricFormats[format] {
+			return nil, fmt.Errorf("Invalid format %s for metric %s", format, metricOid)
+		}
+		newMetric.format = format
+	}
+	if policy := strings.TrimSpace(metricParser.NullPolicy); policy != "" {
+		np, ok := nullPolicies[policy]
+		if !ok {
+			return nil, fmt.Errorf("Invalid null_policy %s for metric %s", policy, metricOid)
+		}
+		newMetric.nullPolicy = np
+	}
+	newMetric.defaultValue = metricParser.DefaultValue
+	if newMetric.nullPolicy == nullDefault && newMetric.defaultValue == "" {
+		return nil, fmt.Errorf("Metric %s has null_policy default but no default_value", metricOid)
+	}
+	if newMetric.defaultValue != "" && newMetric.metricType != attribute {
+		number, err := strconv.ParseFloat(strings.TrimSpace(newMetric.defaultValue), 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid default_value %s for metric %s of metric_type %s, a number is expected", newMetric.defaultValue, metricOid, metricTypeString)
+		}
+		newMetric.defaultNumber = number
+	}
+	if metricParser.Precision != nil {
+		if *metricParser.Precision < 0 {
+			return nil, fmt.Errorf("Invalid precision %d for metric %s", *metricParser.Precision, metricOid)
+		}
+		newMetric.precision = metricParser.Precision
+	}
+	if file := strings.TrimSpace(metricParser.LookupFile); file != "" {
+		lookup, err := loadLookupFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid lookup_file for metric %s: %v", metricOid, err)
+		}
+		newMetric.lookup = lookup
+		newMetric.lookupAttribute = strings.TrimSpace(metricParser.LookupAttribute)
+	} else if metricParser.LookupAttribute != "" {
+		return nil, fmt.Errorf("Metric %s has a lookup_attribute but no lookup_file", metricOid)
+	}
+	if metricParser.Mask != nil {
+		if *metricParser.Mask == 0 {
+			return nil, fmt.Errorf("Invalid mask 0 for metric %s", metricOid)
+		}
+		newMetric.mask = *metricParser.Mask
+	}
+	if metricParser.Shift >= 64 {
+		return nil, fmt.Errorf("Invalid shift %d for metric %s", metricParser.Shift, metricOid)
+	}
+	if metricParser.Scale != nil {
+		if *metricParser.Scale == 0 {
+			return nil, fmt.Errorf("Invalid scale 0 for metric %s", metricOid)
+		}
+		newMetric.scale = *metricParser.Scale
+	}
+	if extract := metricParser.Extract; extract != "" {
+		if newMetric.format != "" && newMetric.format != "numeric" {
+			return nil, fmt.Errorf("Metric %s can't combine extract with format %s", metricOid, newMetric.format)
+		}
+		re, err := regexp.Compile(extract)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid extract expression for metric %s: %v", metricOid, err)
+		}
+		newMetric.format, newMetric.extract = "numeric", re
+	}
+	if fallbackOid := strings.TrimSpace(metricParser.FallbackOid); fallbackOid != "" {
+		newMetric.fallbackOid, err = resolveOid(fallbackOid)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid fallback_oid for metric %s: %v", metricOid, err)
+		}
+	}
+	cacheTTL, err := parseDuration(metricParser.CacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid cache_ttl for metric %s: %v", metricOid, err)
+	}
+	newMetric.cacheTTL = cacheTTL
+	if newMetric.values == nil && newMetric.usesMibEnumeration() {
+		newMetric.values = mibs.enumeration(metricOid)
+	}
+	mibs.applyHints(newMetric)
+	if newMetric.format == "bits" && len(newMetric.bits) == 0 {
+		if node := mibs.node(metricOid); node != nil {
+			newMetric.bits = node.syntax.namedNumbers
+		}
+		if len(newMetric.bits) == 0 {
+			return nil, fmt.Errorf("Metric %s has format bits but no bit names are configured or found in the MIBs", metricOid)
+		}
+	}
+	return newMetric, nil
+}
+
+// usesMibEnumeration reports whether the labels of an enumerated INTEGER
+// defined in the MIBs are reported for the metric when it configures no
+// `values`: it must report the value as is, as an attribute or without a
+// numeric metric_type. An empty `values` map keeps the raw codes
+func (def *metricDef) usesMibEnumeration() bool {
+	switch def.metricType {
+	case unset, auto, attribute:
+	default:
+		return false
+	}
+	return def.format == "" && def.mask == 0 && def.shift == 0 && def.lookup == nil && !def.isScaled()
 }
 
 // parseCollection takes a raw collectionParser and returns
 // an slice of metricSetDefinition objects containing the validated configuration
 func parseCollection(c *collectionParser) ([]*collection, error) {
+	if c.Enabled != nil && !*c.Enabled {
+		log.Debug("skipping a collection file with enabled: false")
+		return nil, nil
+	}
+	conversions, err := parseTypeConversions(c.TypeConversions)
+	if err != nil {
+		return nil, err
+	}
 	var cols []*collection
 	var metricSets []metricSet
 	var inventory []inventoryItem
@@ -151,63 +729,462 @@ func parseCollection(c *collectionParser) ([]*collection, error) {
 			metricParsers := metricSetParser.Metrics
 			var metrics []*metricDef
 			for _, metricParser := range metricParsers {
-				metricOid := strings.TrimSpace(metricParser.Oid)
-				//force all oids to start with a leading dot indicating abolute oids as required by gosnmp
-				if !strings.HasPrefix(metricOid, ".") {
-					metricOid = "." + metricOid
-				}
-				newMetric := &metricDef{
-					metricName: metricParser.MetricName,
-					oid:        metricOid,
-				}
-				metricTypeString := metricParser.MetricType
-				if metricTypeString == "" {
-					newMetric.metricType = auto
-				} else {
-					mt, ok := metricTypes[metricTypeString]
-					if !ok {
-						return nil, fmt.Errorf("Invalid metric type %s", metricTypeString)
-					}
-					newMetric.metricType = mt
+				newMetric, err := parseMetric(metricParser)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid metric of metric set %s: %v", name, err)
 				}
 				metrics = append(metrics, newMetric)
 			}
+			if metricSetType == "scalar" {
+				for _, metric := range metrics {
+					if metric.cacheTTL > 0 {
+						return nil, fmt.Errorf("cache_ttl of metric %s of metric set %s is only supported by table metric sets", metric.oid, name)
+					}
+				}
+			}
+			var err error
 			var indexes []*index
 			indexParsers := metricSetParser.Index
 			for _, indexParser := range indexParsers {
-				indexOid := strings.TrimSpace(indexParser.Oid)
-				//force all oids to start with a leading dot indicating abolute oids as required by gosnmp
-				if !strings.HasPrefix(indexOid, ".") {
-					indexOid = "." + indexOid
+				indexOid, err := resolveOid(indexParser.Oid)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid index of metric set %s: %v", name, err)
+				}
+				if strings.TrimSpace(indexParser.Name) == "index" {
+					log.Warn("index %s of metric set %s is named `index`, which is reserved for the raw index key", indexOid, name)
 				}
 				newIndex := &index{
 					name: indexParser.Name,
-					oid:  indexParser.Oid,
+					oid:  indexOid,
+				}
+				if transformName := strings.TrimSpace(indexParser.Transform); transformName != "" {
+					transform, ok := indexTransforms[transformName]
+					if !ok {
+						return nil, fmt.Errorf("Invalid transform %s for index %s of metric set %s", transformName, indexOid, name)
+					}
+					newIndex.transform = transform
+				}
+				newIndex.cacheTTL, err = parseDuration(indexParser.CacheTTL)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid cache_ttl for index %s of metric set %s: %v", indexOid, name, err)
 				}
 				indexes = append(indexes, newIndex)
 			}
+			rowFilter, err := parseRowFilter(metricSetParser.Indexes)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid indexes for metric set %s: %v", name, err)
+			}
+			indexComponents, indexTemplate, err := parseIndexComponents(metricSetParser)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid index components for metric set %s: %v", name, err)
+			}
+			rowTags, err := parseRowTags(metricSetParser)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid row tags for metric set %s: %v", name, err)
+			}
+			output := strings.TrimSpace(metricSetParser.Output)
+			switch output {
+			case "":
+				output = outputRows
+			case outputRows, outputPivot:
+			default:
+				return nil, fmt.Errorf("Invalid output %s for metric set %s, valid values are rows and pivot", output, name)
+			}
+			if metricSetParser.MaxRows < 0 {
+				return nil, fmt.Errorf("Invalid max_rows %d for metric set %s", metricSetParser.MaxRows, name)
+			}
+			if metricSetParser.PageSize < 0 || metricSetParser.PageSize > 255 {
+				return nil, fmt.Errorf("Invalid page_size %d for metric set %s, valid values are 1 to 255", metricSetParser.PageSize, name)
+			}
+			timeout, err := parseDuration(metricSetParser.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid timeout for metric set %s: %v", name, err)
+			}
 			rootOID := strings.TrimSpace(metricSetParser.RootOid)
+			if rootOID != "" {
+				rootOID, err = resolveOid(rootOID)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid root_oid for metric set %s: %v", name, err)
+				}
+			}
+			if table := strings.TrimSpace(metricSetParser.Table); table != "" {
+				rootOID, indexes, metrics, err = mergeMibTable(table, rootOID, indexes, metrics)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid table for metric set %s: %v", name, err)
+				}
+			}
+			if metricSetType == "table" && len(indexes) == 0 && len(metrics) == 0 {
+				return nil, fmt.Errorf("Neither index nor metrics specified for table metric set %s", name)
+			}
+			derived, metrics, err := parseDerivedMetrics(metricSetParser.Derived, metrics)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid derived metrics for metric set %s: %v", name, err)
+			}
+			attributes, err := parseAttributeTemplates(metricSetParser.Attributes)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid attributes for metric set %s: %v", name, err)
+			}
+			aggregates, metrics, err := parseAggregates(metricSetParser.Aggregates, metrics)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid aggregates for metric set %s: %v", name, err)
+			}
+			if len(aggregates) > 0 && metricSetType != "table" {
+				return nil, fmt.Errorf("aggregates of metric set %s are only supported by table metric sets", name)
+			}
+			if rowTags != nil && rowTags.key != "" && metricSetParser.Augments == "" && !hasIndexValue(rowTags.key, indexes, indexComponents) {
+				return nil, fmt.Errorf("row_tags_key %s of metric set %s is not an index of the table", rowTags.key, name)
+			}
 			newMetricSet = metricSet{
-				Name:      name,
-				Type:      metricSetType,
-				EventType: eventType,
-				Metrics:   metrics,
-				RootOid:   rootOID,
-				Index:     indexes,
+				Name:              name,
+				Type:              metricSetType,
+				EventType:         eventType,
+				Metrics:           metrics,
+				RootOid:           rootOID,
+				Augments:          strings.TrimSpace(metricSetParser.Augments),
+				Index:             indexes,
+				RowFilter:         rowFilter,
+				MaxRows:           metricSetParser.MaxRows,
+				PageSize:          metricSetParser.PageSize,
+				Timeout:           timeout,
+				Disabled:          metricSetParser.Enabled != nil && !*metricSetParser.Enabled,
+				IndexComponents:   indexComponents,
+				IndexTemplate:     indexTemplate,
+				CollectAllColumns: metricSetParser.CollectAllColumns,
+				RowTags:           rowTags,
+				Output:            output,
+				Derived:           derived,
+				Aggregates:        aggregates,
+				Attributes:        attributes,
+			}
+			if discontinuityOid := strings.TrimSpace(metricSetParser.DiscontinuityOid); discontinuityOid != "" {
+				newMetricSet.DiscontinuityOid, err = resolveOid(discontinuityOid)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid discontinuity_oid for metric set %s: %v", name, err)
+				}
 			}
 			metricSets = append(metricSets, newMetricSet)
 		}
+		if err := resolveAugments(metricSets); err != nil {
+			return nil, err
+		}
+		buildColumnTrees(metricSets)
+		var enabledSets []metricSet
+		for _, metricSet := range metricSets {
+			if metricSet.Disabled {
+				log.Debug("skipping metric set %s with enabled: false", metricSet.Name)
+				continue
+			}
+			enabledSets = append(enabledSets, metricSet)
+		}
 
 		for _, inventoryParser := range dataSet.Inventory {
+			oid := strings.TrimSpace(inventoryParser.Oid)
+			if strings.Trim(oid, ".0123456789") != "" {
+				resolved, err := resolveOid(oid)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid inventory item %s: %v", inventoryParser.Name, err)
+				}
+				oid = resolved
+			}
 			newInventoryItem := inventoryItem{
-				oid:      inventoryParser.Oid,
+				oid:      oid,
 				category: inventoryParser.Category,
 				name:     inventoryParser.Name,
 			}
 			inventory = append(inventory, newInventoryItem)
 		}
-		col := collection{Device: dataSet.Device, MetricSets: metricSets, Inventory: inventory}
+		col := collection{Device: dataSet.Device, MetricSets: enabledSets, Inventory: inventory, TypeConversions: conversions}
 		cols = append(cols, &col)
 	}
 	return cols, nil
 }
+
+// parseTypeConversions validates the type_conversions of a collection file,
+// mapping PDU type names such as Counter64 to metric types
+func parseTypeConversions(conversions map[string]string) (map[gosnmp.Asn1BER]metricSourceType, error) {
+	if len(conversions) == 0 {
+		return nil, nil
+	}
+	types := make(map[gosnmp.Asn1BER]metricSourceType)
+	for typeName, metricType := range conversions {
+		pduType, ok := pduTypeByName(typeName)
+		if !ok {
+			return nil, fmt.Errorf("Invalid PDU type %s in type_conversions", typeName)
+		}
+		mt, err := parseMetricType(metricType)
+		if err != nil {
+			return nil, fmt.Errorf("%v for %s in type_conversions", err, typeName)
+		}
+		types[pduType] = mt
+	}
+	return types, nil
+}
+
+// mergeMibTable completes a table metric set from the MIB definition of the
+// named table. Configured root OID, indexes and metrics take precedence over
+// the ones derived from the MIB
+func mergeMibTable(table string, rootOID string, indexes []*index, metrics []*metricDef) (string, []*index, []*metricDef, error) {
+	if mibs == nil {
+		return "", nil, nil, fmt.Errorf("table %s referenced by name but no MIBs are loaded, set mib_dirs", table)
+	}
+	tableOid, mibIndexes, mibMetrics, err := mibs.tableDefinition(table)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if rootOID == "" {
+		rootOID = tableOid
+	}
+	if len(indexes) == 0 {
+		indexes = mibIndexes
+	}
+	configured := make(map[string]bool)
+	for _, metric := range metrics {
+		configured[metric.oid] = true
+	}
+	for _, metric := range mibMetrics {
+		if !configured[metric.oid] {
+			metrics = append(metrics, metric)
+		}
+	}
+	return rootOID, indexes, metrics, nil
+}
+
+// parseDerivedMetrics compiles the expressions of the derived metrics of a
+// set, which can only reference the named metrics of the same set. The OIDs
+// used by percent_of are collected as hidden metrics when not configured
+func parseDerivedMetrics(parsers []derivedParser, metrics []*metricDef) ([]*derivedMetric, []*metricDef, error) {
+	names := make(map[string]bool)
+	oids := make(map[string]bool)
+	for _, metric := range metrics {
+		if metric.metricName != "" {
+			names[metric.metricName] = true
+		}
+		oids[metric.oid] = true
+	}
+	var derived []*derivedMetric
+	for _, p := range parsers {
+		name := strings.TrimSpace(p.MetricName)
+		if name == "" {
+			return nil, nil, fmt.Errorf("derived metric without metric_name")
+		}
+		metricType := gauge
+		if p.MetricType != "" {
+			mt, err := parseMetricType(p.MetricType)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%v for derived metric %s", err, name)
+			}
+			if mt == attribute {
+				return nil, nil, fmt.Errorf("Invalid metric type %s for derived metric %s", p.MetricType, name)
+			}
+			metricType = mt
+		}
+		d := &derivedMetric{metricName: name, metricType: metricType}
+		if p.PercentOf != nil {
+			if p.Expression != "" {
+				return nil, nil, fmt.Errorf("derived metric %s can't have both an expression and percent_of", name)
+			}
+			if strings.TrimSpace(p.PercentOf.Used) == "" || strings.TrimSpace(p.PercentOf.Total) == "" {
+				return nil, nil, fmt.Errorf("percent_of of derived metric %s requires used and total", name)
+			}
+			used, err := resolveOid(p.PercentOf.Used)
+			if err != nil {
+				return nil, nil, fmt.Errorf("percent_of of derived metric %s: %v", name, err)
+			}
+			total, err := resolveOid(p.PercentOf.Total)
+			if err != nil {
+				return nil, nil, fmt.Errorf("percent_of of derived metric %s: %v", name, err)
+			}
+			d.percentOf = &percentOf{used: used, total: total}
+			if strings.TrimSpace(p.PercentOf.AllocationUnits) != "" {
+				d.percentOf.allocationUnits, err = resolveOid(p.PercentOf.AllocationUnits)
+				if err != nil {
+					return nil, nil, fmt.Errorf("percent_of of derived metric %s: %v", name, err)
+				}
+			}
+			for _, oid := range []string{d.percentOf.used, d.percentOf.total, d.percentOf.allocationUnits} {
+				if oid != "" && !oids[oid] {
+					oids[oid] = true
+					metrics = append(metrics, &metricDef{oid: oid, metricType: gauge, hidden: true})
+				}
+			}
+		} else {
+			expr, variables, err := parseExpression(p.Expression)
+			if err != nil {
+				return nil, nil, fmt.Errorf("derived metric %s: %v", name, err)
+			}
+			for _, variable := range variables {
+				if !names[variable] {
+					return nil, nil, fmt.Errorf("derived metric %s references unknown metric %s", name, variable)
+				}
+			}
+			d.expression = expr
+		}
+		derived = append(derived, d)
+	}
+	return derived, metrics, nil
+}
+
+// parseAggregates validates the aggregates of a table. A column is referred to
+// by metric name or OID, columns that are not metrics of the table are
+// collected as hidden metrics
+func parseAggregates(parsers []aggregateParser, metrics []*metricDef) ([]*aggregate, []*metricDef, error) {
+	var aggregates []*aggregate
+	for _, p := range parsers {
+		name := strings.TrimSpace(p.MetricName)
+		if name == "" {
+			return nil, nil, fmt.Errorf("aggregate without metric_name")
+		}
+		function := strings.TrimSpace(p.Function)
+		if !aggregateFunctions[function] {
+			return nil, nil, fmt.Errorf("Invalid function %s for aggregate %s, valid values are sum, min, max, avg and count", function, name)
+		}
+		a := &aggregate{metricName: name, function: function, metricType: gauge}
+		if p.MetricType != "" {
+			mt, err := parseMetricType(p.MetricType)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%v for aggregate %s", err, name)
+			}
+			if mt == attribute {
+				return nil, nil, fmt.Errorf("Invalid metric type %s for aggregate %s", p.MetricType, name)
+			}
+			a.metricType = mt
+		}
+		column := strings.TrimSpace(p.Column)
+		if column == "" {
+			if function != "count" {
+				return nil, nil, fmt.Errorf("aggregate %s requires a column", name)
+			}
+			aggregates = append(aggregates, a)
+			continue
+		}
+		oid, err := resolveOid(column)
+		for _, metric := range metrics {
+			if metric.metricName == column || (err == nil && metric.oid == oid) {
+				a.column = metric
+				break
+			}
+		}
+		if a.column == nil {
+			if err != nil {
+				return nil, nil, fmt.Errorf("aggregate %s references unknown column %s", name, column)
+			}
+			a.column = &metricDef{oid: oid, metricType: gauge, hidden: true}
+			metrics = append(metrics, a.column)
+		}
+		aggregates = append(aggregates, a)
+	}
+	return aggregates, metrics, nil
+}
+
+// hasIndexValue tells whether name is an index or index component of a table
+func hasIndexValue(name string, indexes []*index, components []*indexComponent) bool {
+	for _, index := range indexes {
+		if index.name == name {
+			return true
+		}
+	}
+	for _, component := range components {
+		if component.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDuration parses an optional positive duration with its unit, such as
+// `30s` or `1h30m`. Bare numbers are rejected as their unit is ambiguous
+func parseDuration(duration string) (time.Duration, error) {
+	duration = strings.TrimSpace(duration)
+	if duration == "" {
+		return 0, nil
+	}
+	if _, err := strconv.ParseFloat(duration, 64); err == nil {
+		return 0, fmt.Errorf("%s has no unit, such as %ss", duration, duration)
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s is not a positive duration", duration)
+	}
+	return d, nil
+}
+
+// parseIndexComponents validates the composite index components and the
+// index template of a table metric set
+func parseIndexComponents(p metricSetParser) ([]*indexComponent, *indexTemplate, error) {
+	var components []*indexComponent
+	for _, componentParser := range p.IndexComponents {
+		name := strings.TrimSpace(componentParser.Name)
+		if name == "" {
+			return nil, nil, fmt.Errorf("index component without name")
+		}
+		componentType := strings.TrimSpace(componentParser.Type)
+		if componentType == "" {
+			componentType = "integer"
+		}
+		decoder, ok := indexDecoders[componentType]
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid type %s for index component %s", componentType, name)
+		}
+		if componentParser.Length < 0 {
+			return nil, nil, fmt.Errorf("invalid length %d for index component %s", componentParser.Length, name)
+		}
+		components = append(components, &indexComponent{name: name, decoder: decoder, length: componentParser.Length})
+	}
+	if p.IndexTemplate == nil {
+		return components, nil, nil
+	}
+	template := &indexTemplate{
+		name:   strings.TrimSpace(p.IndexTemplate.Name),
+		format: p.IndexTemplate.Format,
+	}
+	if template.name == "" || template.format == "" {
+		return nil, nil, fmt.Errorf("index_template requires both name and format")
+	}
+	return components, template, nil
+}
+
+// buildColumnTrees builds, once per configuration, the prefix trees
+// extracting the index key from the OIDs walked under a table
+func buildColumnTrees(metricSets []metricSet) {
+	for i := range metricSets {
+		if metricSets[i].Type == "table" {
+			metricSets[i].ColumnTree = newOidTree(tableColumns(metricSets[i]))
+		}
+	}
+}
+
+// resolveAugments makes every table metric set declaring `augments` share the
+// index definitions of the metric set it augments, as an AUGMENTS table shares
+// the INDEX of its base table. Definitions of the augmenting metric set win
+func resolveAugments(metricSets []metricSet) error {
+	byName := make(map[string]*metricSet)
+	for i := range metricSets {
+		byName[metricSets[i].Name] = &metricSets[i]
+	}
+	for i := range metricSets {
+		ms := &metricSets[i]
+		if ms.Augments == "" {
+			continue
+		}
+		base, ok := byName[ms.Augments]
+		if !ok {
+			return fmt.Errorf("Metric set %s augments unknown metric set %s", ms.Name, ms.Augments)
+		}
+		if base.Augments != "" {
+			return fmt.Errorf("Metric set %s augments %s, which itself augments %s", ms.Name, base.Name, base.Augments)
+		}
+		if len(ms.Index) == 0 {
+			ms.Index = base.Index
+		}
+		if len(ms.IndexComponents) == 0 {
+			ms.IndexComponents = base.IndexComponents
+		}
+		if ms.IndexTemplate == nil {
+			ms.IndexTemplate = base.IndexTemplate
+		}
+	}
+	return nil
+}
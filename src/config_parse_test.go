@@ -0,0 +1,265 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/soniah/gosnmp"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestParseMetricDefaultValue(t *testing.T) {
+	cases := []struct {
+		metricType   string
+		defaultValue string
+		valid        bool
+	}{
+		{"gauge", "-1", true},
+		{"rate", "0.5", true},
+		{"gauge", "n/a", false},
+		{"delta", "", false},
+		{"attribute", "n/a", true},
+		{"", "unknown", false},
+		{"auto", "n/a", false},
+		{"", " 5 ", true},
+	}
+	for _, c := range cases {
+		parser := metricParser{MetricName: "value", Oid: ".1.3.6.1.4.1.9.9.91.1.1.1.1.4", MetricType: c.metricType, NullPolicy: "default", DefaultValue: c.defaultValue}
+		if _, err := parseMetric(parser); (err == nil) != c.valid {
+			t.Errorf("default_value %q of a %q metric: unexpected error %v", c.defaultValue, c.metricType, err)
+		}
+	}
+
+	def, err := parseMetric(metricParser{MetricName: "value", Oid: ".1.3.6.1.4.1.9.9.91.1.1.1.1.4", NullPolicy: "default", DefaultValue: " -1 "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := metric.NewSet("TestSample", nil)
+	if err := setNullMetric(def, "value", ms); err != nil {
+		t.Fatal(err)
+	}
+	if ms.Metrics["value"] != float64(-1) {
+		t.Errorf("expected the default value -1, got %#v", ms.Metrics["value"])
+	}
+}
+
+func TestParseMetricType(t *testing.T) {
+	if mt, err := parseMetricType("prate"); err != nil || mt != prate {
+		t.Errorf("unexpected %v, %v", mt, err)
+	}
+	_, err := parseMetricType("guage")
+	if err == nil || !strings.Contains(err.Error(), "did you mean gauge?") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestTypeConversions(t *testing.T) {
+	conversions, err := parseTypeConversions(map[string]string{"counter64": "rate", "Gauge32": "attribute"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	typeConversions = conversions
+	defer func() { typeConversions = nil }()
+	if mt := resolveMetricType(unset, gosnmp.Counter64); mt != rate {
+		t.Errorf("unexpected metric type %v for Counter64", mt)
+	}
+	if mt := resolveMetricType(gauge, gosnmp.Counter64); mt != gauge {
+		t.Errorf("configured metric type overridden with %v", mt)
+	}
+	if mt := resolveMetricType(unset, gosnmp.Integer); mt != unset {
+		t.Errorf("unexpected metric type %v for Integer", mt)
+	}
+	if _, err := parseTypeConversions(map[string]string{"Counter128": "rate"}); err == nil {
+		t.Error("expected error for an unknown PDU type")
+	}
+}
+
+func TestJsonCollection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "collections")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "router.json")
+	collection := `{
+	"collect": [{
+		"device": "router",
+		"metric_sets": [{
+			"name": "interfaces",
+			"type": "table",
+			"event_type": "SNMPInterfaceSample",
+			"root_oid": ".1.3.6.1.2.1.2.2",
+			"row_tags": {"1": {"site": "paris"}},
+			"metrics": [
+				{"metric_name": "ifOperStatus", "oid": ".1.3.6.1.2.1.2.2.1.8", "values": {"1": "up", "2": "down"}},
+				{"metric_name": "ifFlags", "oid": ".1.3.6.1.2.1.2.2.1.9", "mask": 18446744073709551615, "scale": 0.5}
+			]
+		}]
+	}]
+}`
+	if err := ioutil.WriteFile(file, []byte(collection), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parser, err := loadCollectionFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := parser.Collect[0].MetricSets[0].RowTags; tags["1"]["site"] != "paris" {
+		t.Errorf("unexpected row tags %v", tags)
+	}
+	metrics := parser.Collect[0].MetricSets[0].Metrics
+	if metrics[0].Values[2] != "down" || *metrics[1].Mask != 18446744073709551615 || *metrics[1].Scale != 0.5 {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("{\n\"collect\": [\n}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCollectionFile(file); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expected a syntax error on line 3, got %v", err)
+	}
+}
+
+func TestDisabledMetricSets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disabled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"router.yml": `collect:
+- device: router
+  metric_sets:
+  - {name: interfaces, type: table, event_type: SNMPInterfaceSample, root_oid: .1.3.6.1.2.1.2.2, enabled: false,
+     index: [{oid: .1.3.6.1.2.1.2.2.1.2, metric_name: ifDescr}], metrics: [{metric_name: ifInOctets, oid: .1.3.6.1.2.1.2.2.1.10}]}
+  - {name: interfacesHC, type: table, event_type: SNMPInterfaceSample, root_oid: .1.3.6.1.2.1.31.1.1, augments: interfaces,
+     metrics: [{metric_name: ifHCInOctets, oid: .1.3.6.1.2.1.31.1.1.1.6}]}
+  - {name: system, type: scalar, event_type: SNMPSample, enabled: true, metrics: [{metric_name: sysName, oid: .1.3.6.1.2.1.1.5.0}]}
+`,
+		"switch.yml": `enabled: false
+collect:
+- device: switch
+  metric_sets:
+  - {name: system, type: scalar, event_type: SNMPSample, metrics: [{metric_name: sysName, oid: .1.3.6.1.2.1.1.5.0}]}
+`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parser, err := loadCollectionFile(filepath.Join(dir, "router.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collections, err := parseCollection(parser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metricSets := collections[0].MetricSets
+	if len(metricSets) != 2 || metricSets[0].Name != "interfacesHC" || len(metricSets[0].Index) != 1 || metricSets[1].Name != "system" {
+		t.Errorf("unexpected metric sets %+v", metricSets)
+	}
+
+	parser, err = loadCollectionFile(filepath.Join(dir, "switch.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collections, err := parseCollection(parser); err != nil || len(collections) != 0 {
+		t.Errorf("expected a disabled collection file not to be collected, got %v %v", collections, err)
+	}
+}
+
+func TestParseAugments(t *testing.T) {
+	source := `collect:
+- device: router
+  metric_sets:
+  - {name: interfaces, type: table, event_type: SNMPInterfaceSample, root_oid: .1.3.6.1.2.1.2.2,
+     index: [{oid: .1.3.6.1.2.1.2.2.1.2, metric_name: ifDescr}], metrics: [{metric_name: ifInOctets, oid: .1.3.6.1.2.1.2.2.1.10}]}
+  - {name: interfacesHC, type: table, event_type: SNMPInterfaceSample, root_oid: .1.3.6.1.2.1.31.1.1, augments: %s,
+     metrics: [{metric_name: ifHCInOctets, oid: .1.3.6.1.2.1.31.1.1.1.6}]}
+`
+	parser := collectionParser{}
+	if err := yaml.Unmarshal([]byte(strings.Replace(source, "%s", "interfaces", 1)), &parser); err != nil {
+		t.Fatal(err)
+	}
+	collections, err := parseCollection(&parser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := collections[0].MetricSets[1]
+	if len(hc.Index) != 1 || hc.Index[0].oid != ".1.3.6.1.2.1.2.2.1.2" || hc.Index[0].name != "ifDescr" {
+		t.Errorf("expected the index of interfaces, got %+v", hc.Index)
+	}
+	if _, indexKey, ok := hc.ColumnTree.match(".1.3.6.1.2.1.2.2.1.2.3"); !ok || indexKey != "3" {
+		t.Errorf("expected the inherited index column to be walked, got %s %v", indexKey, ok)
+	}
+
+	parser = collectionParser{}
+	if err := yaml.Unmarshal([]byte(strings.Replace(source, "%s", "ports", 1)), &parser); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseCollection(&parser); err == nil || !strings.Contains(err.Error(), "augments unknown metric set ports") {
+		t.Errorf("expected an unknown base table error, got %v", err)
+	}
+}
+
+func TestCollectionDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "sensors.yml")
+	collection := `defaults:
+  metric_type: gauge
+  scale: 0.1
+  null_policy: default
+  default_value: "-1"
+  timeout: 30s
+collect:
+- device: sensors
+  metric_sets:
+  - name: temperatures
+    type: table
+    event_type: SNMPSensorSample
+    root_oid: .1.3.6.1.4.1.9.9.91.1.1.1
+    timeout: 5s
+    metrics:
+    - {metric_name: sensorValue, oid: .1.3.6.1.4.1.9.9.91.1.1.1.1.4}
+    - {metric_name: sensorStatus, oid: .1.3.6.1.4.1.9.9.91.1.1.1.1.5, metric_type: attribute, scale: 1, null_policy: skip}
+  - name: system
+    type: scalar
+    event_type: SNMPSample
+    metrics:
+    - {metric_name: sysUpTime, oid: .1.3.6.1.2.1.1.3.0}
+`
+	if err := ioutil.WriteFile(file, []byte(collection), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parser, err := loadCollectionFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collections, err := parseCollection(parser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	temperatures, system := collections[0].MetricSets[0], collections[0].MetricSets[1]
+	value, status := temperatures.Metrics[0], temperatures.Metrics[1]
+	if value.metricType != gauge || value.scale != 0.1 || value.nullPolicy != nullDefault || value.defaultValue != "-1" {
+		t.Errorf("expected the defaults on %+v", value)
+	}
+	if status.metricType != attribute || status.scale != 1 || status.nullPolicy != nullSkip || status.defaultValue != "" {
+		t.Errorf("expected the defaults to be overridden on %+v", status)
+	}
+	if temperatures.Timeout != 5*time.Second || system.Timeout != 30*time.Second {
+		t.Errorf("unexpected timeouts %s and %s", temperatures.Timeout, system.Timeout)
+	}
+}
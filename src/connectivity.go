@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/soniah/gosnmp"
+)
+
+// usmStatHints explain the usmStats counters a target reports when it
+// rejects an SNMPv3 request
+var usmStatHints = map[string]string{
+	".1.3.6.1.6.3.15.1.1.1.0": "the security_level is not supported for the user",
+	".1.3.6.1.6.3.15.1.1.2.0": "the request was outside the time window of the target engine",
+	".1.3.6.1.6.3.15.1.1.3.0": "the username is unknown to the target",
+	".1.3.6.1.6.3.15.1.1.4.0": "the target engine ID is unknown",
+	".1.3.6.1.6.3.15.1.1.5.0": "the auth_protocol or auth_passphrase is wrong",
+	".1.3.6.1.6.3.15.1.1.6.0": "the priv_protocol or priv_passphrase is wrong",
+}
+
+// testConnectivity connects to the target with the configured credentials
+// and reads its sysDescr and sysUpTime. It returns a summary of the values
+// read, or the reason the target could not be read, naming the usmStats
+// counter a SNMPv3 target reported
+func testConnectivity() (string, error) {
+	if err := connect(targetHost, targetPort); err != nil {
+		return "", err
+	}
+	defer disconnect()
+	result, err := theSNMP.Get([]string{sysDescrOid, sysUpTimeOid})
+	if err != nil {
+		return "", err
+	}
+	if result.Error != gosnmp.NoError {
+		return "", fmt.Errorf("%s: %s", getErrorCode(result.Error), getErrorMessage(result.Error))
+	}
+	var values []string
+	for _, variable := range result.Variables {
+		name := normalizeOid(variable.Name)
+		if err := usmStatError(variable); err != nil {
+			return "", err
+		}
+		switch {
+		case isNullPDU(variable):
+			return "", fmt.Errorf("the target has no value for %s", name)
+		case name == sysDescrOid:
+			if b, ok := variable.Value.([]byte); ok {
+				values = append(values, fmt.Sprintf("sysDescr=%q", octetStringValue(b)))
+			}
+		case name == sysUpTimeOid:
+			values = append(values, "sysUpTime="+formatUptime(gosnmp.ToBigInt(variable.Value).Uint64()))
+		}
+	}
+	return strings.Join(values, " "), nil
+}
+
+// usmStatError explains the usmStats counter a target reports when it
+// rejects an SNMPv3 request, nil when the variable is not one
+func usmStatError(variable gosnmp.SnmpPDU) error {
+	name := normalizeOid(variable.Name)
+	if counter, ok := knownErrorOids[name]; ok {
+		return fmt.Errorf("the target incremented %s, %s", strings.TrimPrefix(counter, "oid"), usmStatHints[name])
+	}
+	return nil
+}
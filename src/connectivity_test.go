@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/soniah/gosnmp"
+)
+
+func TestUsmStatError(t *testing.T) {
+	cases := []struct {
+		oid      string
+		expected string
+	}{
+		{".1.3.6.1.6.3.15.1.1.1.0", "the target incremented UsmStatsUnsupportedSecLevels, the security_level is not supported for the user"},
+		{".1.3.6.1.6.3.15.1.1.2.0", "the target incremented UsmStatsNotInTimeWindows, the request was outside the time window of the target engine"},
+		{"1.3.6.1.6.3.15.1.1.3.0", "the target incremented UsmStatsUnknownUserNames, the username is unknown to the target"},
+		{".1.3.6.1.6.3.15.1.1.4.0", "the target incremented UsmStatsUnknownEngineIDs, the target engine ID is unknown"},
+		{".1.3.6.1.6.3.15.1.1.5.0", "the target incremented UsmStatsWrongDigests, the auth_protocol or auth_passphrase is wrong"},
+		{".1.3.6.1.6.3.15.1.1.6.0", "the target incremented UsmStatsDecryptionErrors, the priv_protocol or priv_passphrase is wrong"},
+	}
+	for _, c := range cases {
+		err := usmStatError(gosnmp.SnmpPDU{Name: c.oid, Type: gosnmp.Counter32, Value: uint(1)})
+		if err == nil || err.Error() != c.expected {
+			t.Errorf("usmStatError(%s) = %v, expected %s", c.oid, err, c.expected)
+		}
+	}
+	if err := usmStatError(gosnmp.SnmpPDU{Name: sysDescrOid, Type: gosnmp.OctetString, Value: []byte("router")}); err != nil {
+		t.Errorf("unexpected error %v for sysDescr", err)
+	}
+	//every usmStats counter known as an error OID has a hint
+	for oid, counter := range knownErrorOids {
+		if _, ok := usmStatHints[oid]; strings.HasPrefix(counter, "oidUsmStats") && !ok {
+			t.Errorf("no hint for %s", counter)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// datadogProfile is a struct to aid the automatic
+// parsing of a Datadog SNMP profile
+type datadogProfile struct {
+	Extends     []string        `yaml:"extends"`
+	SysObjectId stringList      `yaml:"sysobjectid"`
+	Metrics     []datadogMetric `yaml:"metrics"`
+	MetricTags  []datadogTag    `yaml:"metric_tags"`
+}
+
+// datadogMetric is either a scalar `symbol` or the `symbols` of a `table`
+type datadogMetric struct {
+	Symbol     *datadogSymbol  `yaml:"symbol"`
+	Table      *datadogSymbol  `yaml:"table"`
+	Symbols    []datadogSymbol `yaml:"symbols"`
+	ForcedType string          `yaml:"forced_type"`
+	MetricType string          `yaml:"metric_type"`
+	MetricTags []datadogTag    `yaml:"metric_tags"`
+}
+
+type datadogSymbol struct {
+	Oid          string  `yaml:"OID"`
+	Name         string  `yaml:"name"`
+	MetricType   string  `yaml:"metric_type"`
+	ScaleFactor  float64 `yaml:"scale_factor"`
+	ExtractValue string  `yaml:"extract_value"`
+}
+
+// datadogTag tags the rows of a table with a `column` or an `index` arc, or
+// every metric of the device with a scalar `symbol`
+type datadogTag struct {
+	Tag     string            `yaml:"tag"`
+	Index   int               `yaml:"index"`
+	Column  *datadogSymbol    `yaml:"column"`
+	Oid     string            `yaml:"OID"`
+	Symbol  datadogSymbolRef  `yaml:"symbol"`
+	Mapping map[string]string `yaml:"mapping"`
+}
+
+// datadogSymbolRef is the symbol of a device tag, either a bare name next to
+// the OID of the tag or a `{OID, name}` symbol
+type datadogSymbolRef datadogSymbol
+
+// UnmarshalYAML accepts both forms of the symbol of a device tag
+func (s *datadogSymbolRef) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		s.Name = name
+		return nil
+	}
+	return unmarshal((*datadogSymbol)(s))
+}
+
+// stringList is a yaml value that is either a single string or a list of strings
+type stringList []string
+
+// UnmarshalYAML accepts both a string and a list of strings
+func (l *stringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*l = stringList{value}
+		return nil
+	}
+	var values []string
+	if err := unmarshal(&values); err != nil {
+		return err
+	}
+	*l = values
+	return nil
+}
+
+// datadogTypes maps the Datadog metric types to metric types. Datadog infers
+// the type of the metrics without one from the PDU, like `auto`
+var datadogTypes = map[string]string{
+	"":                         "auto",
+	"gauge":                    "gauge",
+	"rate":                     "rate",
+	"monotonic_count":          "delta",
+	"monotonic_count_and_rate": "rate",
+	"percent":                  "rate",
+	"flag_stream":              "gauge",
+}
+
+// datadogBaseProfiles are the Datadog base profiles standing for bundled profiles
+var datadogBaseProfiles = map[string]string{
+	"_base.yaml":                   "generic-system",
+	"_generic-if.yaml":             "generic-if",
+	"_generic-host-resources.yaml": "generic-host-resources",
+	"_generic-entity-sensor.yaml":  "generic-entity-sensor",
+}
+
+// importDatadogProfile converts a Datadog SNMP profile into a collection file.
+// Scalar symbols and device tags make a scalar metric set, every table its own
+// table metric set, with column tags as attributes and index tags as index components
+func importDatadogProfile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var profile datadogProfile
+	if err := yaml.Unmarshal(data, &profile); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	collection, err := convertDatadogProfile(name, profile)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(collection)
+}
+
+func convertDatadogProfile(name string, profile datadogProfile) (generatedCollection, error) {
+	collection := generatedCollection{}
+	for _, sysObjectId := range profile.SysObjectId {
+		//Datadog matches sysObjectIDs with globs, profiles match them by prefix
+		collection.SysObjectIds = append(collection.SysObjectIds, normalizeOid(strings.TrimSuffix(strings.TrimRight(sysObjectId, "*"), ".")))
+	}
+	for _, base := range profile.Extends {
+		extended, ok := datadogBaseProfiles[base]
+		if !ok {
+			extended = strings.TrimPrefix(strings.TrimSuffix(base, filepath.Ext(base)), "_")
+		}
+		collection.Extends = append(collection.Extends, extended)
+	}
+
+	scalars := generatedMetricSet{Name: name, Type: "scalar", EventType: "SNMPSample"}
+	var tables []generatedMetricSet
+	for _, ddMetric := range profile.Metrics {
+		if ddMetric.Table == nil {
+			if ddMetric.Symbol == nil {
+				return collection, fmt.Errorf("metric of profile %s has neither a symbol nor a table", name)
+			}
+			scalars.Metrics = append(scalars.Metrics, datadogSymbolMetric(*ddMetric.Symbol, ddMetric))
+			continue
+		}
+		table := generatedMetricSet{
+			Name:      ddMetric.Table.Name,
+			Type:      "table",
+			EventType: sampleEventType(ddMetric.Table.Name),
+			RootOid:   normalizeOid(ddMetric.Table.Oid),
+		}
+		for _, symbol := range ddMetric.Symbols {
+			table.Metrics = append(table.Metrics, datadogSymbolMetric(symbol, ddMetric))
+		}
+		indexArcs := 0
+		indexTags := make(map[int]string)
+		for _, tag := range ddMetric.MetricTags {
+			switch {
+			case tag.Column != nil:
+				attribute := generatedMetric{MetricName: tag.Tag, Oid: normalizeOid(tag.Column.Oid), MetricType: "attribute"}
+				attribute.Values = datadogMapping(tag.Mapping)
+				table.Metrics = append(table.Metrics, attribute)
+			case tag.Index > 0:
+				indexTags[tag.Index] = tag.Tag
+				if tag.Index > indexArcs {
+					indexArcs = tag.Index
+				}
+			}
+		}
+		//index tags select an arc of the row index, which integer components decode one by one
+		for arc := 1; arc <= indexArcs; arc++ {
+			componentName, ok := indexTags[arc]
+			if !ok {
+				componentName = fmt.Sprintf("index%d", arc)
+			}
+			table.IndexComponents = append(table.IndexComponents, generatedIndexComponent{Name: componentName, Type: "integer"})
+		}
+		tables = append(tables, table)
+	}
+	for _, tag := range profile.MetricTags {
+		oid := tag.Oid
+		if tag.Symbol.Oid != "" {
+			oid = tag.Symbol.Oid
+		}
+		if oid == "" || tag.Tag == "" {
+			continue
+		}
+		attribute := generatedMetric{MetricName: tag.Tag, Oid: normalizeOid(oid), MetricType: "attribute"}
+		attribute.Values = datadogMapping(tag.Mapping)
+		scalars.Metrics = append(scalars.Metrics, attribute)
+	}
+
+	device := generatedDevice{Device: name}
+	if len(scalars.Metrics) > 0 {
+		device.MetricSets = append(device.MetricSets, scalars)
+	}
+	device.MetricSets = append(device.MetricSets, tables...)
+	collection.Collect = []generatedDevice{device}
+	return collection, nil
+}
+
+// datadogSymbolMetric converts a symbol, typed by its own metric_type or by
+// the type of the metric it belongs to
+func datadogSymbolMetric(symbol datadogSymbol, ddMetric datadogMetric) generatedMetric {
+	ddType := symbol.MetricType
+	if ddType == "" {
+		ddType = ddMetric.MetricType
+	}
+	if ddType == "" {
+		ddType = ddMetric.ForcedType
+	}
+	metricType, ok := datadogTypes[ddType]
+	if !ok {
+		metricType = "auto"
+	}
+	generated := generatedMetric{
+		MetricName: symbol.Name,
+		Oid:        normalizeOid(symbol.Oid),
+		MetricType: metricType,
+		Extract:    symbol.ExtractValue,
+	}
+	scale := symbol.ScaleFactor
+	if ddType == "percent" && scale == 0 {
+		//percent metrics are rates of counters expressed as percentages
+		scale = 100
+	}
+	if scale != 0 {
+		generated.Scale = &scale
+	}
+	return generated
+}
+
+// datadogMapping converts the mapping of a tag to the values of an enumeration.
+// Mappings of non integer values have no equivalent and are dropped
+func datadogMapping(mapping map[string]string) map[int]string {
+	var values map[int]string
+	for key, label := range mapping {
+		code, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		if values == nil {
+			values = make(map[int]string)
+		}
+		values[code] = label
+	}
+	return values
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/integration"
+	"github.com/newrelic/infra-integrations-sdk/log"
+	"github.com/soniah/gosnmp"
+)
+
+const (
+	// hrSystemDateOid is the HOST-RESOURCES-MIB wall clock of the device
+	hrSystemDateOid = ".1.3.6.1.2.1.25.1.2.0"
+	// snmpEngineTimeOid is the SNMP-FRAMEWORK-MIB number of seconds since the engine last booted
+	snmpEngineTimeOid = ".1.3.6.1.6.3.10.2.1.3.0"
+)
+
+// deviceClock is the time reported by the target device, read when the
+// device_time argument is set
+type deviceClock struct {
+	readAt time.Time
+	// bootTime is when the device booted, from snmpEngineTime, which does
+	// not wrap, or sysUpTime otherwise. It is zero when neither is available
+	bootTime time.Time
+	// wallClock is the wall clock of the device, zero without hrSystemDate
+	wallClock time.Time
+}
+
+var targetClock deviceClock
+
+// readDeviceTime reads the wall clock and engine time of the target device.
+// Each OID is read on its own as an SNMPv1 agent fails the whole request
+// for an OID it does not support
+func readDeviceTime() {
+	targetClock = deviceClock{readAt: time.Now(), bootTime: deviceBootTime}
+	if pdu, ok := getScalar(snmpEngineTimeOid); ok && pdu.Type == gosnmp.Integer {
+		seconds := gosnmp.ToBigInt(pdu.Value).Int64()
+		targetClock.bootTime = targetClock.readAt.Add(-time.Duration(seconds) * time.Second)
+	}
+	if pdu, ok := getScalar(hrSystemDateOid); ok {
+		if b, isBytes := pdu.Value.([]byte); isBytes {
+			t, err := parseDateAndTime(b)
+			if err != nil {
+				log.Debug("unable to parse hrSystemDate of %s: %v", targetHost, err)
+			} else {
+				targetClock.wallClock = t
+			}
+		}
+	}
+}
+
+// getScalar reads a single scalar OID
+func getScalar(oid string) (gosnmp.SnmpPDU, bool) {
+	result, err := theSNMP.Get([]string{oid})
+	if err != nil || result.Error != gosnmp.NoError || len(result.Variables) == 0 || isNullPDU(result.Variables[0]) {
+		log.Debug("unable to read %s from %s", oid, targetHost)
+		return gosnmp.SnmpPDU{}, false
+	}
+	return result.Variables[0], true
+}
+
+// attachDeviceTime adds the boot time and the wall clock of the device, and
+// the offset of that clock from the collection time, to every metric set
+func attachDeviceTime(i *integration.Integration) {
+	values := make(map[string]interface{})
+	if !targetClock.bootTime.IsZero() {
+		values["deviceBootTime"] = targetClock.bootTime.Unix()
+	}
+	if !targetClock.wallClock.IsZero() {
+		values["deviceTime"] = targetClock.wallClock.Unix()
+		values["deviceClockOffset"] = targetClock.wallClock.Sub(targetClock.readAt).Seconds()
+	}
+	for _, entity := range i.Entities {
+		for _, ms := range entity.Metrics {
+			for name, value := range values {
+				if err := ms.SetMetric(name, value, metric.GAUGE); err != nil {
+					log.Error(err.Error())
+				}
+			}
+			roundMetric(ms, "deviceClockOffset", 3)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/integration"
+)
+
+func TestAttachDeviceTime(t *testing.T) {
+	defer func() { targetClock = deviceClock{} }()
+	readAt := time.Date(2019, 11, 18, 12, 30, 15, 0, time.UTC)
+	cases := []struct {
+		name     string
+		clock    deviceClock
+		expected map[string]interface{}
+	}{
+		{"clock ahead", deviceClock{readAt: readAt, bootTime: readAt.Add(-time.Hour), wallClock: readAt.Add(2500 * time.Millisecond)},
+			map[string]interface{}{"deviceBootTime": float64(readAt.Unix() - 3600), "deviceTime": float64(readAt.Unix() + 2), "deviceClockOffset": 2.5}},
+		{"clock behind in another time zone", deviceClock{readAt: readAt, wallClock: readAt.Add(-90 * time.Second).In(time.FixedZone("", 3600))},
+			map[string]interface{}{"deviceTime": float64(readAt.Unix() - 90), "deviceClockOffset": -90.0}},
+		{"no wall clock", deviceClock{readAt: readAt, bootTime: readAt.Add(-time.Hour)},
+			map[string]interface{}{"deviceBootTime": float64(readAt.Unix() - 3600)}},
+		{"nothing known", deviceClock{readAt: readAt}, map[string]interface{}{}},
+	}
+	for _, c := range cases {
+		i, err := integration.New("test", "0.0.0", integration.InMemoryStore())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ms := i.LocalEntity().NewMetricSet("SNMPSample")
+		targetClock = c.clock
+		attachDeviceTime(i)
+		for _, name := range []string{"deviceBootTime", "deviceTime", "deviceClockOffset"} {
+			if value, expected := ms.Metrics[name], c.expected[name]; value != expected {
+				t.Errorf("%s: %s = %#v, expected %#v", c.name, name, value, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/soniah/gosnmp"
+)
+
+const (
+	// maxScalarOids is the number of OIDs above which a scalar metric set is
+	// not requested at all
+	maxScalarOids = 200
+	// bulkWalkMaxRepetitions is the GETBULK max-repetitions gosnmp walks a
+	// subtree with when the connection does not set one
+	bulkWalkMaxRepetitions = 50
+)
+
+// dryRunPlan describes the requests a run sends to the target, without
+// connecting to it
+type dryRunPlan struct {
+	strings.Builder
+}
+
+func (p *dryRunPlan) line(depth int, format string, v ...interface{}) {
+	p.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(p, format, v...)
+	p.WriteString("\n")
+}
+
+// planTarget describes the connection to the target and the requests sent
+// before any collection file is collected
+func (p *dryRunPlan) planTarget(maxOids int) {
+	version := "v2c"
+	if args.V3 {
+		version = "v3 " + strings.TrimSpace(args.SecurityLevel)
+	}
+	p.line(0, "target %s:%d, SNMP %s, at most %d OIDs per GET, timeout %s", targetHost, targetPort, version, maxOids, connectionTimeout)
+	p.line(1, "GET %s sysUpTime, to detect counter discontinuities", sysUpTimeOid)
+	if args.DeviceTime {
+		p.line(1, "GET %s snmpEngineTime, then GET %s hrSystemDate, for device_time", snmpEngineTimeOid, hrSystemDateOid)
+	}
+	for _, name := range strings.Split(args.Profiles, ",") {
+		if strings.TrimSpace(name) == autoProfile {
+			p.line(1, "GET %s sysObjectID and %s sysDescr on first contact, to select the auto profile, which is not planned", sysObjectIdOid, sysDescrOid)
+		}
+	}
+}
+
+// planCollection describes the requests sent to collect a collection file,
+// in the order they are sent
+func (p *dryRunPlan) planCollection(file string, collections []*collection, maxOids int) {
+	p.line(0, "collection %s", file)
+	for _, collection := range collections {
+		p.line(1, "device %s", collection.Device)
+		tableGroups := make(map[string][]metricSet)
+		var tableRootOids []string
+		for _, metricSet := range collection.MetricSets {
+			switch metricSet.Type {
+			case "scalar":
+				p.planScalars(metricSet, maxOids)
+			case "table":
+				if _, ok := tableGroups[metricSet.RootOid]; !ok {
+					tableRootOids = append(tableRootOids, metricSet.RootOid)
+				}
+				tableGroups[metricSet.RootOid] = append(tableGroups[metricSet.RootOid], metricSet)
+			default:
+				p.line(2, "metric set %s has the invalid type %s and is skipped", metricSet.Name, metricSet.Type)
+			}
+		}
+		for _, rootOid := range tableRootOids {
+			p.planTable(tableGroups[rootOid], maxOids)
+		}
+		if len(collection.Inventory) > 0 {
+			var oids []string
+			for _, item := range collection.Inventory {
+				oids = append(oids, item.oid)
+			}
+			p.planGet("inventory", oids, maxOids)
+			for _, item := range collection.Inventory {
+				p.line(3, "%s %s/%s", item.oid, item.category, item.name)
+			}
+		}
+	}
+}
+
+// planGet describes a single GET of OIDs
+func (p *dryRunPlan) planGet(name string, oids []string, maxOids int) {
+	switch {
+	case len(oids) > maxOids:
+		p.line(2, "GET %s: %d OIDs, more than the %d a GET accepts, the request fails", name, len(oids), maxOids)
+	default:
+		p.line(2, "GET %s: %d OIDs in 1 request", name, len(oids))
+	}
+}
+
+func (p *dryRunPlan) planScalars(metricSet metricSet, maxOids int) {
+	var oids []string
+	seen := make(map[string]bool)
+	for _, metric := range metricSet.Metrics {
+		if !seen[metric.oid] {
+			seen[metric.oid] = true
+			oids = append(oids, metric.oid)
+		}
+	}
+	if len(oids) > maxScalarOids {
+		p.line(2, "scalar %s: %d OIDs, more than %d, the metric set is not requested", metricSet.Name, len(oids), maxScalarOids)
+		return
+	}
+	p.planGet("scalar "+metricSet.Name, oids, maxOids)
+	if metricSet.Timeout > 0 {
+		p.line(3, "timeout %s", metricSet.Timeout)
+	}
+	for _, metric := range metricSet.Metrics {
+		p.line(3, "%s %s", metric.oid, dryRunMetricName(metric))
+	}
+	for _, metric := range metricSet.Metrics {
+		if metric.fallbackOid != "" {
+			p.line(3, "then %s as fallback of %s, only when the target has no value for it", metric.fallbackOid, dryRunMetricName(metric))
+		}
+	}
+}
+
+// planTable describes the walk of the table metric sets sharing a root OID
+func (p *dryRunPlan) planTable(metricSets []metricSet, maxOids int) {
+	var names []string
+	pageSize := 0
+	collectAllColumns := false
+	var timeout time.Duration
+	for _, metricSet := range metricSets {
+		names = append(names, metricSet.Name)
+		if metricSet.Timeout > timeout {
+			timeout = metricSet.Timeout
+		}
+		if metricSet.PageSize > 0 && (pageSize == 0 || metricSet.PageSize < pageSize) {
+			pageSize = metricSet.PageSize
+		}
+		collectAllColumns = collectAllColumns || metricSet.CollectAllColumns
+	}
+	rootOid := metricSets[0].RootOid
+	//the dry run does not probe the target, it plans the walk of a target answering every column
+	columns := walkedColumns(metricSets, func(column string) bool { return false })
+	defer func() {
+		if timeout > 0 {
+			p.line(3, "timeout %s", timeout)
+		}
+	}()
+	if collectAllColumns {
+		p.line(2, "WALK table %s: the whole subtree of %s with GETBULK, max-repetitions %d, held in memory until complete", strings.Join(names, ", "), rootOid, bulkWalkMaxRepetitions)
+		for _, metricSet := range metricSets {
+			for _, index := range metricSet.Index {
+				if !strings.HasPrefix(index.oid, rootOid+".") {
+					p.line(3, "then the index column %s outside of the root OID", index.oid)
+				}
+			}
+		}
+		return
+	}
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	requests := (len(columns) + maxOids - 1) / maxOids
+	p.line(2, "WALK table %s: %d columns side by side with GETBULK, max-repetitions %d, %d varbinds per page in %d requests", strings.Join(names, ", "), len(columns), pageSize, len(columns)*pageSize, requests)
+	p.line(3, "a page too big for the agent falls back to walking the remaining columns one after the other, max-repetitions %d", bulkWalkMaxRepetitions)
+	columnNames := make(map[string]string)
+	for _, metricSet := range metricSets {
+		for _, index := range metricSet.Index {
+			columnNames[index.oid] = "index " + index.name
+		}
+		for _, metric := range metricSet.Metrics {
+			if _, ok := columnNames[metric.oid]; !ok {
+				columnNames[metric.oid] = dryRunMetricName(metric)
+			}
+		}
+		if metricSet.DiscontinuityOid != "" {
+			columnNames[metricSet.DiscontinuityOid] = "discontinuity timer"
+		}
+	}
+	for _, column := range columns {
+		if ttl := columnCacheTTL(metricSets, column); ttl > 0 {
+			p.line(3, "%s %s, walked once every %s", column, columnNames[column], ttl)
+			continue
+		}
+		p.line(3, "%s %s", column, columnNames[column])
+	}
+	for _, metricSet := range metricSets {
+		for _, metric := range metricSet.Metrics {
+			if metric.fallbackOid != "" {
+				p.line(3, "or %s as fallback of %s, walked in its place when a GETNEXT finds no value for it", metric.fallbackOid, dryRunMetricName(metric))
+			}
+		}
+	}
+}
+
+// planHardwareInventory describes the walk of the hardware inventory
+func (p *dryRunPlan) planHardwareInventory() {
+	var columns []string
+	for number := range entPhysicalColumns {
+		columns = append(columns, fmt.Sprintf("%s.%d", entPhysicalEntry, number))
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		return compareOids(columns[i], columns[j]) < 0
+	})
+	p.line(0, "hardware inventory")
+	p.line(1, "WALK entPhysicalTable: %d columns side by side with GETBULK, max-repetitions %d", len(columns), defaultPageSize)
+	for _, column := range columns {
+		p.line(2, "%s", column)
+	}
+}
+
+// dryRunMetricName is the name a metric is reported with
+func dryRunMetricName(def *metricDef) string {
+	if def.metricName != "" {
+		return def.metricName
+	}
+	return mibs.objectName(def.oid)
+}
+
+// dryRunMaxOids is the number of OIDs a GET of the configured connection accepts
+func dryRunMaxOids() int {
+	if args.V3 {
+		return gosnmp.MaxOids
+	}
+	return v2cMaxOids
+}
+
+// dryRun describes the requests a run with the current arguments sends to
+// the target, from the collection files and the profiles selected by name
+func dryRun() (string, error) {
+	if err := loadMibDirs(); err != nil {
+		return "", fmt.Errorf("failed to load MIB files: %v", err)
+	}
+	files, err := configuredCollectionFiles()
+	if err != nil {
+		return "", err
+	}
+
+	maxOids := dryRunMaxOids()
+	var plan dryRunPlan
+	plan.planTarget(maxOids)
+	for _, file := range files {
+		file = strings.TrimSpace(file)
+		parser, err := loadCollectionFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse collection definition file %s: %v", file, err)
+		}
+		collections, err := parseCollection(parser)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse collection definition %s: %v", file, err)
+		}
+		plan.planCollection(file, collections, maxOids)
+	}
+	if args.HardwareInventory {
+		plan.planHardwareInventory()
+	}
+	return plan.String(), nil
+}
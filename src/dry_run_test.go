@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	args.ProfileDirs, args.Profiles = "../profiles", "generic-system,auto"
+	targetHost, targetPort = "192.0.2.1", 161
+	defer func() { args.ProfileDirs, args.Profiles = "", "" }()
+	plan, err := dryRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"target 192.0.2.1:161, SNMP v2c, at most 8900 OIDs per GET, timeout 10s",
+		"to select the auto profile, which is not planned",
+		"collection ../profiles/generic-system.yml",
+		"GET scalar system: 4 OIDs in 1 request",
+		".1.3.6.1.2.1.1.5.0 sysName",
+	} {
+		if !strings.Contains(plan, expected) {
+			t.Errorf("expected %q in the plan:\n%s", expected, plan)
+		}
+	}
+}
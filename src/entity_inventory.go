@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/integration"
+	"github.com/newrelic/infra-integrations-sdk/log"
+	"github.com/soniah/gosnmp"
+)
+
+// entPhysicalEntry is the entry of the ENTITY-MIB entPhysicalTable
+const entPhysicalEntry = ".1.3.6.1.2.1.47.1.1.1.1"
+
+// entPhysicalColumns are the columns of entPhysicalEntry read for the
+// hardware inventory, by column number
+var entPhysicalColumns = map[int]string{
+	2:  "description",
+	4:  "containedIn",
+	5:  "class",
+	7:  "name",
+	8:  "hardwareRevision",
+	9:  "firmwareRevision",
+	10: "softwareRevision",
+	11: "serialNumber",
+	12: "manufacturer",
+	13: "model",
+}
+
+// entPhysicalClasses are the labels of the PhysicalClass enumeration
+var entPhysicalClasses = map[int]string{
+	1:  "other",
+	2:  "unknown",
+	3:  "chassis",
+	4:  "backplane",
+	5:  "container",
+	6:  "powerSupply",
+	7:  "fan",
+	8:  "sensor",
+	9:  "module",
+	10: "port",
+	11: "stack",
+	12: "cpu",
+	13: "energyObject",
+	14: "batteryObject",
+	15: "storageDrive",
+}
+
+// inventoryClasses are the physical classes always reported as hardware
+// inventory. Entities of the other classes, such as ports holding a
+// transceiver, are reported when they have a serial number
+var inventoryClasses = map[string]bool{
+	"chassis":     true,
+	"stack":       true,
+	"module":      true,
+	"powerSupply": true,
+	"fan":         true,
+}
+
+// physicalEntity is a row of entPhysicalTable
+type physicalEntity struct {
+	index       string
+	containedIn string
+	fields      map[string]string
+}
+
+// populateHardwareInventory walks entPhysicalTable and reports its hardware
+// as inventory items of the entity of the target
+func populateHardwareInventory(i *integration.Integration) error {
+	entity, err := i.Entity(fmt.Sprintf("%s:%d", targetHost, targetPort), "address")
+	if err != nil {
+		return err
+	}
+	var columns []string
+	for number := range entPhysicalColumns {
+		columns = append(columns, entPhysicalEntry+"."+strconv.Itoa(number))
+	}
+	var rows []*tableRow
+	stats := &walkStats{rootOid: entPhysicalEntry}
+	err = walkColumns(theSNMP, theSNMP.MaxOids, columns, defaultPageSize, stats, func(row *tableRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Debug("read %d physical entities from %s", len(rows), targetHost)
+	for key, fields := range hardwareInventory(rows) {
+		for field, value := range fields {
+			if err := entity.SetInventoryItem(key, field, value); err != nil {
+				log.Error(err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// hardwareInventory builds the inventory items of the physical entities worth
+// reporting, keyed `hardware/<entPhysicalIndex>`. Each item carries the
+// `parent` item it is contained in, through containers and entities that are
+// not reported, and the `path` of entity names from the chassis down
+func hardwareInventory(rows []*tableRow) map[string]map[string]string {
+	entities := make(map[string]*physicalEntity)
+	for _, row := range rows {
+		entity := &physicalEntity{index: row.indexKey, fields: make(map[string]string)}
+		for number, field := range entPhysicalColumns {
+			pdu, ok := row.pdus[entPhysicalEntry+"."+strconv.Itoa(number)]
+			if !ok || isNullPDU(pdu) {
+				continue
+			}
+			switch value := pdu.Value.(type) {
+			case []byte:
+				entity.fields[field] = octetStringValue(value)
+			case int:
+				entity.fields[field] = strconv.Itoa(value)
+				if field == "class" {
+					if class, ok := entPhysicalClasses[value]; ok {
+						entity.fields[field] = class
+					}
+				}
+			default:
+				entity.fields[field] = gosnmp.ToBigInt(value).String()
+			}
+		}
+		entity.containedIn = entity.fields["containedIn"]
+		delete(entity.fields, "containedIn")
+		entities[entity.index] = entity
+	}
+
+	reported := func(entity *physicalEntity) bool {
+		return inventoryClasses[entity.fields["class"]] || entity.fields["serialNumber"] != ""
+	}
+	items := make(map[string]map[string]string)
+	for _, entity := range entities {
+		if !reported(entity) {
+			continue
+		}
+		item := make(map[string]string)
+		for field, value := range entity.fields {
+			if value != "" {
+				item[field] = value
+			}
+		}
+		item["index"] = entity.index
+		var path []string
+		parent := ""
+		//agents reporting a containment loop must not hang the walk up the tree
+		visited := make(map[string]bool)
+		for ancestor := entity; ancestor != nil && !visited[ancestor.index]; {
+			visited[ancestor.index] = true
+			name := ancestor.fields["name"]
+			if name == "" {
+				name = ancestor.fields["class"] + " " + ancestor.index
+			}
+			path = append([]string{name}, path...)
+			ancestor = entities[ancestor.containedIn]
+			if ancestor != nil && ancestor != entity && parent == "" && reported(ancestor) {
+				parent = "hardware/" + ancestor.index
+			}
+		}
+		item["path"] = strings.Join(path, " / ")
+		if parent != "" {
+			item["parent"] = parent
+		}
+		items["hardware/"+entity.index] = item
+	}
+	return items
+}
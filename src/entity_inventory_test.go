@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/soniah/gosnmp"
+)
+
+func TestHardwareInventory(t *testing.T) {
+	entity := func(index string, class int, containedIn int, name string, serial string) *tableRow {
+		row := &tableRow{indexKey: index, pdus: map[string]gosnmp.SnmpPDU{
+			entPhysicalEntry + ".4": {Type: gosnmp.Integer, Value: containedIn},
+			entPhysicalEntry + ".5": {Type: gosnmp.Integer, Value: class},
+			entPhysicalEntry + ".7": {Type: gosnmp.OctetString, Value: []byte(name)},
+		}}
+		if serial != "" {
+			row.pdus[entPhysicalEntry+".11"] = gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte(serial)}
+		}
+		return row
+	}
+	rows := []*tableRow{
+		entity("1", 3, 0, "Chassis", "FOX123"),
+		entity("2", 5, 1, "Slot 1", ""),
+		entity("3", 9, 2, "Supervisor", "SAL456"),
+		entity("4", 10, 3, "Gi1/1", ""),
+		entity("5", 10, 3, "Te1/1", "AVD789"),
+		entity("6", 6, 1, "PSU 1", ""),
+		entity("7", 9, 7, "Loop", ""),
+	}
+	items := hardwareInventory(rows)
+	if len(items) != 5 {
+		t.Fatalf("expected 5 items, got %v", items)
+	}
+	supervisor := items["hardware/3"]
+	if supervisor["class"] != "module" || supervisor["parent"] != "hardware/1" || supervisor["path"] != "Chassis / Slot 1 / Supervisor" {
+		t.Errorf("unexpected module %v", supervisor)
+	}
+	if transceiver := items["hardware/5"]; transceiver["serialNumber"] != "AVD789" || transceiver["parent"] != "hardware/3" {
+		t.Errorf("unexpected transceiver %v", transceiver)
+	}
+	if _, ok := items["hardware/4"]; ok {
+		t.Error("ports without serial number should not be reported")
+	}
+	if loop := items["hardware/7"]; loop["path"] != "Loop" || loop["parent"] != "" {
+		t.Errorf("unexpected path of a containment loop %v", loop)
+	}
+	if chassis := items["hardware/1"]; chassis["parent"] != "" || chassis["path"] != "Chassis" {
+		t.Errorf("unexpected chassis %v", chassis)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 var knownErrorOids = map[string]string{
+	".1.3.6.1.6.3.15.1.1.1.0": "oidUsmStatsUnsupportedSecLevels",
+	".1.3.6.1.6.3.15.1.1.2.0": "oidUsmStatsNotInTimeWindows",
 	".1.3.6.1.6.3.15.1.1.3.0": "oidUsmStatsUnknownUserNames",
 	".1.3.6.1.6.3.15.1.1.4.0": "oidUsmStatsUnknownEngineIDs",
 	".1.3.6.1.6.3.15.1.1.5.0": "oidUsmStatsWrongDigests",
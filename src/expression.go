@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expression is a compiled arithmetic expression over the values of the
+// metrics of a row or of a scalar metric set. Comparisons evaluate to 1 or 0,
+// for threshold flags such as `temperature > 75`
+type expression interface {
+	eval(values map[string]float64) (float64, error)
+}
+
+type numberExpr float64
+
+type variableExpr string
+
+type negateExpr struct {
+	operand expression
+}
+
+type binaryExpr struct {
+	op          byte
+	left, right expression
+}
+
+type compareExpr struct {
+	op          string
+	left, right expression
+}
+
+func (e numberExpr) eval(values map[string]float64) (float64, error) {
+	return float64(e), nil
+}
+
+func (e variableExpr) eval(values map[string]float64) (float64, error) {
+	value, ok := values[string(e)]
+	if !ok {
+		return 0, fmt.Errorf("no value for %s", string(e))
+	}
+	return value, nil
+}
+
+func (e negateExpr) eval(values map[string]float64) (float64, error) {
+	value, err := e.operand.eval(values)
+	return -value, err
+}
+
+func (e binaryExpr) eval(values map[string]float64) (float64, error) {
+	left, err := e.left.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	right, err := e.right.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	switch e.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	}
+	if right == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	return left / right, nil
+}
+
+func (e compareExpr) eval(values map[string]float64) (float64, error) {
+	left, err := e.left.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	right, err := e.right.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	var result bool
+	switch e.op {
+	case "<":
+		result = left < right
+	case "<=":
+		result = left <= right
+	case ">":
+		result = left > right
+	case ">=":
+		result = left >= right
+	case "==":
+		result = left == right
+	case "!=":
+		result = left != right
+	}
+	if result {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// comparisonOperators are the operators of comparisons, two character
+// operators first so they are not matched as their first character
+var comparisonOperators = []string{"<=", ">=", "==", "!=", "<", ">"}
+
+// expressionParser is a recursive descent parser of the grammar
+//
+//	comparison = expr [ ("<" | "<=" | ">" | ">=" | "==" | "!=") expr ]
+//	expr   = term { ("+" | "-") term }
+//	term   = factor { ("*" | "/") factor }
+//	factor = number | name | "(" comparison ")" | "-" factor
+type expressionParser struct {
+	input     string
+	pos       int
+	variables []string
+}
+
+// parseExpression compiles an arithmetic expression and returns the names it references
+func parseExpression(input string) (expression, []string, error) {
+	p := &expressionParser{input: input}
+	expr, err := p.parseComparison()
+	if err != nil {
+		return nil, nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return nil, nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos:], p.pos)
+	}
+	return expr, p.variables, nil
+}
+
+func (p *expressionParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *expressionParser) parseComparison() (expression, error) {
+	left, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	for _, op := range comparisonOperators {
+		if strings.HasPrefix(p.input[p.pos:], op) {
+			p.pos += len(op)
+			right, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return compareExpr{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseExpr() (expression, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.input) || (p.input[p.pos] != '+' && p.input[p.pos] != '-') {
+			return left, nil
+		}
+		op := p.input[p.pos]
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *expressionParser) parseTerm() (expression, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.input) || (p.input[p.pos] != '*' && p.input[p.pos] != '/') {
+			return left, nil
+		}
+		op := p.input[p.pos]
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *expressionParser) parseFactor() (expression, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	c := rune(p.input[p.pos])
+	switch {
+	case c == '(':
+		p.pos++
+		expr, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis at position %d", p.pos)
+		}
+		p.pos++
+		return expr, nil
+	case c == '-':
+		p.pos++
+		operand, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return negateExpr{operand: operand}, nil
+	case unicode.IsDigit(c) || c == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsDigit(rune(p.input[p.pos])) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+		}
+		return numberExpr(value), nil
+	case unicode.IsLetter(c) || c == '_':
+		start := p.pos
+		for p.pos < len(p.input) && isNameChar(rune(p.input[p.pos])) {
+			p.pos++
+		}
+		name := p.input[start:p.pos]
+		p.variables = append(p.variables, name)
+		return variableExpr(name), nil
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", string(c), p.pos)
+}
+
+func isNameChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("_.", c)
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestExpression(t *testing.T) {
+	values := map[string]float64{"ifInOctets": 100, "ifOutOctets": 50, "hrStorageUsed": 25, "hrStorageSize": 200}
+	cases := map[string]float64{
+		"(ifInOctets + ifOutOctets) * 8":    1200,
+		"hrStorageUsed / hrStorageSize*100": 12.5,
+		"-ifOutOctets + 2 * 3":              -44,
+		"ifInOctets - ifOutOctets - 10":     40,
+		"ifInOctets > 75":                   1,
+		"ifOutOctets >= 75":                 0,
+		"ifOutOctets != 50":                 0,
+		"(hrStorageUsed <= 25) * 2":         2,
+	}
+	for input, expected := range cases {
+		expr, _, err := parseExpression(input)
+		if err != nil {
+			t.Fatalf("parseExpression(%s): %v", input, err)
+		}
+		if value, err := expr.eval(values); err != nil || value != expected {
+			t.Errorf("%s = %v (%v), expected %v", input, value, err, expected)
+		}
+	}
+
+	_, variables, _ := parseExpression("a + b.c * a")
+	if len(variables) != 3 || variables[1] != "b.c" {
+		t.Errorf("unexpected variables %v", variables)
+	}
+	for _, invalid := range []string{"(a + b", "a +", "a $ b", "2 3", "a > b > c", "a >"} {
+		if _, _, err := parseExpression(invalid); err == nil {
+			t.Errorf("expected error parsing %q", invalid)
+		}
+	}
+	expr, _, _ := parseExpression("a / b")
+	if _, err := expr.eval(map[string]float64{"a": 1, "b": 0}); err == nil {
+		t.Error("expected division by zero error")
+	}
+}
+
+func TestUnsigned32(t *testing.T) {
+	if v := unsigned32(-1); v != 4294967295 {
+		t.Errorf("unsigned32(-1) = %v", v)
+	}
+	if v := unsigned32(1024); v != 1024 {
+		t.Errorf("unsigned32(1024) = %v", v)
+	}
+}
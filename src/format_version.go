@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// currentFormatVersion is the version of the collection file format read by
+// the integration. Files without a format_version are version 1
+const currentFormatVersion = 2
+
+// formatMigrations upgrade the source of a collection file, YAML or JSON, by
+// the version they upgrade from to the next one. They edit the source line by
+// line, keeping its comments, layout and line numbers
+var formatMigrations = map[int]func(src []byte) []byte{
+	1: migrateUnitlessDurations,
+}
+
+var (
+	// formatVersionKey matches the format_version of a YAML or JSON collection file
+	formatVersionKey = regexp.MustCompile(`(?m)^\s*"?format_version"?\s*:\s*"?(\w+)"?`)
+	// unitlessDuration matches a cache_ttl or timeout of a number of seconds
+	// without unit, as version 1 files could hold in place of a duration
+	unitlessDuration = regexp.MustCompile(`(?m)((?:^|[\s{,])"?(?:cache_ttl|timeout)"?\s*:\s*)"?([0-9]+)"?(\s*[,}#\r\n]|\s*$)`)
+)
+
+// formatVersion reads the format_version of the source of a collection file
+func formatVersion(src []byte) (int, error) {
+	match := formatVersionKey.FindSubmatch(src)
+	if match == nil {
+		return 1, nil
+	}
+	version, err := strconv.Atoi(string(match[1]))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid format_version %s", match[1])
+	}
+	if version > currentFormatVersion {
+		return 0, fmt.Errorf("format_version %d is newer than the version %d read by this version of the integration, upgrade it", version, currentFormatVersion)
+	}
+	return version, nil
+}
+
+// migrateFormat upgrades the source of a collection file to the current
+// format version and returns the version it was upgraded from
+func migrateFormat(src []byte) ([]byte, int, error) {
+	version, err := formatVersion(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	for v := version; v < currentFormatVersion; v++ {
+		src = formatMigrations[v](src)
+	}
+	return src, version, nil
+}
+
+// migrateUnitlessDurations upgrades the cache_ttl and timeout given as a bare
+// number of seconds, such as `cache_ttl: 300`, to durations such as `300s`
+func migrateUnitlessDurations(src []byte) []byte {
+	return unitlessDuration.ReplaceAll(src, []byte(`$1"${2}s"$3`))
+}
+
+// setFormatVersion sets the format_version of the source of a collection
+// file, adding it at its top when it has none
+func setFormatVersion(src []byte, isJSON bool) []byte {
+	version := strconv.Itoa(currentFormatVersion)
+	if loc := formatVersionKey.FindSubmatchIndex(src); loc != nil {
+		return append(append(append([]byte(nil), src[:loc[2]]...), version...), src[loc[3]:]...)
+	}
+	if isJSON {
+		if start := strings.Index(string(src), "{"); start >= 0 {
+			return []byte(string(src[:start+1]) + "\n  \"format_version\": " + version + "," + string(src[start+1:]))
+		}
+		return src
+	}
+	//after the comments and the document start heading the file
+	lines := strings.SplitAfter(string(src), "\n")
+	var head int
+	for head < len(lines) && (strings.HasPrefix(strings.TrimSpace(lines[head]), "#") || strings.TrimSpace(lines[head]) == "---") {
+		head++
+	}
+	return []byte(strings.Join(lines[:head], "") + "format_version: " + version + "\n" + strings.Join(lines[head:], ""))
+}
+
+// migrateConfig upgrades the collection files of the collection_files
+// argument to the current format version in place, keeping a copy of each
+// file upgraded with the .bak extension, and reports what it did
+func migrateConfig() (string, error) {
+	files, err := collectionFilePaths(args.CollectionFiles)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("no collection file to migrate, set collection_files")
+	}
+	var report strings.Builder
+	for _, file := range files {
+		file = strings.TrimSpace(file)
+		info, err := os.Stat(file)
+		if err != nil {
+			return report.String(), err
+		}
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			return report.String(), err
+		}
+		migrated, version, err := migrateFormat(src)
+		if err != nil {
+			return report.String(), fmt.Errorf("%s: %v", file, err)
+		}
+		if version == currentFormatVersion {
+			fmt.Fprintf(&report, "%s: format_version %d, up to date\n", file, version)
+			continue
+		}
+		migrated = setFormatVersion(migrated, strings.EqualFold(filepath.Ext(file), ".json"))
+		if err := ioutil.WriteFile(file+".bak", src, info.Mode().Perm()); err != nil {
+			return report.String(), err
+		}
+		if err := ioutil.WriteFile(file, migrated, info.Mode().Perm()); err != nil {
+			return report.String(), err
+		}
+		fmt.Fprintf(&report, "%s: migrated from format_version %d to %d, the original is kept in %s.bak\n", file, version, currentFormatVersion, file)
+	}
+	return report.String(), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { args.CollectionFiles = "" }()
+	files := map[string]string{
+		"interfaces.yml": `# interfaces of the core switches
+collect:
+- device: core
+  metric_sets:
+  - name: interfaces
+    type: table
+    event_type: SNMPInterfaceSample
+    root_oid: .1.3.6.1.2.1.2.2
+    timeout: 30 # slow table
+    metrics:
+    - {metric_name: ifDescr, oid: .1.3.6.1.2.1.2.2.1.2, metric_type: attribute, cache_ttl: 3600}
+    - {metric_name: ifInOctets, oid: .1.3.6.1.2.1.2.2.1.10, metric_type: rate}
+`,
+		"system.json": `{"collect": [{"device": "core", "metric_sets": [{"name": "system", "type": "scalar", "event_type": "SNMPSample", "timeout": 5,
+  "metrics": [{"metric_name": "sysName", "oid": ".1.3.6.1.2.1.1.5.0", "metric_type": "attribute"}]}]}]}
+`,
+		"current.yml": "format_version: 2\ncollect: []\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//version 1 files are upgraded when they are read
+	parser, err := loadCollectionFile(filepath.Join(dir, "interfaces.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collections, err := parseCollection(parser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metricSet := collections[0].MetricSets[0]; metricSet.Timeout.String() != "30s" || metricSet.Metrics[0].cacheTTL.String() != "1h0m0s" {
+		t.Errorf("unexpected durations %s and %s", metricSet.Timeout, metricSet.Metrics[0].cacheTTL)
+	}
+
+	args.CollectionFiles = dir
+	report, err := migrateConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(report, "migrated from format_version 1 to 2") != 2 || !strings.Contains(report, "current.yml: format_version 2, up to date") {
+		t.Errorf("unexpected report\n%s", report)
+	}
+	migrated, err := ioutil.ReadFile(filepath.Join(dir, "interfaces.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"# interfaces of the core switches\nformat_version: 2\ncollect:", `timeout: "30s" # slow table`, `cache_ttl: "3600s"}`} {
+		if !strings.Contains(string(migrated), expected) {
+			t.Errorf("expected %q in the migrated file\n%s", expected, migrated)
+		}
+	}
+	if original, err := ioutil.ReadFile(filepath.Join(dir, "interfaces.yml.bak")); err != nil || string(original) != files["interfaces.yml"] {
+		t.Errorf("expected the original file to be kept, got %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "interfaces.yml")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the mode of the file to be kept, got %v", err)
+	}
+	for _, name := range []string{"interfaces.yml", "system.json"} {
+		parser, err := parseYaml(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parser.FormatVersion != currentFormatVersion {
+			t.Errorf("expected format_version %d in %s, got %d", currentFormatVersion, name, parser.FormatVersion)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "future.yml"), []byte("format_version: 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseYaml(filepath.Join(dir, "future.yml")); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("expected error for a newer format_version, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// parseGeneratedCollection checks that a generated collection file is a valid collection
+func parseGeneratedCollection(t *testing.T, collection generatedCollection) []*collection {
+	out, err := yaml.Marshal(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := collectionParser{}
+	if err := yaml.Unmarshal(out, &parser); err != nil {
+		t.Fatal(err)
+	}
+	collections, err := parseCollection(&parser)
+	if err != nil {
+		t.Fatalf("generated collection does not parse: %v\n%s", err, out)
+	}
+	return collections
+}
+
+func TestConvertDatadogProfile(t *testing.T) {
+	source := `
+extends: [_base.yaml, _generic-if.yaml, _vendor-base.yaml]
+sysobjectid: [1.3.6.1.4.1.9.1.*, 1.3.6.1.4.1.9.12.3.1.3]
+metric_tags:
+  - {OID: 1.3.6.1.2.1.1.5.0, symbol: sysName, tag: snmp_host}
+  - {symbol: {OID: 1.3.6.1.2.1.1.6.0, name: sysLocation}, tag: location}
+metrics:
+  - MIB: CISCO-PROCESS-MIB
+    table: {OID: 1.3.6.1.4.1.9.9.109.1.1.1, name: cpmCPUTotalTable}
+    symbols:
+      - {OID: 1.3.6.1.4.1.9.9.109.1.1.1.1.12, name: cpmCPUMemoryUsed, scale_factor: 1024}
+    metric_tags:
+      - {index: 2, tag: cpu}
+      - tag: state
+        column: {OID: 1.3.6.1.4.1.9.9.109.1.1.1.1.6, name: cpmCPUState}
+        mapping: {1: ok, 2: failed}
+  - MIB: IF-MIB
+    symbol: {OID: 1.3.6.1.4.1.9.9.48.1.1.1.5.1, name: poolUsed}
+    forced_type: monotonic_count
+`
+	var profile datadogProfile
+	if err := yaml.Unmarshal([]byte(source), &profile); err != nil {
+		t.Fatal(err)
+	}
+	converted, err := convertDatadogProfile("cisco", profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(converted.SysObjectIds) != 2 || converted.SysObjectIds[0] != ".1.3.6.1.4.1.9.1" {
+		t.Errorf("unexpected sys_object_ids %v", converted.SysObjectIds)
+	}
+	if len(converted.Extends) != 3 || converted.Extends[0] != "generic-system" || converted.Extends[2] != "vendor-base" {
+		t.Errorf("unexpected extends %v", converted.Extends)
+	}
+
+	metricSets := parseGeneratedCollection(t, converted)[0].MetricSets
+	if len(metricSets) != 2 {
+		t.Fatalf("expected a scalar and a table metric set, got %d", len(metricSets))
+	}
+	scalars, table := metricSets[0], metricSets[1]
+	if len(scalars.Metrics) != 3 || scalars.Metrics[0].metricType != delta || scalars.Metrics[2].metricName != "location" {
+		t.Errorf("unexpected scalar metrics %+v", scalars.Metrics)
+	}
+	if table.RootOid != ".1.3.6.1.4.1.9.9.109.1.1.1" || len(table.Metrics) != 2 {
+		t.Fatalf("unexpected table %+v", table)
+	}
+	if used := table.Metrics[0]; used.metricType != auto || used.scale != 1024 {
+		t.Errorf("unexpected symbol %+v", used)
+	}
+	if state := table.Metrics[1]; state.metricType != attribute || state.values[2] != "failed" {
+		t.Errorf("unexpected column tag %+v", state)
+	}
+	if len(table.IndexComponents) != 2 || table.IndexComponents[1].name != "cpu" {
+		t.Errorf("unexpected index components %+v", table.IndexComponents)
+	}
+}
+
+func TestConvertTelegrafInputs(t *testing.T) {
+	config := `
+[agent]
+  interval = "60s"
+
+[[inputs.snmp]]
+  agents = [
+    "udp://10.0.0.1:161", # core switch
+    "udp://10.0.0.2:161",
+  ]
+  version = 2
+  community = "public"
+
+  [[inputs.snmp.field]]
+    name = "hostname"
+    oid = "RFC1213-MIB::sysName.0"
+    is_tag = true
+
+  [[inputs.snmp.field]]
+    name = "uptime"
+    oid = ".1.3.6.1.2.1.1.3.0"
+
+  [[inputs.snmp.table]]
+    name = "interface"
+    oid = "IF-MIB::ifTable"
+
+    [[inputs.snmp.table.field]]
+      name = "ifDescr"
+      oid = "IF-MIB::ifDescr"
+      is_tag = true
+
+  [[inputs.snmp.table]]
+    name = "sensors"
+
+    [[inputs.snmp.table.field]]
+      oid = ".1.3.6.1.4.1.9.9.91.1.1.1.1.4"
+      conversion = "float(1)"
+
+    [[inputs.snmp.table.field]]
+      name = "mac"
+      oid = '.1.3.6.1.2.1.2.2.1.6'
+      conversion = "hwaddr"
+
+[[outputs.influxdb]]
+  urls = ["http://127.0.0.1:8086"]
+`
+	inputs, err := parseTelegrafConfig(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 1 || len(inputs[0].agents) != 2 || len(inputs[0].fields) != 2 || len(inputs[0].tables) != 2 {
+		t.Fatalf("unexpected inputs %+v", inputs)
+	}
+	converted := convertTelegrafInputs(inputs)
+	device := converted.Collect[0]
+	if device.Device != "10.0.0.1" || len(device.MetricSets) != 3 {
+		t.Fatalf("unexpected device %+v", device)
+	}
+	if hostname := device.MetricSets[0].Metrics[0]; hostname.MetricType != "attribute" || hostname.Oid != "RFC1213-MIB::sysName.0" {
+		t.Errorf("unexpected tag %+v", hostname)
+	}
+	if table := device.MetricSets[1]; table.Table != "IF-MIB::ifTable" || table.RootOid != "" {
+		t.Errorf("unexpected MIB table %+v", table)
+	}
+
+	sensors := device.MetricSets[2]
+	metricSets := parseGeneratedCollection(t, generatedCollection{Collect: []generatedDevice{{Device: "sensors", MetricSets: []generatedMetricSet{sensors}}}})[0].MetricSets
+	value, mac := metricSets[0].Metrics[0], metricSets[0].Metrics[1]
+	if metricSets[0].RootOid != ".1.3.6.1.4.1.9.9.91.1.1.1.1.4" || value.metricType != gauge || value.scale != 0.1 {
+		t.Errorf("unexpected field %+v", value)
+	}
+	if mac.format != "mac" {
+		t.Errorf("unexpected conversion %+v", mac)
+	}
+}
+
+func TestConvertLibrenmsDefinitions(t *testing.T) {
+	osDefinition := `
+os: ios
+text: 'Cisco IOS'
+discovery:
+  - sysObjectID:
+      - .1.3.6.1.4.1.9.1.
+`
+	discoveryDefinition := `
+modules:
+  processors:
+    data:
+      - oid: cpmCPUTotal5minRev
+        num_oid: '.1.3.6.1.4.1.9.9.109.1.1.1.1.8.{{ $index }}'
+        descr: 'Processor {{ $index }}'
+  mempools:
+    data:
+      - used: .1.3.6.1.4.1.9.9.48.1.1.1.5.1
+        free: .1.3.6.1.4.1.9.9.48.1.1.1.6.1
+        descr: Processor memory
+  sensors:
+    pre-cache:
+      data:
+        - oid: entPhysicalName
+    temperature:
+      data:
+        - oid: ciscoEnvMonTemperatureStatusTable
+          value: ciscoEnvMonTemperatureStatusValue
+          num_oid: '.1.3.6.1.4.1.9.9.13.1.3.1.3.{{ $index }}'
+          descr: '{{ $ciscoEnvMonTemperatureStatusDescr }}'
+          divisor: 10
+    state:
+      data:
+        - oid: ciscoEnvMonFanStatusTable
+          value: ciscoEnvMonFanState
+          num_oid: '.1.3.6.1.4.1.9.9.13.1.4.1.3.{{ $index }}'
+          states:
+            - { value: 1, descr: normal }
+            - { value: 3, descr: critical }
+`
+	var definitions []librenmsDefinition
+	for _, source := range []string{osDefinition, discoveryDefinition} {
+		var definition librenmsDefinition
+		if err := yaml.Unmarshal([]byte(source), &definition); err != nil {
+			t.Fatal(err)
+		}
+		definitions = append(definitions, definition)
+	}
+	converted, err := convertLibrenmsDefinitions("cisco", definitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(converted.SysObjectIds) != 1 || converted.SysObjectIds[0] != ".1.3.6.1.4.1.9.1" {
+		t.Errorf("unexpected sys_object_ids %v", converted.SysObjectIds)
+	}
+	device := converted.Collect[0]
+	if device.Device != "ios" || len(device.MetricSets) != 4 {
+		t.Fatalf("unexpected device %+v", device)
+	}
+	//the temperature description references a column only known from the MIB
+	temperature := device.MetricSets[3]
+	if temperature.Attributes["descr"] != "{{.ciscoEnvMonTemperatureStatusDescr}}" || temperature.Attributes["sensorClass"] != "temperature" {
+		t.Errorf("unexpected temperature attributes %v", temperature.Attributes)
+	}
+	if len(temperature.Metrics) != 2 || *temperature.Metrics[0].Scale != 0.1 {
+		t.Errorf("unexpected temperature metrics %+v", temperature.Metrics)
+	}
+
+	device.MetricSets = device.MetricSets[:3]
+	converted.Collect[0] = device
+	metricSets := parseGeneratedCollection(t, converted)[0].MetricSets
+	if cpu := metricSets[0]; cpu.Type != "table" || cpu.RootOid != ".1.3.6.1.4.1.9.9.109.1.1.1.1.8" || len(cpu.Attributes) != 1 {
+		t.Errorf("unexpected processor %+v", cpu)
+	}
+	if memory := metricSets[1]; memory.Type != "scalar" || len(memory.Metrics) != 2 || memory.Metrics[1].metricName != "memoryFree" {
+		t.Errorf("unexpected memory pool %+v", memory)
+	}
+	if state := metricSets[2].Metrics[0]; state.values[3] != "critical" || !state.emitCode {
+		t.Errorf("unexpected state sensor %+v", state)
+	}
+}
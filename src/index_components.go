@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/soniah/gosnmp"
+)
+
+// indexComponent describes one part of a composite table index, decoded
+// from the index key (the OID suffix of the row) instead of read from a column
+type indexComponent struct {
+	name    string
+	decoder indexDecoder
+	length  int
+}
+
+// indexTemplate builds a single display attribute from the decoded index values
+type indexTemplate struct {
+	name   string
+	format string
+}
+
+// indexDecoder consumes the arcs of one index component from the front of an
+// index key, returning the decoded value and the remaining arcs
+type indexDecoder func(component *indexComponent, arcs []uint64) (string, []uint64, error)
+
+var (
+	// indexDecoders maps the index component types accepted in yaml to their decoder
+	indexDecoders = map[string]indexDecoder{
+		"integer":        decodeIntegerComponent,
+		"string":         decodeStringComponent,
+		"implied_string": decodeImpliedStringComponent,
+		"ipv4":           decodeIPv4Component,
+		"oid":            decodeOidComponent,
+		"inet_address":   decodeInetAddressComponent,
+		"mac":            decodeMacComponent,
+	}
+
+	// templatePlaceholder matches the `{name}` placeholders of an index template
+	templatePlaceholder = regexp.MustCompile(`\{([^{}]+)\}`)
+)
+
+// decodeIndexComponents splits an index key into the values of its components
+func decodeIndexComponents(components []*indexComponent, indexKey string) (map[string]string, error) {
+	var arcs []uint64
+	for _, arc := range oidArcs(indexKey) {
+		n, err := strconv.ParseUint(arc, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index key %s", indexKey)
+		}
+		arcs = append(arcs, n)
+	}
+	values := make(map[string]string)
+	for _, component := range components {
+		value, rest, err := component.decoder(component, arcs)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode index component %s of %s: %v", component.name, indexKey, err)
+		}
+		values[component.name] = value
+		arcs = rest
+	}
+	if len(arcs) > 0 {
+		return values, fmt.Errorf("index key %s has %d arcs left after decoding its components", indexKey, len(arcs))
+	}
+	return values, nil
+}
+
+func decodeIntegerComponent(component *indexComponent, arcs []uint64) (string, []uint64, error) {
+	if len(arcs) < 1 {
+		return "", nil, fmt.Errorf("missing arc")
+	}
+	return strconv.FormatUint(arcs[0], 10), arcs[1:], nil
+}
+
+// decodeStringComponent decodes an OCTET STRING index, prefixed with its length
+// unless the component declares a fixed length
+func decodeStringComponent(component *indexComponent, arcs []uint64) (string, []uint64, error) {
+	length := component.length
+	if length == 0 {
+		if len(arcs) < 1 {
+			return "", nil, fmt.Errorf("missing length arc")
+		}
+		length, arcs = int(arcs[0]), arcs[1:]
+	}
+	if len(arcs) < length {
+		return "", nil, fmt.Errorf("expected %d arcs, found %d", length, len(arcs))
+	}
+	return validUTF8(trimString(arcsToString(arcs[:length]))), arcs[length:], nil
+}
+
+// decodeImpliedStringComponent decodes an IMPLIED OCTET STRING, which takes every remaining arc
+func decodeImpliedStringComponent(component *indexComponent, arcs []uint64) (string, []uint64, error) {
+	return validUTF8(trimString(arcsToString(arcs))), nil, nil
+}
+
+func decodeIPv4Component(component *indexComponent, arcs []uint64) (string, []uint64, error) {
+	if len(arcs) < 4 {
+		return "", nil, fmt.Errorf("expected 4 arcs, found %d", len(arcs))
+	}
+	return fmt.Sprintf("%d.%d.%d.%d", arcs[0], arcs[1], arcs[2], arcs[3]), arcs[4:], nil
+}
+
+// decodeMacComponent decodes a MacAddress index, 6 arcs unless the component
+// declares another fixed length
+func decodeMacComponent(component *indexComponent, arcs []uint64) (string, []uint64, error) {
+	length := component.length
+	if length == 0 {
+		length = 6
+	}
+	if len(arcs) < length {
+		return "", nil, fmt.Errorf("expected %d arcs, found %d", length, len(arcs))
+	}
+	return formatMacAddress([]byte(arcsToString(arcs[:length]))), arcs[length:], nil
+}
+
+// decodeOidComponent decodes an OBJECT IDENTIFIER index, prefixed with its length
+func decodeOidComponent(component *indexComponent, arcs []uint64) (string, []uint64, error) {
+	if len(arcs) < 1 {
+		return "", nil, fmt.Errorf("missing length arc")
+	}
+	length, arcs := int(arcs[0]), arcs[1:]
+	if len(arcs) < length {
+		return "", nil, fmt.Errorf("expected %d arcs, found %d", length, len(arcs))
+	}
+	return arcsToOid(arcs[:length]), arcs[length:], nil
+}
+
+func arcsToString(arcs []uint64) string {
+	b := make([]byte, len(arcs))
+	for i, arc := range arcs {
+		b[i] = byte(arc)
+	}
+	return string(b)
+}
+
+func arcsToOid(arcs []uint64) string {
+	var sb strings.Builder
+	for _, arc := range arcs {
+		sb.WriteString(".")
+		sb.WriteString(strconv.FormatUint(arc, 10))
+	}
+	return sb.String()
+}
+
+// render replaces every `{name}` placeholder with the matching index value
+func (t *indexTemplate) render(values map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(t.format, func(placeholder string) string {
+		return values[strings.TrimSpace(placeholder[1:len(placeholder)-1])]
+	})
+}
+
+// decodeInetAddressComponent decodes an InetAddressType, InetAddress index pair.
+// DNS names (type 16) are decoded as strings, every other type as an address
+func decodeInetAddressComponent(component *indexComponent, arcs []uint64) (string, []uint64, error) {
+	if len(arcs) < 2 {
+		return "", nil, fmt.Errorf("missing address type or length arc")
+	}
+	addressType, length, arcs := arcs[0], int(arcs[1]), arcs[2:]
+	if len(arcs) < length {
+		return "", nil, fmt.Errorf("expected %d arcs, found %d", length, len(arcs))
+	}
+	value := arcsToString(arcs[:length])
+	if addressType == 16 {
+		return validUTF8(value), arcs[length:], nil
+	}
+	address, err := formatInetAddress([]byte(value))
+	if err != nil {
+		return "", nil, err
+	}
+	return address, arcs[length:], nil
+}
+
+// indexTransform converts the value of an index column into the attribute reported for it
+type indexTransform func(pdu gosnmp.SnmpPDU) (string, error)
+
+// indexTransforms maps the index `transform` names accepted in yaml to their
+// implementation. Vendor specific encodings are supported by registering them here
+var indexTransforms = map[string]indexTransform{
+	"inet_address": func(pdu gosnmp.SnmpPDU) (string, error) {
+		b, ok := pdu.Value.([]byte)
+		if !ok {
+			return "", fmt.Errorf("inet_address transform requires an OctetString, Oid[%s]", pdu.Name)
+		}
+		return formatInetAddress(b)
+	},
+	"date_and_time": func(pdu gosnmp.SnmpPDU) (string, error) {
+		b, ok := pdu.Value.([]byte)
+		if !ok {
+			return "", fmt.Errorf("date_and_time transform requires an OctetString, Oid[%s]", pdu.Name)
+		}
+		return formatDateAndTime(b)
+	},
+	"row_pointer": func(pdu gosnmp.SnmpPDU) (string, error) {
+		oid, ok := pdu.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("row_pointer transform requires an ObjectIdentifier, Oid[%s]", pdu.Name)
+		}
+		return mibs.translate(oid), nil
+	},
+	"mac": func(pdu gosnmp.SnmpPDU) (string, error) {
+		b, ok := pdu.Value.([]byte)
+		if !ok {
+			return "", fmt.Errorf("mac transform requires an OctetString, Oid[%s]", pdu.Name)
+		}
+		return formatMacAddress(b), nil
+	},
+	"hex": func(pdu gosnmp.SnmpPDU) (string, error) {
+		b, ok := pdu.Value.([]byte)
+		if !ok {
+			return "", fmt.Errorf("hex transform requires an OctetString, Oid[%s]", pdu.Name)
+		}
+		return hex.EncodeToString(b), nil
+	},
+	"base64": func(pdu gosnmp.SnmpPDU) (string, error) {
+		b, ok := pdu.Value.([]byte)
+		if !ok {
+			return "", fmt.Errorf("base64 transform requires an OctetString, Oid[%s]", pdu.Name)
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	},
+}
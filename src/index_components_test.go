@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDecodeIndexComponents(t *testing.T) {
+	components := []*indexComponent{
+		{name: "srcAddr", decoder: decodeIPv4Component},
+		{name: "srcPort", decoder: decodeIntegerComponent},
+		{name: "dstAddr", decoder: decodeIPv4Component},
+		{name: "dstPort", decoder: decodeIntegerComponent},
+	}
+	values, err := decodeIndexComponents(components, "10.0.0.1.5432.192.168.1.20.61000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &indexTemplate{name: "connection", format: "{srcAddr}:{srcPort}->{dstAddr}"}
+	if display := template.render(values); display != "10.0.0.1:5432->192.168.1.20" {
+		t.Errorf("unexpected display value %s", display)
+	}
+	if values["dstPort"] != "61000" {
+		t.Errorf("unexpected dstPort %s", values["dstPort"])
+	}
+}
+
+func TestDecodeStringComponents(t *testing.T) {
+	components := []*indexComponent{
+		{name: "owner", decoder: decodeStringComponent},
+		{name: "code", decoder: decodeStringComponent, length: 2},
+		{name: "rest", decoder: decodeImpliedStringComponent},
+	}
+	values, err := decodeIndexComponents(components, "3.98.111.98.65.66.120.121")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["owner"] != "bob" || values["code"] != "AB" || values["rest"] != "xy" {
+		t.Errorf("unexpected values %v", values)
+	}
+	if _, err := decodeIndexComponents(components[:1], "5.98.111"); err == nil {
+		t.Error("expected error for short index key")
+	}
+}
+
+func TestDecodeInetAddressComponent(t *testing.T) {
+	components := []*indexComponent{
+		{name: "local", decoder: decodeInetAddressComponent},
+		{name: "remote", decoder: decodeInetAddressComponent},
+	}
+	values, err := decodeIndexComponents(components, "1.4.10.0.0.1.2.16.254.128.0.0.0.0.0.0.0.0.0.0.0.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["local"] != "10.0.0.1" || values["remote"] != "fe80::1" {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestDecodeMacComponent(t *testing.T) {
+	components := []*indexComponent{
+		{name: "vlan", decoder: decodeIntegerComponent},
+		{name: "address", decoder: decodeMacComponent},
+	}
+	values, err := decodeIndexComponents(components, "10.0.27.33.171.205.239")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["vlan"] != "10" || values["address"] != "00:1b:21:ab:cd:ef" {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestDecodeComponentsEndingInPadding(t *testing.T) {
+	components := []*indexComponent{
+		{name: "address", decoder: decodeMacComponent},
+		{name: "network", decoder: decodeInetAddressComponent},
+		{name: "name", decoder: decodeStringComponent},
+	}
+	values, err := decodeIndexComponents(components, "0.27.33.171.205.32.1.4.10.1.0.0.4.101.116.104.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["address"] != "00:1b:21:ab:cd:20" || values["network"] != "10.1.0.0" || values["name"] != "eth" {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestFormatDateAndTime(t *testing.T) {
+	value, err := formatDateAndTime([]byte{0x07, 0xe3, 11, 18, 13, 30, 15, 0, '+', 1, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "2019-11-18T13:30:15.0+01:00" {
+		t.Errorf("unexpected value %s", value)
+	}
+	if _, err := formatDateAndTime([]byte{1, 2, 3}); err == nil {
+		t.Error("expected error for short DateAndTime")
+	}
+	parsed, err := parseDateAndTime([]byte{0x07, 0xe3, 11, 18, 13, 30, 15, 0, '+', 1, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Unix() != 1574080215 {
+		t.Errorf("unexpected epoch %d", parsed.Unix())
+	}
+}
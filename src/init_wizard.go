@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// defaultConfigDir is where the infrastructure agent reads the configuration
+// of integrations
+const defaultConfigDir = "/etc/newrelic-infra/integrations.d"
+
+// starterCollection is the collection file written by the init wizard, with
+// the system group every SNMP agent implements
+const starterCollection = `# Starter collection file written by nri-snmp -init.
+# Add the scalars and tables of your device below, nri-snmp -from_walk
+# proposes them from an snmpwalk of the device.
+collect:
+- device: %s
+  metric_sets:
+  - name: system
+    type: scalar
+    event_type: SNMPSample
+    metrics:
+    - metric_name: sysDescr
+      oid: .1.3.6.1.2.1.1.1.0
+      metric_type: attribute
+    - metric_name: sysUpTime
+      oid: .1.3.6.1.2.1.1.3.0
+      metric_type: gauge
+    - metric_name: sysContact
+      oid: .1.3.6.1.2.1.1.4.0
+      metric_type: attribute
+    - metric_name: sysName
+      oid: .1.3.6.1.2.1.1.5.0
+      metric_type: attribute
+    - metric_name: sysLocation
+      oid: .1.3.6.1.2.1.1.6.0
+      metric_type: attribute
+`
+
+// wizard asks the questions of the init wizard
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask asks a question and returns the answer, or the default answer when
+// the answer is empty
+func (w *wizard) ask(question string, defaultAnswer string) string {
+	if defaultAnswer != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, defaultAnswer)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", question)
+	}
+	answer, _ := w.in.ReadString('\n')
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return defaultAnswer
+	}
+	return answer
+}
+
+// choose asks a question until the answer is one of the choices
+func (w *wizard) choose(question string, defaultAnswer string, choices ...string) (string, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		answer := w.ask(fmt.Sprintf("%s (%s)", question, strings.Join(choices, ", ")), defaultAnswer)
+		for _, choice := range choices {
+			if strings.EqualFold(answer, choice) {
+				return choice, nil
+			}
+		}
+		fmt.Fprintf(w.out, "%s is not one of %s\n", answer, strings.Join(choices, ", "))
+	}
+	return "", fmt.Errorf("no valid answer to %q", question)
+}
+
+// confirm asks a yes or no question, no by default
+func (w *wizard) confirm(question string) bool {
+	answer := strings.ToLower(w.ask(question+" (y/N)", ""))
+	return answer == "y" || answer == "yes"
+}
+
+// availableProfiles lists the names of the profiles found in the profile
+// directories
+func availableProfiles() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, dir := range profileDirs() {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.yml"))
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), ".yml")
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runInitWizard asks for the target, its SNMP version and credentials and
+// the profiles to collect, tests the connectivity to the target and writes
+// the integration configuration and a starter collection file
+func runInitWizard(in io.Reader, out io.Writer, test func() (string, error)) error {
+	w := &wizard{in: bufio.NewReader(in), out: out}
+	arguments := yaml.MapSlice{}
+	argument := func(name string, value interface{}) {
+		arguments = append(arguments, yaml.MapItem{Key: name, Value: value})
+	}
+
+	args.SNMPHost = w.ask("SNMP host", "127.0.0.1")
+	port, err := strconv.Atoi(w.ask("SNMP port", "161"))
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port, valid values are 1 to 65535")
+	}
+	args.SNMPPort = port
+	argument("snmp_host", args.SNMPHost)
+	argument("snmp_port", args.SNMPPort)
+
+	version, err := w.choose("SNMP version", "v2c", "v2c", "v3")
+	if err != nil {
+		return err
+	}
+	args.V3 = version == "v3"
+	if args.V3 {
+		if args.SecurityLevel, err = w.choose("Security level", "authPriv", "noAuthNoPriv", "authNoPriv", "authPriv"); err != nil {
+			return err
+		}
+		args.Username = w.ask("Username", "")
+		argument("v3", true)
+		argument("security_level", args.SecurityLevel)
+		argument("username", args.Username)
+		if args.SecurityLevel != "noAuthNoPriv" {
+			if args.AuthProtocol, err = w.choose("Authentication protocol", "SHA", "SHA", "MD5"); err != nil {
+				return err
+			}
+			args.AuthPassphrase = w.ask("Authentication passphrase", "")
+			argument("auth_protocol", args.AuthProtocol)
+			argument("auth_passphrase", args.AuthPassphrase)
+		}
+		if args.SecurityLevel == "authPriv" {
+			if args.PrivProtocol, err = w.choose("Privacy protocol", "AES", "AES", "DES"); err != nil {
+				return err
+			}
+			args.PrivPassphrase = w.ask("Privacy passphrase", "")
+			argument("priv_protocol", args.PrivProtocol)
+			argument("priv_passphrase", args.PrivPassphrase)
+		}
+	} else {
+		args.Community = w.ask("Community", "public")
+		argument("community", args.Community)
+	}
+
+	if profiles := availableProfiles(); len(profiles) > 0 {
+		fmt.Fprintf(w.out, "Profiles: %s\n", strings.Join(profiles, ", "))
+	}
+	args.Profiles = w.ask("Profiles to collect, comma separated, auto to select one by the sysObjectID of the device, none for none", autoProfile)
+	if args.Profiles == "none" {
+		args.Profiles = ""
+	}
+
+	targetHost, targetPort = args.SNMPHost, args.SNMPPort
+	summary, err := test()
+	if err != nil {
+		fmt.Fprintf(w.out, "FAIL %s:%d %v\n", targetHost, targetPort, err)
+		if !w.confirm("Write the configuration anyway?") {
+			return fmt.Errorf("the target could not be read, nothing was written")
+		}
+	} else {
+		fmt.Fprintf(w.out, "PASS %s:%d %s\n", targetHost, targetPort, summary)
+	}
+
+	dir, err := filepath.Abs(w.ask("Configuration directory", defaultConfigDir))
+	if err != nil {
+		return err
+	}
+	collectionFile := filepath.Join(dir, "snmp-metrics.yml")
+	argument("collection_files", collectionFile)
+	if args.Profiles != "" {
+		argument("profiles", args.Profiles)
+	}
+	config, err := yaml.Marshal(yaml.MapSlice{
+		{Key: "integration_name", Value: integrationName},
+		{Key: "instances", Value: []yaml.MapSlice{{
+			{Key: "name", Value: "snmp-" + args.SNMPHost},
+			{Key: "command", Value: "metrics"},
+			{Key: "arguments", Value: arguments},
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		path    string
+		content []byte
+		mode    os.FileMode
+	}{
+		//the configuration holds the credentials
+		{filepath.Join(dir, "snmp-config.yml"), config, 0600},
+		{collectionFile, []byte(fmt.Sprintf(starterCollection, args.SNMPHost)), 0644},
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file.path); err == nil && !w.confirm(fmt.Sprintf("Overwrite %s?", file.path)) {
+			return fmt.Errorf("%s exists and was not overwritten", file.path)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := ioutil.WriteFile(file.path, file.content, file.mode); err != nil {
+			return err
+		}
+		fmt.Fprintf(w.out, "wrote %s\n", file.path)
+	}
+	return nil
+}
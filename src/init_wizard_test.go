@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunInitWizard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { args = argumentList{} }()
+	args.ProfileDirs = "../profiles"
+
+	answers := strings.Join([]string{"192.0.2.1", "", "v3", "authpriv", "monitoring", "", "s3cr3t", "des", "pr1v4te", "generic-if", dir}, "\n") + "\n"
+	var out bytes.Buffer
+	tested := false
+	err = runInitWizard(strings.NewReader(answers), &out, func() (string, error) {
+		tested = true
+		if targetHost != "192.0.2.1" || targetPort != 161 || !args.V3 || args.PrivProtocol != "DES" {
+			t.Errorf("unexpected arguments tested %+v", args)
+		}
+		return "sysDescr=\"router\"", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tested || !strings.Contains(out.String(), "PASS 192.0.2.1:161") || !strings.Contains(out.String(), "generic-if") {
+		t.Errorf("unexpected output %s", out.String())
+	}
+	config, err := ioutil.ReadFile(filepath.Join(dir, "snmp-config.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"snmp_host: 192.0.2.1", "security_level: authPriv", "priv_passphrase: pr1v4te", "profiles: generic-if", "collection_files: " + filepath.Join(dir, "snmp-metrics.yml")} {
+		if !strings.Contains(string(config), expected) {
+			t.Errorf("expected %q in %s", expected, config)
+		}
+	}
+	if _, err := loadCollectionFile(filepath.Join(dir, "snmp-metrics.yml")); err != nil {
+		t.Errorf("invalid starter collection file: %v", err)
+	}
+
+	//a failed test writes nothing unless confirmed, existing files are only overwritten when confirmed
+	answers = strings.Join([]string{"192.0.2.2", "", "", "", "none", "n"}, "\n") + "\n"
+	err = runInitWizard(strings.NewReader(answers), &out, func() (string, error) {
+		return "", errors.New("request timeout")
+	})
+	if err == nil {
+		t.Error("expected error when the configuration is not written")
+	}
+	answers = strings.Join([]string{"192.0.2.2", "", "", "", "none", dir, "n"}, "\n") + "\n"
+	err = runInitWizard(strings.NewReader(answers), &out, func() (string, error) {
+		return "", nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "not overwritten") {
+		t.Errorf("expected the configuration not to be overwritten, got %v", err)
+	}
+}
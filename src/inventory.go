@@ -58,7 +58,7 @@ func populateInventory(inventoryItems []inventoryItem, entity *integration.Entit
 
 		switch variable.Type {
 		case gosnmp.OctetString:
-			value = string(variable.Value.([]byte))
+			value = validUTF8(trimString(string(variable.Value.([]byte))))
 		case gosnmp.Gauge32, gosnmp.Counter32, gosnmp.Counter64, gosnmp.Integer, gosnmp.Uinteger32:
 			value = gosnmp.ToBigInt(variable.Value)
 		case gosnmp.ObjectIdentifier, gosnmp.IPAddress:
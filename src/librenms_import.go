@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// librenmsDefinition is a struct to aid the automatic parsing of a LibreNMS
+// OS definition (`os` and `discovery`) or discovery definition (`modules`)
+type librenmsDefinition struct {
+	Os        string `yaml:"os"`
+	Discovery []struct {
+		SysObjectId stringList `yaml:"sysObjectID"`
+	} `yaml:"discovery"`
+	Modules struct {
+		Processors librenmsModule            `yaml:"processors"`
+		Mempools   librenmsModule            `yaml:"mempools"`
+		Sensors    map[string]librenmsModule `yaml:"sensors"`
+	} `yaml:"modules"`
+}
+
+type librenmsModule struct {
+	Data []librenmsData `yaml:"data"`
+}
+
+// librenmsData is a discovered processor, memory pool or sensor
+type librenmsData struct {
+	Oid        stringList `yaml:"oid"`
+	NumOid     string     `yaml:"num_oid"`
+	Value      string     `yaml:"value"`
+	Descr      string     `yaml:"descr"`
+	Divisor    float64    `yaml:"divisor"`
+	Multiplier float64    `yaml:"multiplier"`
+	Precision  float64    `yaml:"precision"`
+	States     []struct {
+		Value int    `yaml:"value"`
+		Descr string `yaml:"descr"`
+	} `yaml:"states"`
+	Total       string `yaml:"total"`
+	Used        string `yaml:"used"`
+	Free        string `yaml:"free"`
+	PercentUsed string `yaml:"percent_used"`
+}
+
+// librenmsVariable matches the `{{ $name }}` variables of LibreNMS descriptions
+var librenmsVariable = regexp.MustCompile(`\{\{\s*\$(\w+)\s*\}\}`)
+
+// importLibrenmsDefinitions converts a comma separated list of LibreNMS OS and
+// discovery definitions of the same OS into a profile
+func importLibrenmsDefinitions(paths string) ([]byte, error) {
+	var definitions []librenmsDefinition
+	name := ""
+	for _, path := range strings.Split(paths, ",") {
+		path = strings.TrimSpace(path)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var definition librenmsDefinition
+		if err := yaml.Unmarshal(data, &definition); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		definitions = append(definitions, definition)
+	}
+	collection, err := convertLibrenmsDefinitions(name, definitions)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(collection)
+}
+
+// convertLibrenmsDefinitions maps processors to SNMPCpuSample, memory pools to
+// SNMPMemorySample and sensors to SNMPSensorSample metric sets, one per
+// discovered entry, with the LibreNMS description as an attribute template.
+// The variables of descriptions and the symbolic OIDs need the MIBs of the OS in mib_dirs
+func convertLibrenmsDefinitions(name string, definitions []librenmsDefinition) (generatedCollection, error) {
+	var collection generatedCollection
+	var metricSets []generatedMetricSet
+	for _, definition := range definitions {
+		if definition.Os != "" {
+			name = definition.Os
+		}
+		for _, discovery := range definition.Discovery {
+			for _, sysObjectId := range discovery.SysObjectId {
+				//LibreNMS prefixes end with a dot, e.g. `.1.3.6.1.4.1.9.1.`
+				collection.SysObjectIds = append(collection.SysObjectIds, normalizeOid(strings.TrimSuffix(sysObjectId, ".")))
+			}
+		}
+		for i, data := range definition.Modules.Processors.Data {
+			divisor := data.Precision
+			if divisor == 0 {
+				divisor = data.Divisor
+			}
+			data.Divisor = divisor
+			if metricSet, ok := librenmsMetricSet(fmt.Sprintf("processor%d", i+1), "SNMPCpuSample", "cpuPercent", data, nil); ok {
+				metricSets = append(metricSets, metricSet)
+			}
+		}
+		for i, data := range definition.Modules.Mempools.Data {
+			if metricSet, ok := librenmsMempool(fmt.Sprintf("mempool%d", i+1), data); ok {
+				metricSets = append(metricSets, metricSet)
+			}
+		}
+		var classes []string
+		for class := range definition.Modules.Sensors {
+			if class != "pre-cache" {
+				classes = append(classes, class)
+			}
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			for i, data := range definition.Modules.Sensors[class].Data {
+				attributes := map[string]string{"sensorClass": class}
+				if metricSet, ok := librenmsMetricSet(fmt.Sprintf("%s%d", class, i+1), "SNMPSensorSample", "sensorValue", data, attributes); ok {
+					metricSets = append(metricSets, metricSet)
+				}
+			}
+		}
+	}
+	if len(metricSets) == 0 {
+		return collection, fmt.Errorf("no processors, memory pools or sensors found in the definitions of %s", name)
+	}
+	collection.Collect = []generatedDevice{{Device: name, MetricSets: metricSets}}
+	return collection, nil
+}
+
+// librenmsMetricSet converts a processor or a sensor. The `{{ $index }}` of
+// num_oid makes it a table column, which the value of the definition names otherwise
+func librenmsMetricSet(setName string, eventType string, metricName string, data librenmsData, attributes map[string]string) (generatedMetricSet, bool) {
+	oid, isColumn := librenmsOid(data.NumOid, data.Value, data)
+	if oid == "" {
+		return generatedMetricSet{}, false
+	}
+	metricSet := generatedMetricSet{Name: setName, Type: "scalar", EventType: eventType, Attributes: attributes}
+	if isColumn {
+		metricSet.Type, metricSet.RootOid = "table", oid
+	}
+	value := generatedMetric{MetricName: metricName, Oid: oid, MetricType: "gauge"}
+	if scale := librenmsScale(data); scale != 1 {
+		value.Scale = &scale
+	}
+	if len(data.States) > 0 {
+		value.MetricType, value.EmitCode = "", true
+		value.Values = make(map[int]string)
+		for _, state := range data.States {
+			value.Values[state.Value] = state.Descr
+		}
+	}
+	metricSet.Metrics = append(metricSet.Metrics, value)
+	metricSet.Metrics = append(metricSet.Metrics, librenmsDescr(&metricSet, data.Descr)...)
+	return metricSet, true
+}
+
+// librenmsMempool converts a memory pool, whose total, used, free and
+// percent_used are each a scalar OID or a column
+func librenmsMempool(setName string, data librenmsData) (generatedMetricSet, bool) {
+	metricSet := generatedMetricSet{Name: setName, Type: "scalar", EventType: "SNMPMemorySample"}
+	fields := []struct{ name, oid string }{
+		{"memoryTotal", data.Total},
+		{"memoryUsed", data.Used},
+		{"memoryFree", data.Free},
+		{"memoryPercentUsed", data.PercentUsed},
+	}
+	scale := librenmsScale(data)
+	for _, field := range fields {
+		oid, isColumn := librenmsOid(field.oid, field.oid, data)
+		if oid == "" {
+			continue
+		}
+		if isColumn && metricSet.Type == "scalar" {
+			metricSet.Type, metricSet.RootOid = "table", oid
+		}
+		metric := generatedMetric{MetricName: field.name, Oid: oid, MetricType: "gauge"}
+		if scale != 1 && field.name != "memoryPercentUsed" {
+			metric.Scale = &scale
+		}
+		metricSet.Metrics = append(metricSet.Metrics, metric)
+	}
+	if len(metricSet.Metrics) == 0 {
+		return metricSet, false
+	}
+	metricSet.Metrics = append(metricSet.Metrics, librenmsDescr(&metricSet, data.Descr)...)
+	return metricSet, true
+}
+
+// librenmsOid returns the OID of a value and whether it is a table column.
+// A numeric OID is a column when it ends with `{{ $index }}`. A symbolic
+// name is a column of the table walked by the definition unless it has an index
+func librenmsOid(numOid string, value string, data librenmsData) (string, bool) {
+	numOid = strings.TrimSpace(numOid)
+	if i := strings.Index(numOid, "{{"); i >= 0 {
+		return normalizeOid(strings.TrimSuffix(strings.TrimSpace(numOid[:i]), ".")), true
+	}
+	if numOid != "" && strings.Trim(numOid, ".0123456789") == "" {
+		return normalizeOid(numOid), false
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", false
+	}
+	return value, len(data.Oid) > 0 && !strings.Contains(value, ".")
+}
+
+// librenmsScale combines the multiplier and divisor of a definition
+func librenmsScale(data librenmsData) float64 {
+	scale := 1.0
+	if data.Multiplier != 0 {
+		scale *= data.Multiplier
+	}
+	if data.Divisor != 0 {
+		scale /= data.Divisor
+	}
+	return scale
+}
+
+// librenmsDescr converts a description to a `descr` attribute template and
+// returns the attribute metrics of the variables it references
+func librenmsDescr(metricSet *generatedMetricSet, descr string) []generatedMetric {
+	if strings.TrimSpace(descr) == "" {
+		return nil
+	}
+	var variables []generatedMetric
+	seen := make(map[string]bool)
+	template := librenmsVariable.ReplaceAllStringFunc(descr, func(variable string) string {
+		name := librenmsVariable.FindStringSubmatch(variable)[1]
+		if name != "index" && !seen[name] {
+			seen[name] = true
+			variables = append(variables, generatedMetric{MetricName: name, Oid: name, MetricType: "attribute"})
+		}
+		return "{{." + name + "}}"
+	})
+	if metricSet.Attributes == nil {
+		metricSet.Attributes = make(map[string]string)
+	}
+	metricSet.Attributes["descr"] = template
+	return variables
+}
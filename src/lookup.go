@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/soniah/gosnmp"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// lookupFiles caches the lookup files by path, as every metric of a table
+// may share the same file
+var lookupFiles = make(map[string]map[string]string)
+
+// loadLookupFile reads a lookup file mapping values to labels. Files with a
+// .csv extension hold value,label records, any other file is a YAML mapping.
+// OID keys are accepted with or without their leading dot
+func loadLookupFile(file string) (map[string]string, error) {
+	if !filepath.IsAbs(file) {
+		return nil, fmt.Errorf("lookup file %s must be an absolute path", file)
+	}
+	if lookup, ok := lookupFiles[file]; ok {
+		return lookup, nil
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]string)
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		reader := csv.NewReader(bytes.NewReader(content))
+		reader.FieldsPerRecord = 2
+		reader.Comment = '#'
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse %s: %v", file, err)
+			}
+			entries[record[0]] = record[1]
+		}
+	} else if err := yaml.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", file, err)
+	}
+	lookup := make(map[string]string, len(entries))
+	for value, label := range entries {
+		lookup[strings.TrimPrefix(strings.TrimSpace(value), ".")] = strings.TrimSpace(label)
+	}
+	lookupFiles[file] = lookup
+	return lookup, nil
+}
+
+// lookupKey returns the key a PDU value is looked up by
+func lookupKey(pdu gosnmp.SnmpPDU) (string, bool) {
+	switch pdu.Type {
+	case gosnmp.OctetString:
+		if b, ok := pdu.Value.([]byte); ok {
+			return trimString(string(b)), true
+		}
+	case gosnmp.ObjectIdentifier, gosnmp.IPAddress:
+		if v, ok := pdu.Value.(string); ok {
+			return strings.TrimPrefix(v, "."), true
+		}
+	case gosnmp.Gauge32, gosnmp.Counter32, gosnmp.Counter64, gosnmp.Integer, gosnmp.Uinteger32, gosnmp.TimeTicks:
+		return gosnmp.ToBigInt(pdu.Value).String(), true
+	}
+	return "", false
+}
+
+// setLookupMetric reports the label the value of a metric maps to in its
+// lookup file as the `<metricName>Label` attribute, or the configured
+// lookup_attribute. Values missing from the file are not reported
+func setLookupMetric(def *metricDef, metricName string, pdu gosnmp.SnmpPDU, ms *metric.Set) error {
+	key, ok := lookupKey(pdu)
+	if !ok {
+		return nil
+	}
+	label, ok := def.lookup[key]
+	if !ok {
+		return nil
+	}
+	attribute := def.lookupAttribute
+	if attribute == "" {
+		attribute = metricName + "Label"
+	}
+	return ms.SetMetric(attribute, label, metric.ATTRIBUTE)
+}
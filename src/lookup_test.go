@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/soniah/gosnmp"
+)
+
+func TestLookupMetric(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "models.csv")
+	content := "# sysObjectID,model\n.1.3.6.1.4.1.9.1.1208,Catalyst 2960X\n1.3.6.1.4.1.9.1.2066,ISR 4331\n"
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lookup, err := loadLookupFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := metric.NewSet("TestSample", nil)
+	def := &metricDef{lookup: lookup}
+	pdu := gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.1.2.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.9.1.2066"}
+	if err := setLookupMetric(def, "sysObjectID", pdu, ms); err != nil {
+		t.Fatal(err)
+	}
+	if ms.Metrics["sysObjectIDLabel"] != "ISR 4331" {
+		t.Errorf("unexpected metrics %v", ms.Metrics)
+	}
+	if _, err := loadLookupFile("models.csv"); err == nil {
+		t.Error("expected error for a relative lookup file")
+	}
+}
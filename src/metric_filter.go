@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/integration"
+	"github.com/newrelic/infra-integrations-sdk/log"
+)
+
+// metricFilter is the allowlist and the blocklist of the metric_allowlist and
+// metric_blocklist arguments, applied to every collection file and profile
+type metricFilter struct {
+	allow []string
+	block []string
+}
+
+// metricFilters is the filter of the arguments, nil when there is none
+var metricFilters *metricFilter
+
+// parseMetricFilter reads comma separated lists of patterns: globs matching
+// metric names, such as `ifHC*`, or numeric OIDs, such as
+// `.1.3.6.1.2.1.31.1.1.1`, matching the metrics read under them
+func parseMetricFilter(allowlist string, blocklist string) (*metricFilter, error) {
+	split := func(list string) ([]string, error) {
+		var patterns []string
+		for _, pattern := range strings.Split(list, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern == "" {
+				continue
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid metric pattern %s", pattern)
+			}
+			if isOidPattern(pattern) {
+				pattern = normalizeOid(pattern)
+			}
+			patterns = append(patterns, pattern)
+		}
+		return patterns, nil
+	}
+	allow, err := split(allowlist)
+	if err != nil {
+		return nil, err
+	}
+	block, err := split(blocklist)
+	if err != nil {
+		return nil, err
+	}
+	if len(allow) == 0 && len(block) == 0 {
+		return nil, nil
+	}
+	return &metricFilter{allow: allow, block: block}, nil
+}
+
+// isOidPattern reports whether a pattern is a numeric OID
+func isOidPattern(pattern string) bool {
+	return strings.Trim(pattern, ".0123456789") == ""
+}
+
+// matches reports whether a metric name or OID matches one of the patterns
+func matches(patterns []string, name string, oid string) bool {
+	for _, pattern := range patterns {
+		if isOidPattern(pattern) {
+			if oid != "" && oidHasPrefix(oid, pattern) {
+				return true
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// reports reports whether a metric is reported: it matches no pattern of the
+// blocklist and, when there is an allowlist, a pattern of the allowlist
+func (f *metricFilter) reports(name string, oid string) bool {
+	if f == nil {
+		return true
+	}
+	if matches(f.block, name, oid) {
+		return false
+	}
+	return len(f.allow) == 0 || matches(f.allow, name, oid)
+}
+
+// filterCollection hides the configured metrics the filter does not report.
+// Hidden metrics are still read when derived metrics are computed from them
+func (f *metricFilter) filterCollection(collection *collection) {
+	if f == nil {
+		return
+	}
+	for _, metricSet := range collection.MetricSets {
+		for _, def := range metricSet.Metrics {
+			if def.hidden {
+				continue
+			}
+			name := def.metricName
+			if name == "" {
+				name = mibs.objectName(def.oid)
+			}
+			if !f.reports(name, def.oid) {
+				log.Debug("metric %s of metric set %s is filtered out", name, metricSet.Name)
+				def.hidden = true
+			}
+		}
+	}
+}
+
+// filterPayload removes the metrics named by the blocklist from every metric
+// set of the payload, such as derived metrics and the columns reported by
+// collect_all_columns, which are only named once collected
+func (f *metricFilter) filterPayload(i *integration.Integration) {
+	if f == nil || len(f.block) == 0 {
+		return
+	}
+	for _, entity := range i.Entities {
+		for _, ms := range entity.Metrics {
+			for name := range ms.Metrics {
+				if name != "event_type" && matches(f.block, name, "") {
+					delete(ms.Metrics, name)
+				}
+			}
+		}
+	}
+}
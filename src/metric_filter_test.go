@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/integration"
+)
+
+func TestMetricFilter(t *testing.T) {
+	filter, err := parseMetricFilter("if*, .1.3.6.1.2.1.1", "ifHC*,1.3.6.1.2.1.2.2.1.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name, oid string
+		reported  bool
+	}{
+		{"ifOutOctets", ".1.3.6.1.2.1.2.2.1.16", true},
+		{"ifInOctets", ".1.3.6.1.2.1.2.2.1.10", false},
+		{"ifHCInOctets", ".1.3.6.1.2.1.31.1.1.1.6", false},
+		{"sysName", ".1.3.6.1.2.1.1.5.0", true},
+		{"hrProcessorLoad", ".1.3.6.1.2.1.25.3.3.1.2", false},
+	} {
+		if reported := filter.reports(test.name, test.oid); reported != test.reported {
+			t.Errorf("expected %s to be reported %v", test.name, test.reported)
+		}
+	}
+
+	i, err := integration.New("test", "0.0.0", integration.InMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity, err := i.Entity("router:161", "address")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := entity.NewMetricSet("SNMPInterfaceSample")
+	ms.SetMetric("ifHCOutOctets", 10, metric.GAUGE)
+	ms.SetMetric("ifMtu", 1500, metric.GAUGE)
+	filter.filterPayload(i)
+	if _, ok := ms.Metrics["ifHCOutOctets"]; ok || ms.Metrics["ifMtu"] == nil || ms.Metrics["event_type"] == nil {
+		t.Errorf("unexpected metrics %v", ms.Metrics)
+	}
+
+	if filter, err := parseMetricFilter("", " "); err != nil || filter != nil {
+		t.Errorf("expected no filter, got %v %v", filter, err)
+	}
+	if _, err := parseMetricFilter("if[", ""); err == nil {
+		t.Error("expected error for an invalid pattern")
+	}
+}
@@ -1,88 +1,589 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/log"
 	"github.com/soniah/gosnmp"
 )
 
+// setMetric reports a PDU according to its metric definition, applying the
+// configured format before falling back to the default handling of its type.
+// Float values are rounded to the precision of the metric
+func setMetric(def *metricDef, metricName string, pdu gosnmp.SnmpPDU, ms *metric.Set) error {
+	if args.StrictTypes && !checkMetricType(def, pdu) {
+		return fmt.Errorf("metric %s is configured as %s but %s returned %s", metricName, metricTypeName(def.metricType), pdu.Name, pduTypeName(pdu.Type))
+	}
+	pdu = def.unpack(pdu)
+	if metricType := resolveMetricType(def.metricType, pdu.Type); metricType != def.metricType {
+		resolved := *def
+		resolved.metricType = metricType
+		def = &resolved
+	}
+	err := setMetricValue(def, metricName, pdu, ms)
+	if def.lookup != nil {
+		if lookupErr := setLookupMetric(def, metricName, pdu, ms); lookupErr != nil && err == nil {
+			err = lookupErr
+		}
+	}
+	precision := args.FloatPrecision
+	if def.precision != nil {
+		precision = *def.precision
+	}
+	roundMetric(ms, metricName, precision)
+	return err
+}
+
+// roundMetric rounds a float metric of a set to the given number of decimals.
+// A negative precision keeps the value as is
+func roundMetric(ms *metric.Set, metricName string, precision int) {
+	if precision < 0 {
+		return
+	}
+	if value, ok := ms.Metrics[metricName].(float64); ok {
+		scale := math.Pow10(precision)
+		ms.Metrics[metricName] = math.Round(value*scale) / scale
+	}
+}
+
+func setMetricValue(def *metricDef, metricName string, pdu gosnmp.SnmpPDU, ms *metric.Set) error {
+	if def.unit != "" {
+		if err := ms.SetMetric(metricName+"Unit", def.unit, metric.ATTRIBUTE); err != nil {
+			return err
+		}
+	}
+	if len(def.values) > 0 && pdu.Type == gosnmp.Integer {
+		return setEnumMetrics(def, metricName, pdu, ms)
+	}
+	if def.format == "" && def.isScaled() {
+		if value, ok := pduFloat(pdu); ok {
+			return setNumericValue(def, metricName, pdu.Name, value, counterWrap(pdu.Type), ms)
+		}
+	}
+	switch def.format {
+	case "bits":
+		return setBitsMetrics(def, metricName, pdu, ms)
+	case "uptime":
+		return setUptimeMetrics(def, metricName, pdu, ms)
+	case "date_and_time":
+		return setDateAndTimeMetric(def, metricName, pdu, ms)
+	case "mac":
+		b, ok := pdu.Value.([]byte)
+		if !ok {
+			return fmt.Errorf("unsupported PDU type[%x] for MAC address metric %v", pdu.Type, metricName)
+		}
+		return ms.SetMetric(metricName, formatMacAddress(b), metric.ATTRIBUTE)
+	case "hex":
+		b, ok := pdu.Value.([]byte)
+		if !ok {
+			return fmt.Errorf("unsupported PDU type[%x] for hex metric %v", pdu.Type, metricName)
+		}
+		return ms.SetMetric(metricName, hex.EncodeToString(b), metric.ATTRIBUTE)
+	case "base64":
+		b, ok := pdu.Value.([]byte)
+		if !ok {
+			return fmt.Errorf("unsupported PDU type[%x] for base64 metric %v", pdu.Type, metricName)
+		}
+		return ms.SetMetric(metricName, base64.StdEncoding.EncodeToString(b), metric.ATTRIBUTE)
+	case "length", "present":
+		return setStringLengthMetric(def, metricName, pdu, ms)
+	case "reverse_dns":
+		return setReverseDNSMetrics(metricName, pdu, ms)
+	case "numeric":
+		return setNumericStringMetric(def, metricName, pdu, ms)
+	case "truthvalue":
+		return setTruthValueMetric(def, metricName, pdu, ms)
+	}
+	return createMetric(metricName, def.metricType, pdu, ms)
+}
+
+// setBitsMetrics expands a BITS value into one 0/1 value per named bit,
+// reported as `<metricName>.<bitName>`. Bit 0 is the most significant bit of the first octet
+func setBitsMetrics(def *metricDef, metricName string, pdu gosnmp.SnmpPDU, ms *metric.Set) error {
+	b, ok := pdu.Value.([]byte)
+	if !ok {
+		return fmt.Errorf("unsupported PDU type[%x] for BITS metric %v", pdu.Type, metricName)
+	}
+	for bit, bitName := range def.bits {
+		value := 0
+		if bit >= 0 && bit/8 < len(b) && b[bit/8]&(0x80>>uint(bit%8)) != 0 {
+			value = 1
+		}
+		var err error
+		if def.metricType == attribute {
+			err = ms.SetMetric(metricName+"."+bitName, fmt.Sprintf("%d", value), metric.ATTRIBUTE)
+		} else {
+			err = ms.SetMetric(metricName+"."+bitName, value, metric.GAUGE)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setEnumMetrics reports the label of an enumerated integer as an attribute.
+// Codes without a label are reported as is. With emit_code the raw code is
+// also reported as the `<metricName>Code` gauge
+func setEnumMetrics(def *metricDef, metricName string, pdu gosnmp.SnmpPDU, ms *metric.Set) error {
+	code, ok := pdu.Value.(int)
+	if !ok {
+		return fmt.Errorf("unable to assert Integer value of %v as int", metricName)
+	}
+	label, ok := def.values[code]
+	if !ok {
+		label = strconv.Itoa(code)
+	}
+	if err := ms.SetMetric(metricName, label, metric.ATTRIBUTE); err != nil {
+		return err
+	}
+	if def.emitCode {
+		return ms.SetMetric(metricName+"Code", code, metric.GAUGE)
+	}
+	return nil
+}
+
+// setTruthValueMetric reports an SMI TruthValue (1 true, 2 false) as a 1/0
+// gauge or, for attribute metrics, as "true"/"false"
+func setTruthValueMetric(def *metricDef, metricName string, pdu gosnmp.SnmpPDU, ms *metric.Set) error {
+	code, ok := pdu.Value.(int)
+	if pdu.Type != gosnmp.Integer || !ok {
+		return fmt.Errorf("unsupported PDU type[%x] for TruthValue metric %v", pdu.Type, metricName)
+	}
+	var value bool
+	switch code {
+	case 1:
+		value = true
+	case 2:
+		value = false
+	default:
+		return fmt.Errorf("invalid TruthValue %d for metric %v", code, metricName)
+	}
+	if def.metricType == attribute {
+		return ms.SetMetric(metricName, strconv.FormatBool(value), metric.ATTRIBUTE)
+	}
+	if value {
+		return ms.SetMetric(metricName, 1, metric.GAUGE)
+	}
+	return ms.SetMetric(metricName, 0, metric.GAUGE)
+}
+
+// setUptimeMetrics reports a TimeTicks value in seconds along with a
+// `<metricName>Display` attribute formatted as `3d 04:05:06`
+func setUptimeMetrics(def *metricDef, metricName string, pdu gosnmp.SnmpPDU, ms *metric.Set) error {
+	if pdu.Type != gosnmp.TimeTicks {
+		return fmt.Errorf("unsupported PDU type[%x] for uptime metric %v", pdu.Type, metricName)
+	}
+	if err := createMetric(metricName, def.metricType, pdu, ms); err != nil {
+		return err
+	}
+	return ms.SetMetric(metricName+"Display", formatUptime(gosnmp.ToBigInt(pdu.Value).Uint64()), metric.ATTRIBUTE)
+}
+
+// formatUptime renders hundredths of a second as days and hh:mm:ss
+func formatUptime(ticks uint64) string {
+	seconds := ticks / 100
+	return fmt.Sprintf("%dd %02d:%02d:%02d", seconds/86400, seconds/3600%24, seconds/60%60, seconds%60)
+}
+
+// setDateAndTimeMetric reports a DateAndTime octet string as an ISO-8601
+// attribute or, for gauge metrics, as seconds since the epoch
+func setDateAndTimeMetric(def *metricDef, metricName string, pdu gosnmp.SnmpPDU, ms *metric.Set) error {
+	b, ok := pdu.Value.([]byte)
+	if !ok {
+		return fmt.Errorf("unsupported PDU type[%x] for DateAndTime metric %v", pdu.Type, metricName)
+	}
+	if def.metricType == gauge {
+		t, err := parseDateAndTime(b)
+		if err != nil {
+			return fmt.Errorf("%s: %v", metricName, err)
+		}
+		return ms.SetMetric(metricName, t.Unix(), metric.GAUGE)
+	}
+	value, err := formatDateAndTime(b)
+	if err != nil {
+		return fmt.Errorf("%s: %v", metricName, err)
+	}
+	return ms.SetMetric(metricName, value, metric.ATTRIBUTE)
+}
+
+// opaqueValue returns the float carried by an Opaque wrapped Float or Double.
+// NaN and infinite values are rejected as they cannot be reported
+func opaqueValue(pdu gosnmp.SnmpPDU) (float64, error) {
+	var f float64
+	switch v := pdu.Value.(type) {
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	default:
+		return 0, fmt.Errorf("unable to assert Opaque value %v of %s as float", pdu.Value, pdu.Name)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("non finite Opaque value %v of %s", f, pdu.Name)
+	}
+	return f, nil
+}
+
+// trimString drops the trailing NUL bytes and whitespace some agents pad
+// DisplayStrings with, unless raw_strings is set
+func trimString(s string) string {
+	if args.RawStrings {
+		return s
+	}
+	return strings.TrimRightFunc(s, func(r rune) bool {
+		return r == 0 || unicode.IsSpace(r)
+	})
+}
+
+// validUTF8 repairs a string that is not valid UTF-8, such as a Latin-1
+// sysDescr, by decoding its bytes as Latin-1, so the payload stays valid JSON
+func validUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	runes := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		runes[i] = rune(s[i])
+	}
+	return string(runes)
+}
+
+// octetStringValue renders an OctetString as text, or as hex when it holds
+// binary data that would otherwise put control characters in the payload.
+// Padding is trimmed from text values
+func octetStringValue(b []byte) string {
+	text := validUTF8(trimString(string(b)))
+	for _, r := range text {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+			return hex.EncodeToString(b)
+		}
+	}
+	return text
+}
+
+// setStringLengthMetric reports the length in characters of a string value,
+// trimmed of its padding, as a gauge, or with the present format 1 when it is not empty and 0 otherwise
+func setStringLengthMetric(def *metricDef, metricName string, pdu gosnmp.SnmpPDU, ms *metric.Set) error {
+	b, ok := pdu.Value.([]byte)
+	if !ok {
+		return fmt.Errorf("unsupported PDU type[%x] for %s metric %v", pdu.Type, def.format, metricName)
+	}
+	length := utf8.RuneCountInString(trimString(string(b)))
+	if def.format == "present" && length > 0 {
+		length = 1
+	}
+	return ms.SetMetric(metricName, length, metric.GAUGE)
+}
+
+// numericString matches the first number of a string such as "23.5" or " 42 %"
+var numericString = regexp.MustCompile(`[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?`)
+
+// setNumericStringMetric parses a number out of an OctetString, using the first
+// group of the extract expression when configured, and reports it with the
+// configured metric type, as a gauge by default
+func setNumericStringMetric(def *metricDef, metricName string, pdu gosnmp.SnmpPDU, ms *metric.Set) error {
+	b, ok := pdu.Value.([]byte)
+	if !ok {
+		return createMetric(metricName, def.metricType, pdu, ms)
+	}
+	number := numericString.FindString(string(b))
+	if def.extract != nil {
+		number = ""
+		if matches := def.extract.FindStringSubmatch(string(b)); len(matches) > 1 {
+			number = strings.TrimSpace(matches[1])
+		} else if len(matches) == 1 {
+			number = numericString.FindString(matches[0])
+		}
+	}
+	if number == "" {
+		return fmt.Errorf("no number found in %q for metric %v", string(b), metricName)
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return fmt.Errorf("unable to parse %q for metric %v: %v", number, metricName, err)
+	}
+	return setNumericValue(def, metricName, pdu.Name, value, 0, ms)
+}
+
+// numericValue returns the value of a numeric PDU as reported for the metric,
+// after its scale and offset
+func (def *metricDef) numericValue(pdu gosnmp.SnmpPDU) (float64, bool) {
+	value, ok := pduFloat(def.unpack(pdu))
+	if !ok {
+		return 0, false
+	}
+	if def.scale != 0 {
+		value *= def.scale
+	}
+	return value + def.offset, true
+}
+
+// setDerivedMetrics evaluates the derived metrics of a set from the values of
+// its other metrics, keyed by metric name and by OID. A derived metric is
+// skipped when a value it needs is missing. source identifies the row, or the
+// scalar set, in the samples of derived rates and deltas
+func setDerivedMetrics(derived []*derivedMetric, values map[string]float64, source string, ms *metric.Set) {
+	for _, d := range derived {
+		if d.percentOf != nil {
+			setPercentOfMetrics(d, values, ms)
+			continue
+		}
+		value, err := d.expression.eval(values)
+		if err != nil {
+			log.Debug("unable to compute derived metric %s: %v", d.metricName, err)
+			continue
+		}
+		if err := setSampledMetric(ms, d.metricName, source, value, sampledType(d.metricType)); err != nil {
+			log.Error(err.Error())
+		}
+		roundMetric(ms, d.metricName, args.FloatPrecision)
+	}
+}
+
+// setPercentOfMetrics reports used/total as a percentage. With allocation
+// units, used and total are also reported in bytes as `<metricName>UsedBytes`
+// and `<metricName>TotalBytes`. Integer32 sizes of large storage wrap to
+// negative values on many agents, so negative sizes are read as unsigned
+func setPercentOfMetrics(d *derivedMetric, values map[string]float64, ms *metric.Set) {
+	used, usedOk := values[d.percentOf.used]
+	total, totalOk := values[d.percentOf.total]
+	if !usedOk || !totalOk {
+		log.Debug("unable to compute derived metric %s: missing used or total", d.metricName)
+		return
+	}
+	used, total = unsigned32(used), unsigned32(total)
+	if total > 0 {
+		if err := ms.SetMetric(d.metricName, used/total*100, metric.GAUGE); err != nil {
+			log.Error(err.Error())
+		}
+		roundMetric(ms, d.metricName, args.FloatPrecision)
+	}
+	if d.percentOf.allocationUnits == "" {
+		return
+	}
+	units, ok := values[d.percentOf.allocationUnits]
+	if !ok {
+		return
+	}
+	if err := ms.SetMetric(d.metricName+"UsedBytes", used*units, metric.GAUGE); err != nil {
+		log.Error(err.Error())
+	}
+	if err := ms.SetMetric(d.metricName+"TotalBytes", total*units, metric.GAUGE); err != nil {
+		log.Error(err.Error())
+	}
+}
+
+// unsigned32 reads a negative Integer32 as the unsigned value it wrapped from
+func unsigned32(value float64) float64 {
+	if value < 0 && value >= math.MinInt32 {
+		return value + (1 << 32)
+	}
+	return value
+}
+
+// unpack extracts the field selected by the mask and shift of a metric from
+// an integer PDU. The field is a gauge, or an Integer for enumerations
+func (def *metricDef) unpack(pdu gosnmp.SnmpPDU) gosnmp.SnmpPDU {
+	if def.mask == 0 && def.shift == 0 {
+		return pdu
+	}
+	switch pdu.Type {
+	case gosnmp.Integer, gosnmp.Gauge32, gosnmp.Counter32, gosnmp.Counter64, gosnmp.Uinteger32:
+	default:
+		return pdu
+	}
+	bigValue := gosnmp.ToBigInt(pdu.Value)
+	value := bigValue.Uint64()
+	if bigValue.Sign() < 0 {
+		//negative Integer32 values are unpacked from their two's complement
+		value = uint64(uint32(bigValue.Int64()))
+	}
+	if def.mask != 0 {
+		value &= def.mask
+	}
+	value >>= def.shift
+	if pdu.Type == gosnmp.Integer {
+		//enumerations and TruthValues expect Integer values as int
+		pdu.Value = int(value)
+		return pdu
+	}
+	pdu.Type = gosnmp.Gauge32
+	pdu.Value = value
+	return pdu
+}
+
+// isScaled reports whether numeric values of the metric are converted before being reported
+func (def *metricDef) isScaled() bool {
+	return def.scale != 0 || def.offset != 0
+}
+
+// setNumericValue applies the scale and offset of a metric to a value and
+// reports it with the configured metric type, as a gauge by default
+func setNumericValue(def *metricDef, metricName string, source string, value float64, wrapAt float64, ms *metric.Set) error {
+	if def.scale != 0 {
+		value *= def.scale
+		wrapAt *= def.scale
+	}
+	value += def.offset
+	if def.metricType == attribute {
+		return ms.SetMetric(metricName, strconv.FormatFloat(value, 'f', -1, 64), metric.ATTRIBUTE)
+	}
+	return setCounterMetric(ms, metricName, source, value, sampledType(def.metricType), wrapAt)
+}
+
+// setReverseDNSMetrics reports an IpAddress as a dotted-quad attribute along
+// with a `<metricName>Hostname` attribute resolved by reverse DNS
+func setReverseDNSMetrics(metricName string, pdu gosnmp.SnmpPDU, ms *metric.Set) error {
+	address, ok := pdu.Value.(string)
+	if pdu.Type != gosnmp.IPAddress || !ok {
+		return fmt.Errorf("unsupported PDU type[%x] for reverse_dns metric %v", pdu.Type, metricName)
+	}
+	if err := ms.SetMetric(metricName, address, metric.ATTRIBUTE); err != nil {
+		return err
+	}
+	if hostname := reverseLookup(address); hostname != "" {
+		return ms.SetMetric(metricName+"Hostname", hostname, metric.ATTRIBUTE)
+	}
+	return nil
+}
+
+// isNullPDU reports whether a PDU carries no value for its OID
+func isNullPDU(pdu gosnmp.SnmpPDU) bool {
+	return pdu.Type == gosnmp.Null || pdu.Type == gosnmp.NoSuchObject || pdu.Type == gosnmp.NoSuchInstance
+}
+
+// setNullMetric applies the null policy of a metric whose value is missing.
+// The present format reports a missing value as 0 unless the null policy is zero, default or flag
+func setNullMetric(def *metricDef, metricName string, ms *metric.Set) error {
+	switch def.nullPolicy {
+	case nullSkip:
+		if def.format == "present" {
+			return ms.SetMetric(metricName, 0, metric.GAUGE)
+		}
+	case nullZero:
+		if def.metricType == attribute {
+			return ms.SetMetric(metricName, "0", metric.ATTRIBUTE)
+		}
+		return ms.SetMetric(metricName, 0, metric.GAUGE)
+	case nullDefault:
+		if def.metricType == attribute {
+			return ms.SetMetric(metricName, def.defaultValue, metric.ATTRIBUTE)
+		}
+		return ms.SetMetric(metricName, def.defaultNumber, metric.GAUGE)
+	case nullFlag:
+		return ms.SetMetric(metricName+"IsNull", "true", metric.ATTRIBUTE)
+	}
+	return nil
+}
+
+// inferMetricType derives the metric type of an auto metric from the PDU type:
+// counters are reported as rates, strings and addresses as attributes and
+// every other value as a gauge
+func inferMetricType(pduType gosnmp.Asn1BER) metricSourceType {
+	switch pduType {
+	case gosnmp.Counter32, gosnmp.Counter64:
+		return rate
+	case gosnmp.OctetString, gosnmp.ObjectIdentifier, gosnmp.IPAddress:
+		return attribute
+	}
+	return gauge
+}
+
+// typeConversions are the type_conversions of the collection being run
+var typeConversions map[gosnmp.Asn1BER]metricSourceType
+
+// resolveMetricType returns the metric type a PDU is reported with: the
+// type_conversions of its PDU type for metrics without a metric_type and the
+// inferred type for auto metrics
+func resolveMetricType(metricType metricSourceType, pduType gosnmp.Asn1BER) metricSourceType {
+	if metricType == unset {
+		if converted, ok := typeConversions[pduType]; ok {
+			metricType = converted
+		}
+	}
+	if metricType == auto {
+		metricType = inferMetricType(pduType)
+	}
+	return metricType
+}
+
 func createMetric(metricName string, metricType metricSourceType, pdu gosnmp.SnmpPDU, ms *metric.Set) error {
-	var sourceType metric.SourceType
+	var st sampleType
 	var value interface{}
+	metricType = resolveMetricType(metricType, pdu.Type)
 	switch pdu.Type {
 	case gosnmp.OctetString:
 		if v, ok := pdu.Value.([]byte); ok {
-			value = string(v)
-			return ms.SetMetric(metricName, value, metric.ATTRIBUTE)
+			return ms.SetMetric(metricName, octetStringValue(v), metric.ATTRIBUTE)
 		}
 	case gosnmp.Gauge32, gosnmp.Counter32, gosnmp.Counter64, gosnmp.Integer, gosnmp.Uinteger32:
 		switch metricType {
-		case auto, gauge:
-			value = gosnmp.ToBigInt(pdu.Value)
-			sourceType = metric.GAUGE
-		case delta:
-			value = gosnmp.ToBigInt(pdu.Value)
-			sourceType = metric.DELTA
-		case rate:
-			value = gosnmp.ToBigInt(pdu.Value)
-			sourceType = metric.RATE
 		case attribute:
 			value = gosnmp.ToBigInt(pdu.Value).String()
-			sourceType = metric.ATTRIBUTE
+			st = sampleType{sourceType: metric.ATTRIBUTE}
+		default:
+			value = gosnmp.ToBigInt(pdu.Value)
+			st = sampledType(metricType)
 		}
-		return ms.SetMetric(metricName, value, sourceType)
+		return setCounterMetric(ms, metricName, pdu.Name, value, st, counterWrap(pdu.Type))
 	case gosnmp.ObjectIdentifier, gosnmp.IPAddress:
+		//IpAddress values are always reported as dotted-quad attributes, whatever the metric type.
+		//OID values (sysObjectID, RowPointer) are translated to symbolic names when MIBs are loaded
 		if v, ok := pdu.Value.(string); ok {
+			if pdu.Type == gosnmp.ObjectIdentifier {
+				v = mibs.translate(v)
+			}
 			value = v
-			sourceType = metric.ATTRIBUTE
-			return ms.SetMetric(metricName, value, sourceType)
+			st = sampleType{sourceType: metric.ATTRIBUTE}
+			return ms.SetMetric(metricName, value, st.sourceType)
 		}
 		return fmt.Errorf("unable to assert ObjectIdentifier or IPAddress as string")
-	case gosnmp.OpaqueFloat:
+	case gosnmp.OpaqueFloat, gosnmp.OpaqueDouble:
+		f, err := opaqueValue(pdu)
+		if err != nil {
+			return fmt.Errorf("%v for %v", err, metricName)
+		}
 		switch metricType {
-		case auto, gauge:
-			value = float64(pdu.Value.(float32))
-			sourceType = metric.GAUGE
-		case delta:
-			value = float64(pdu.Value.(float32))
-			sourceType = metric.DELTA
-		case rate:
-			value = float64(pdu.Value.(float32))
-			sourceType = metric.RATE
 		case attribute:
-			value = fmt.Sprintf("%f", float64(pdu.Value.(float32)))
-			sourceType = metric.ATTRIBUTE
+			value = fmt.Sprintf("%f", f)
+			st = sampleType{sourceType: metric.ATTRIBUTE}
+		default:
+			value = f
+			st = sampledType(metricType)
 		}
-		return ms.SetMetric(metricName, value, sourceType)
-	case gosnmp.OpaqueDouble:
+		return setSampledMetric(ms, metricName, pdu.Name, value, st)
+	case gosnmp.TimeTicks:
+		//TimeTicks count hundredths of a second, they are reported in seconds
+		seconds := float64(gosnmp.ToBigInt(pdu.Value).Uint64()) / 100
 		switch metricType {
-		case auto, gauge:
-			value = pdu.Value.(float64)
-			sourceType = metric.GAUGE
-		case delta:
-			value = pdu.Value.(float64)
-			sourceType = metric.DELTA
-		case rate:
-			value = pdu.Value.(float64)
-			sourceType = metric.RATE
 		case attribute:
-			value = fmt.Sprintf("%f", pdu.Value.(float64))
-			sourceType = metric.ATTRIBUTE
+			value = fmt.Sprintf("%.2f", seconds)
+			st = sampleType{sourceType: metric.ATTRIBUTE}
+		default:
+			value = seconds
+			st = sampledType(metricType)
 		}
-		return ms.SetMetric(metricName, value, sourceType)
+		return setSampledMetric(ms, metricName, pdu.Name, value, st)
 	case gosnmp.Boolean:
 		return fmt.Errorf("unsupported PDU type[Boolean] for %v", metricName)
 	case gosnmp.BitString:
 		return fmt.Errorf("unsupported PDU type[BitString] for %v", metricName)
-	case gosnmp.TimeTicks:
-		return fmt.Errorf("unsupported PDU type[TimeTicks] for %v", metricName)
 	case gosnmp.UnknownType:
 		return fmt.Errorf("unsupported PDU type[UnknownType] for %v", metricName)
 	case gosnmp.Null:
-		return fmt.Errorf("null value[" + metricName + "].")
+		return fmt.Errorf("null value[%s].", metricName)
 	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance:
-		return fmt.Errorf("no such object or instance[" + metricName + "].")
+		return fmt.Errorf("no such object or instance[%s].", metricName)
 	default:
 		return fmt.Errorf("unsupported PDU type[%x] for %v", pdu.Type, metricName)
 	}
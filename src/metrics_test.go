@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/soniah/gosnmp"
+)
+
+func TestFormatUptime(t *testing.T) {
+	cases := map[uint64]string{
+		0:        "0d 00:00:00",
+		12345:    "0d 00:02:03",
+		27000000: "3d 03:00:00",
+		8640100:  "1d 00:00:01",
+	}
+	for ticks, expected := range cases {
+		if uptime := formatUptime(ticks); uptime != expected {
+			t.Errorf("formatUptime(%d) = %s, expected %s", ticks, uptime, expected)
+		}
+	}
+}
+
+func TestOctetStringValue(t *testing.T) {
+	cases := map[string]string{
+		"GigabitEthernet0/1":       "GigabitEthernet0/1",
+		"eth0\x00\x00":             "eth0",
+		"Gi0/1  \x00":              "Gi0/1",
+		"line one\nline two":       "line one\nline two",
+		"\x00\x1b\x21\xab\xcd\xef": "001b21abcdef",
+		"\xff\x80":                 "ff80",
+		"Cisco IOS \xae":           "Cisco IOS ®",
+	}
+	for input, expected := range cases {
+		if value := octetStringValue([]byte(input)); value != expected {
+			t.Errorf("octetStringValue(%q) = %q, expected %q", input, value, expected)
+		}
+	}
+}
+
+func TestNumericString(t *testing.T) {
+	cases := map[string]string{
+		"23.5":      "23.5",
+		" 42 %":     "42",
+		"-3.2 dBm":  "-3.2",
+		"temp=1e3C": "1e3",
+		"n/a":       "",
+	}
+	for input, expected := range cases {
+		if number := numericString.FindString(input); number != expected {
+			t.Errorf("numericString in %q = %q, expected %q", input, number, expected)
+		}
+	}
+}
+
+func TestRoundMetric(t *testing.T) {
+	ms := metric.NewSet("TestSample", nil)
+	ms.Metrics["rate"] = 12.3456789
+	ms.Metrics["count"] = 7
+	roundMetric(ms, "rate", 2)
+	roundMetric(ms, "count", 2)
+	if ms.Metrics["rate"] != 12.35 || ms.Metrics["count"] != 7 {
+		t.Errorf("unexpected metrics %v", ms.Metrics)
+	}
+	roundMetric(ms, "rate", -1)
+	if ms.Metrics["rate"] != 12.35 {
+		t.Errorf("negative precision changed %v", ms.Metrics["rate"])
+	}
+}
+
+func TestCheckMetricType(t *testing.T) {
+	defer func() { typeMismatches = nil }()
+	text := gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("router")}
+	counter := gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.2.2.1.10.1", Type: gosnmp.Counter32, Value: uint(5)}
+	if !checkMetricType(&metricDef{metricType: gauge}, counter) {
+		t.Error("counter rejected as gauge")
+	}
+	if !checkMetricType(&metricDef{metricType: auto}, text) {
+		t.Error("auto metric rejected")
+	}
+	if !checkMetricType(&metricDef{metricType: gauge, format: "numeric"}, text) {
+		t.Error("formatted metric rejected")
+	}
+	if checkMetricType(&metricDef{metricName: "sysName", metricType: gauge}, text) {
+		t.Error("string accepted as gauge")
+	}
+	if len(typeMismatches) != 1 || typeMismatches[0].metricName != "sysName" || pduTypeName(typeMismatches[0].pduType) != "OctetString" {
+		t.Errorf("unexpected mismatches %v", typeMismatches)
+	}
+}
+
+func TestMetricTypeName(t *testing.T) {
+	for name, mt := range metricTypes {
+		if metricTypeName(mt) != name {
+			t.Errorf("metricTypeName(%d) = %s, expected %s", mt, metricTypeName(mt), name)
+		}
+	}
+	if name := metricTypeName(unset); name != "unknown" {
+		t.Errorf("metricTypeName(unset) = %s", name)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	pdu := gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.9.1.0", Type: gosnmp.Integer, Value: 0x1234}
+	high := &metricDef{mask: 0xFF00, shift: 8}
+	if value, ok := high.numericValue(pdu); !ok || value != 0x12 {
+		t.Errorf("unexpected high byte %v", value)
+	}
+	low := &metricDef{mask: 0xFF}
+	if value, ok := low.numericValue(pdu); !ok || value != 0x34 {
+		t.Errorf("unexpected low byte %v", value)
+	}
+	negative := gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.9.1.0", Type: gosnmp.Integer, Value: -1}
+	sign := &metricDef{shift: 31}
+	if value, ok := sign.numericValue(negative); !ok || value != 1 {
+		t.Errorf("unexpected sign bit %v", value)
+	}
+	if unpacked := high.unpack(pdu); unpacked.Value != 0x12 {
+		t.Errorf("unexpected unpacked Integer %#v", unpacked.Value)
+	}
+}
+
+func TestTruthValueMetric(t *testing.T) {
+	cases := []struct {
+		metricType metricSourceType
+		code       int
+		expected   interface{}
+	}{
+		{gauge, 1, 1.0},
+		{gauge, 2, 0.0},
+		{attribute, 1, "true"},
+		{attribute, 2, "false"},
+	}
+	for _, c := range cases {
+		ms := metric.NewSet("TestSample", nil)
+		pdu := gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.2.2.1.21.1", Type: gosnmp.Integer, Value: c.code}
+		if err := setTruthValueMetric(&metricDef{metricType: c.metricType}, "ifPromiscuousMode", pdu, ms); err != nil {
+			t.Fatal(err)
+		}
+		if value := ms.Metrics["ifPromiscuousMode"]; value != c.expected {
+			t.Errorf("TruthValue %d as %s = %#v, expected %#v", c.code, metricTypeName(c.metricType), value, c.expected)
+		}
+	}
+	invalid := []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.2.2.1.21.1", Type: gosnmp.Integer, Value: 0},
+		{Name: ".1.3.6.1.2.1.2.2.1.21.1", Type: gosnmp.Integer, Value: 3},
+		{Name: ".1.3.6.1.2.1.2.2.1.21.1", Type: gosnmp.OctetString, Value: []byte("true")},
+	}
+	for _, pdu := range invalid {
+		if err := setTruthValueMetric(&metricDef{metricType: gauge}, "ifPromiscuousMode", pdu, metric.NewSet("TestSample", nil)); err == nil {
+			t.Errorf("expected error for TruthValue %#v", pdu.Value)
+		}
+	}
+}
+
+func TestScaleThenOffset(t *testing.T) {
+	//212.0 degrees Fahrenheit, read in tenths, reported as 100 degrees Celsius
+	def := &metricDef{metricType: gauge, scale: 0.1 * 5 / 9, offset: -32 * 5.0 / 9}
+	pdu := gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.9.9.13.1.3.1.3.1", Type: gosnmp.Integer, Value: 2120}
+	if value, ok := def.numericValue(pdu); !ok || math.Abs(value-100) > 1e-9 {
+		t.Errorf("numericValue = %v, expected the scale to be applied before the offset", value)
+	}
+	ms := metric.NewSet("TestSample", nil)
+	if err := setNumericValue(&metricDef{metricType: gauge, scale: 8, offset: 1}, "ifSpeedBits", pdu.Name, 10, 0, ms); err != nil {
+		t.Fatal(err)
+	}
+	if value := ms.Metrics["ifSpeedBits"]; value != 81.0 {
+		t.Errorf("setNumericValue = %v, expected 10*8+1", value)
+	}
+}
+
+func TestBitsMetrics(t *testing.T) {
+	bits := map[int]string{0: "first", 1: "second", 8: "ninth", 15: "last", 16: "beyond"}
+	//bit 0 is the most significant bit of the first octet, bit 15 the least significant of the second
+	pdu := gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.9999.1.0", Type: gosnmp.OctetString, Value: []byte{0x80, 0x01}}
+	expected := map[string]int{"first": 1, "second": 0, "ninth": 0, "last": 1, "beyond": 0}
+
+	ms := metric.NewSet("TestSample", nil)
+	if err := setBitsMetrics(&metricDef{format: "bits", bits: bits}, "alarms", pdu, ms); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range expected {
+		if reported := ms.Metrics["alarms."+name]; reported != float64(value) {
+			t.Errorf("bit %s = %#v, expected %d", name, reported, value)
+		}
+	}
+
+	ms = metric.NewSet("TestSample", nil)
+	if err := setBitsMetrics(&metricDef{metricType: attribute, format: "bits", bits: bits}, "alarms", pdu, ms); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range expected {
+		if reported := ms.Metrics["alarms."+name]; reported != fmt.Sprintf("%d", value) {
+			t.Errorf("bit %s = %#v, expected \"%d\"", name, reported, value)
+		}
+	}
+	if len(ms.Metrics) != len(bits)+1 {
+		t.Errorf("expected one attribute per named bit, got %v", ms.Metrics)
+	}
+}
+
+func TestEnumMetrics(t *testing.T) {
+	mibs = loadTestMib(t)
+	defer func() { mibs = nil }()
+	cases := []struct {
+		parser   metricParser
+		code     int
+		expected string
+	}{
+		//the labels come from the MIB enumeration of ifOperStatus
+		{metricParser{Oid: "ifOperStatus.1", MetricName: "status"}, 2, "down"},
+		{metricParser{Oid: "ifOperStatus.1", MetricName: "status"}, 7, "7"},
+		//configured values replace the MIB enumeration
+		{metricParser{Oid: "ifOperStatus.1", MetricName: "status", Values: map[int]string{1: "ready"}}, 1, "ready"},
+		{metricParser{Oid: "ifOperStatus.1", MetricName: "status", Values: map[int]string{1: "ready"}}, 2, "2"},
+		//and are applied to objects the MIBs do not enumerate
+		{metricParser{Oid: ".1.3.6.1.4.1.9999.1.0", MetricName: "status", Values: map[int]string{3: "testing"}, EmitCode: true}, 3, "testing"},
+	}
+	for _, c := range cases {
+		def, err := parseMetric(c.parser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ms := metric.NewSet("TestSample", nil)
+		pdu := gosnmp.SnmpPDU{Name: def.oid, Type: gosnmp.Integer, Value: c.code}
+		if err := setMetric(def, "status", pdu, ms); err != nil {
+			t.Fatal(err)
+		}
+		if label := ms.Metrics["status"]; label != c.expected {
+			t.Errorf("%s with values %v: code %d reported as %#v, expected %q", c.parser.Oid, c.parser.Values, c.code, label, c.expected)
+		}
+		code, emitted := ms.Metrics["statusCode"]
+		if emitted != c.parser.EmitCode || (emitted && code != float64(c.code)) {
+			t.Errorf("%s: unexpected code %#v", c.parser.Oid, code)
+		}
+	}
+}
+
+func TestBase64Format(t *testing.T) {
+	cases := map[string][]byte{
+		"AAEC/w==": {0x00, 0x01, 0x02, 0xff},
+		//not UTF-8, which a text attribute would mangle
+		"wygA/v0=": {0xc3, 0x28, 0x00, 0xfe, 0xfd},
+		"aMOpbGxv": []byte("héllo"),
+		"":         {},
+	}
+	for expected, value := range cases {
+		ms := metric.NewSet("TestSample", nil)
+		pdu := gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.9999.1.0", Type: gosnmp.OctetString, Value: value}
+		if err := setMetricValue(&metricDef{format: "base64"}, "certificate", pdu, ms); err != nil {
+			t.Fatal(err)
+		}
+		if encoded := ms.Metrics["certificate"]; encoded != expected {
+			t.Errorf("% x encoded as %#v, expected %q", value, encoded, expected)
+		}
+	}
+	pdu := gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.9999.1.0", Type: gosnmp.Integer, Value: 1}
+	if err := setMetricValue(&metricDef{format: "base64"}, "certificate", pdu, metric.NewSet("TestSample", nil)); err == nil {
+		t.Error("expected error encoding an Integer as base64")
+	}
+}
+
+func TestStringLengthFormats(t *testing.T) {
+	cases := []struct {
+		value   []byte
+		length  float64
+		present float64
+	}{
+		{[]byte("banner"), 6, 1},
+		{[]byte(""), 0, 0},
+		//padding is trimmed
+		{[]byte("  \x00"), 0, 0},
+		//characters are counted, not bytes
+		{[]byte("Zürich"), 6, 1},
+	}
+	for _, c := range cases {
+		pdu := gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.9.2.1.1.0", Type: gosnmp.OctetString, Value: c.value}
+		for format, expected := range map[string]float64{"length": c.length, "present": c.present} {
+			ms := metric.NewSet("TestSample", nil)
+			if err := setMetricValue(&metricDef{format: format}, "banner", pdu, ms); err != nil {
+				t.Fatal(err)
+			}
+			if value := ms.Metrics["banner"]; value != expected {
+				t.Errorf("%s of %q = %#v, expected %v", format, c.value, value, expected)
+			}
+		}
+	}
+
+	//a missing value is not present, its length is only reported by a null policy
+	ms := metric.NewSet("TestSample", nil)
+	if err := setNullMetric(&metricDef{format: "present"}, "banner", ms); err != nil {
+		t.Fatal(err)
+	}
+	if err := setNullMetric(&metricDef{format: "length"}, "bannerLength", ms); err != nil {
+		t.Fatal(err)
+	}
+	if err := setNullMetric(&metricDef{format: "present", nullPolicy: nullFlag}, "motd", ms); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"event_type": "TestSample", "banner": 0.0, "motdIsNull": "true"}
+	if !reflect.DeepEqual(ms.Metrics, expected) {
+		t.Errorf("missing values reported as %v, expected %v", ms.Metrics, expected)
+	}
+}
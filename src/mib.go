@@ -0,0 +1,813 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/log"
+)
+
+// mibs holds the MIB definitions loaded from the configured mib_dirs.
+// It is nil when no MIB directories are configured
+var mibs *mibRegistry
+
+// mibNode is a single named node of the OID tree as defined in a MIB module
+type mibNode struct {
+	name     string
+	module   string
+	oid      string
+	syntax   mibSyntax
+	access   string
+	index    []string
+	augments string
+	// units and displayHint are the UNITS and DISPLAY-HINT clauses of the object
+	units       string
+	displayHint string
+	children    []*mibNode
+}
+
+// mibSyntax is the SYNTAX clause of an OBJECT-TYPE or TEXTUAL-CONVENTION
+type mibSyntax struct {
+	typeName     string
+	namedNumbers map[int]string
+	// displayHint is the DISPLAY-HINT of a TEXTUAL-CONVENTION
+	displayHint string
+}
+
+// mibRegistry indexes the loaded MIB nodes by name and by OID
+type mibRegistry struct {
+	nodes              map[string]*mibNode
+	byOid              map[string]*mibNode
+	textualConventions map[string]mibSyntax
+	pending            []pendingOid
+}
+
+// pendingOid is an OID assignment waiting for its parent to be resolved
+type pendingOid struct {
+	node       *mibNode
+	components []oidComponent
+}
+
+// oidComponent is one element of an OID value such as `{ ifEntry 1 }` or `{ iso org(3) }`
+type oidComponent struct {
+	name   string
+	number int
+	hasNum bool
+}
+
+// wellKnownOids are the roots of the registration tree, so MIB modules
+// resolve even when SNMPv2-SMI itself is not present in the mib_dirs
+var wellKnownOids = map[string]string{
+	"ccitt":           ".0",
+	"iso":             ".1",
+	"joint-iso-ccitt": ".2",
+	"org":             ".1.3",
+	"dod":             ".1.3.6",
+	"internet":        ".1.3.6.1",
+	"directory":       ".1.3.6.1.1",
+	"mgmt":            ".1.3.6.1.2",
+	"mib-2":           ".1.3.6.1.2.1",
+	"transmission":    ".1.3.6.1.2.1.10",
+	"experimental":    ".1.3.6.1.3",
+	"private":         ".1.3.6.1.4",
+	"enterprises":     ".1.3.6.1.4.1",
+	"security":        ".1.3.6.1.5",
+	"snmpV2":          ".1.3.6.1.6",
+	"snmpDomains":     ".1.3.6.1.6.1",
+	"snmpProxys":      ".1.3.6.1.6.2",
+	"snmpModules":     ".1.3.6.1.6.3",
+	"zeroDotZero":     ".0.0",
+}
+
+// wellKnownTextualConventions are the SNMPv2-TC conventions most MIBs import,
+// so their base types are known even when SNMPv2-TC is not loaded
+var wellKnownTextualConventions = map[string]string{
+	"DisplayString":        "OCTET STRING",
+	"PhysAddress":          "OCTET STRING",
+	"MacAddress":           "OCTET STRING",
+	"DateAndTime":          "OCTET STRING",
+	"SnmpAdminString":      "OCTET STRING",
+	"TruthValue":           "INTEGER",
+	"RowStatus":            "INTEGER",
+	"StorageType":          "INTEGER",
+	"TimeStamp":            "TimeTicks",
+	"TimeInterval":         "INTEGER",
+	"AutonomousType":       "OBJECT IDENTIFIER",
+	"RowPointer":           "OBJECT IDENTIFIER",
+	"VariablePointer":      "OBJECT IDENTIFIER",
+	"InterfaceIndex":       "Integer32",
+	"InetAddress":          "OCTET STRING",
+	"InetAddressType":      "INTEGER",
+	"InetPortNumber":       "Unsigned32",
+	"Ipv6Address":          "OCTET STRING",
+	"TestAndIncr":          "INTEGER",
+	"TAddress":             "OCTET STRING",
+	"TDomain":              "OBJECT IDENTIFIER",
+	"InterfaceIndexOrZero": "Integer32",
+}
+
+// mibMacros are the macros whose invocation assigns an OID to a name
+var mibMacros = map[string]bool{
+	"OBJECT-TYPE":        true,
+	"MODULE-IDENTITY":    true,
+	"OBJECT-IDENTITY":    true,
+	"NOTIFICATION-TYPE":  true,
+	"OBJECT-GROUP":       true,
+	"NOTIFICATION-GROUP": true,
+	"MODULE-COMPLIANCE":  true,
+	"AGENT-CAPABILITIES": true,
+}
+
+func newMibRegistry() *mibRegistry {
+	r := &mibRegistry{
+		nodes:              make(map[string]*mibNode),
+		byOid:              make(map[string]*mibNode),
+		textualConventions: make(map[string]mibSyntax),
+	}
+	for name, oid := range wellKnownOids {
+		node := &mibNode{name: name, oid: oid}
+		r.nodes[name] = node
+		r.byOid[oid] = node
+	}
+	for name, typeName := range wellKnownTextualConventions {
+		r.textualConventions[name] = mibSyntax{typeName: typeName}
+	}
+	return r
+}
+
+// loadMibs parses every file found in the given directories. Files that fail
+// to parse are logged and skipped so one broken vendor MIB does not prevent
+// the others from being used
+func loadMibs(dirs []string) (*mibRegistry, error) {
+	r := newMibRegistry()
+	for _, dir := range dirs {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read MIB directory %s: %v", dir, err)
+		}
+		for _, file := range files {
+			if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(dir, file.Name())
+			if err := r.loadFile(path); err != nil {
+				log.Warn("unable to parse MIB file %s: %v", path, err)
+			}
+		}
+	}
+	r.resolve()
+	return r, nil
+}
+
+func (r *mibRegistry) loadFile(path string) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return r.parse(string(src))
+}
+
+// tokenizeMib splits SMI source into tokens, dropping comments. Quoted strings
+// are kept as a single token including their quotes
+func tokenizeMib(src string) []string {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '-' && i+1 < len(src) && src[i+1] == '-':
+			// a comment runs to the end of the line or to the next "--"
+			i += 2
+			for i < len(src) && src[i] != '\n' {
+				if src[i] == '-' && i+1 < len(src) && src[i+1] == '-' {
+					i += 2
+					break
+				}
+				i++
+			}
+		case c == '"':
+			end := strings.IndexByte(src[i+1:], '"')
+			if end < 0 {
+				end = len(src) - i - 1
+			}
+			tokens = append(tokens, src[i:i+end+2])
+			i += end + 2
+		case strings.HasPrefix(src[i:], "::="):
+			tokens = append(tokens, "::=")
+			i += 3
+		case strings.HasPrefix(src[i:], ".."):
+			tokens = append(tokens, "..")
+			i += 2
+		case strings.IndexByte("{}(),;|[]", c) >= 0:
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			start := i
+			for i < len(src) && isMibWordChar(src[i]) {
+				if src[i] == '-' && i+1 < len(src) && src[i+1] == '-' {
+					break
+				}
+				i++
+			}
+			if i == start {
+				i++
+				continue
+			}
+			tokens = append(tokens, src[start:i])
+		}
+	}
+	return tokens
+}
+
+func isMibWordChar(c byte) bool {
+	return c == '-' || c == '_' || c == '\'' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isLowerIdentifier(token string) bool {
+	return token != "" && token[0] >= 'a' && token[0] <= 'z'
+}
+
+func isUpperIdentifier(token string) bool {
+	return token != "" && token[0] >= 'A' && token[0] <= 'Z'
+}
+
+// parse reads the definitions of every module in src
+func (r *mibRegistry) parse(src string) error {
+	tokens := tokenizeMib(src)
+	module := ""
+	for i := 0; i < len(tokens); i++ {
+		next := func(n int) string {
+			if i+n < len(tokens) {
+				return tokens[i+n]
+			}
+			return ""
+		}
+		switch {
+		case next(1) == "DEFINITIONS":
+			module = tokens[i]
+		case next(1) == "MACRO":
+			for i < len(tokens) && tokens[i] != "END" {
+				i++
+			}
+		case isLowerIdentifier(tokens[i]) && next(1) == "OBJECT" && next(2) == "IDENTIFIER" && next(3) == "::=":
+			node := &mibNode{name: tokens[i], module: module}
+			components, end, err := parseOidValue(tokens, i+4)
+			if err != nil {
+				return fmt.Errorf("%s: %v", tokens[i], err)
+			}
+			r.pending = append(r.pending, pendingOid{node: node, components: components})
+			i = end
+		case isLowerIdentifier(tokens[i]) && mibMacros[next(1)] && next(2) != "," && next(2) != "FROM":
+			node := &mibNode{name: tokens[i], module: module}
+			end, err := r.parseMacroClauses(node, tokens, i+2)
+			if err != nil {
+				return fmt.Errorf("%s: %v", tokens[i], err)
+			}
+			components, end, err := parseOidValue(tokens, end+1)
+			if err != nil {
+				return fmt.Errorf("%s: %v", tokens[i], err)
+			}
+			r.pending = append(r.pending, pendingOid{node: node, components: components})
+			i = end
+		case isUpperIdentifier(tokens[i]) && next(1) == "::=" && next(2) == "TEXTUAL-CONVENTION":
+			tc := &mibNode{name: tokens[i], module: module}
+			end, err := r.parseMacroClauses(tc, tokens, i+3)
+			if err != nil {
+				return fmt.Errorf("%s: %v", tokens[i], err)
+			}
+			tc.syntax.displayHint = tc.displayHint
+			r.textualConventions[tc.name] = tc.syntax
+			i = end - 1
+		case isUpperIdentifier(tokens[i]) && next(1) == "::=" && next(2) != "BEGIN" && next(2) != "SEQUENCE" && next(2) != "CHOICE":
+			j := i + 2
+			for j < len(tokens) && (tokens[j] == "[" || tokens[j] == "IMPLICIT") {
+				if tokens[j] == "[" {
+					for j < len(tokens) && tokens[j] != "]" {
+						j++
+					}
+				}
+				j++
+			}
+			syntax, end := parseSyntax(tokens, j)
+			if syntax.typeName != "" {
+				r.textualConventions[tokens[i]] = syntax
+			}
+			i = end - 1
+		}
+	}
+	return nil
+}
+
+// parseMacroClauses reads the clauses of a macro invocation starting at
+// tokens[start]. It returns the position of the `::=` that ends the
+// invocation, or for a TEXTUAL-CONVENTION the position after its SYNTAX
+func (r *mibRegistry) parseMacroClauses(node *mibNode, tokens []string, start int) (int, error) {
+	for j := start; j < len(tokens); {
+		switch tokens[j] {
+		case "::=":
+			return j, nil
+		case "SYNTAX":
+			var end int
+			node.syntax, end = parseSyntax(tokens, j+1)
+			j = end
+			if j < len(tokens) && tokens[j] != "::=" && !isMibClause(tokens[j]) {
+				// end of a TEXTUAL-CONVENTION, SYNTAX is its last clause
+				return j, nil
+			}
+		case "UNITS", "DISPLAY-HINT":
+			if j+1 < len(tokens) {
+				value := strings.Trim(tokens[j+1], `"`)
+				if tokens[j] == "UNITS" {
+					node.units = value
+				} else {
+					node.displayHint = value
+				}
+			}
+			j += 2
+		case "MAX-ACCESS", "ACCESS":
+			if j+1 < len(tokens) {
+				node.access = tokens[j+1]
+			}
+			j += 2
+		case "INDEX":
+			names, end := parseBracedList(tokens, j+1)
+			node.index = names
+			j = end
+		case "AUGMENTS":
+			names, end := parseBracedList(tokens, j+1)
+			if len(names) > 0 {
+				node.augments = names[0]
+			}
+			j = end
+		case "DEFVAL":
+			j = skipBalanced(tokens, j+1, "{", "}")
+		default:
+			j++
+		}
+	}
+	if start < len(tokens) && tokens[start-1] == "TEXTUAL-CONVENTION" {
+		return len(tokens), nil
+	}
+	return 0, fmt.Errorf("unterminated definition")
+}
+
+// isMibClause reports whether token starts a clause of an SMI macro
+func isMibClause(token string) bool {
+	switch token {
+	case "SYNTAX", "UNITS", "MAX-ACCESS", "ACCESS", "STATUS", "DESCRIPTION", "REFERENCE",
+		"INDEX", "AUGMENTS", "DEFVAL", "DISPLAY-HINT":
+		return true
+	}
+	return false
+}
+
+// parseSyntax reads a type such as `Counter32`, `OCTET STRING (SIZE (0..255))`
+// or `INTEGER { up(1), down(2) }` and returns the position after it
+func parseSyntax(tokens []string, start int) (mibSyntax, int) {
+	var syntax mibSyntax
+	j := start
+	if j >= len(tokens) {
+		return syntax, j
+	}
+	switch {
+	case tokens[j] == "OCTET" || tokens[j] == "OBJECT":
+		if j+1 < len(tokens) {
+			syntax.typeName = tokens[j] + " " + tokens[j+1]
+		}
+		j += 2
+	case tokens[j] == "SEQUENCE" && j+1 < len(tokens) && tokens[j+1] == "OF":
+		if j+2 < len(tokens) {
+			syntax.typeName = "SEQUENCE OF " + tokens[j+2]
+		}
+		j += 3
+	default:
+		syntax.typeName = tokens[j]
+		j++
+	}
+	for j < len(tokens) {
+		switch tokens[j] {
+		case "{":
+			end := skipBalanced(tokens, j, "{", "}")
+			syntax.namedNumbers = parseNamedNumbers(tokens[j+1 : end-1])
+			j = end
+		case "(":
+			j = skipBalanced(tokens, j, "(", ")")
+		default:
+			return syntax, j
+		}
+	}
+	return syntax, j
+}
+
+// parseNamedNumbers reads the `name(n), ...` list of an enumerated INTEGER or BITS
+func parseNamedNumbers(tokens []string) map[int]string {
+	named := make(map[int]string)
+	for j := 0; j+3 < len(tokens); j++ {
+		if tokens[j+1] == "(" && tokens[j+3] == ")" {
+			if n, err := strconv.Atoi(tokens[j+2]); err == nil {
+				named[n] = tokens[j]
+			}
+			j += 3
+		}
+	}
+	return named
+}
+
+// parseBracedList reads `{ a, b, IMPLIED c }` and returns the names it contains
+func parseBracedList(tokens []string, start int) ([]string, int) {
+	if start >= len(tokens) || tokens[start] != "{" {
+		return nil, start
+	}
+	end := skipBalanced(tokens, start, "{", "}")
+	var names []string
+	for _, token := range tokens[start+1 : end-1] {
+		if token == "," || token == "IMPLIED" {
+			continue
+		}
+		names = append(names, token)
+	}
+	return names, end
+}
+
+// skipBalanced returns the position after the bracket that closes the one at tokens[start]
+func skipBalanced(tokens []string, start int, open, closing string) int {
+	if start >= len(tokens) || tokens[start] != open {
+		return start
+	}
+	depth := 0
+	for j := start; j < len(tokens); j++ {
+		switch tokens[j] {
+		case open:
+			depth++
+		case closing:
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// parseOidValue reads an OID value `{ parent 1 }` starting at tokens[start]
+// and returns its components and the position of the closing brace
+func parseOidValue(tokens []string, start int) ([]oidComponent, int, error) {
+	if start >= len(tokens) || tokens[start] != "{" {
+		return nil, start, fmt.Errorf("expected OID value")
+	}
+	var components []oidComponent
+	for j := start + 1; j < len(tokens); j++ {
+		token := tokens[j]
+		if token == "}" {
+			if len(components) == 0 {
+				return nil, j, fmt.Errorf("empty OID value")
+			}
+			return components, j, nil
+		}
+		if n, err := strconv.Atoi(token); err == nil {
+			components = append(components, oidComponent{number: n, hasNum: true})
+			continue
+		}
+		component := oidComponent{name: token}
+		if j+3 < len(tokens) && tokens[j+1] == "(" && tokens[j+3] == ")" {
+			if n, err := strconv.Atoi(tokens[j+2]); err == nil {
+				component.number = n
+				component.hasNum = true
+			}
+			j += 3
+		}
+		components = append(components, component)
+	}
+	return nil, len(tokens), fmt.Errorf("unterminated OID value")
+}
+
+// resolve computes the numeric OID of every pending definition and links
+// the nodes into a tree. Definitions whose parents never resolve are dropped
+func (r *mibRegistry) resolve() {
+	for progress := true; progress && len(r.pending) > 0; {
+		progress = false
+		var unresolved []pendingOid
+		for _, p := range r.pending {
+			oid, ok := r.componentsToOid(p.components)
+			if !ok {
+				unresolved = append(unresolved, p)
+				continue
+			}
+			p.node.oid = oid
+			r.add(p.node)
+			progress = true
+		}
+		r.pending = unresolved
+	}
+	for _, p := range r.pending {
+		log.Debug("unable to resolve OID of MIB object %s::%s", p.node.module, p.node.name)
+	}
+	r.pending = nil
+
+	for _, node := range r.byOid {
+		node.children = nil
+	}
+	for oid, node := range r.byOid {
+		if parent, ok := r.byOid[parentOid(oid)]; ok && parent != node {
+			parent.children = append(parent.children, node)
+		}
+	}
+	for _, node := range r.byOid {
+		sort.Slice(node.children, func(i, j int) bool {
+			return compareOids(node.children[i].oid, node.children[j].oid) < 0
+		})
+	}
+}
+
+func (r *mibRegistry) componentsToOid(components []oidComponent) (string, bool) {
+	oid := ""
+	for i, component := range components {
+		if i == 0 && !component.hasNum {
+			parent, ok := r.nodes[component.name]
+			if !ok || parent.oid == "" {
+				return "", false
+			}
+			oid = parent.oid
+			continue
+		}
+		oid = oid + "." + strconv.Itoa(component.number)
+		if component.name != "" {
+			if _, ok := r.nodes[component.name]; !ok {
+				r.add(&mibNode{name: component.name, oid: oid})
+			}
+		}
+	}
+	return oid, true
+}
+
+func (r *mibRegistry) add(node *mibNode) {
+	r.nodes[node.name] = node
+	if node.module != "" {
+		r.nodes[node.module+"::"+node.name] = node
+	}
+	if existing, ok := r.byOid[node.oid]; !ok || existing.module == "" {
+		r.byOid[node.oid] = node
+	}
+}
+
+// parentOid drops the last arc of an OID
+func parentOid(oid string) string {
+	if i := strings.LastIndex(oid, "."); i > 0 {
+		return oid[:i]
+	}
+	return ""
+}
+
+// lookup finds a node by `name` or `MODULE::name`
+func (r *mibRegistry) lookup(name string) *mibNode {
+	if r == nil {
+		return nil
+	}
+	return r.nodes[strings.TrimSpace(name)]
+}
+
+// resolveOid returns the numeric OID of an OID written in a collection file,
+// either numeric or symbolic such as `IF-MIB::ifHCInOctets`, `sysUpTime.0`
+// or `ifDescr.1`. Symbolic OIDs require their MIB to be loaded from mib_dirs
+func resolveOid(oid string) (string, error) {
+	oid = strings.TrimSpace(oid)
+	if oid == "" || strings.Trim(oid, ".0123456789") == "" {
+		return normalizeOid(oid), nil
+	}
+	name, suffix := oid, ""
+	start := strings.LastIndex(oid, "::") + 1
+	if dot := strings.Index(oid[start:], "."); dot >= 0 {
+		name, suffix = oid[:start+dot], oid[start+dot:]
+	}
+	if strings.Trim(suffix, ".0123456789") != "" {
+		return "", fmt.Errorf("invalid OID %s", oid)
+	}
+	if mibs == nil {
+		return "", fmt.Errorf("symbolic OID %s requires MIBs to be loaded with mib_dirs", oid)
+	}
+	node := mibs.lookup(name)
+	if node == nil {
+		return "", fmt.Errorf("unknown MIB object %s", name)
+	}
+	return node.oid + suffix, nil
+}
+
+// smiBaseTypes are the types textual conventions are ultimately defined in terms of
+var smiBaseTypes = map[string]bool{
+	"INTEGER":           true,
+	"OCTET STRING":      true,
+	"OBJECT IDENTIFIER": true,
+	"BITS":              true,
+	"Integer32":         true,
+	"Unsigned32":        true,
+	"Counter":           true,
+	"Counter32":         true,
+	"Counter64":         true,
+	"Gauge":             true,
+	"Gauge32":           true,
+	"TimeTicks":         true,
+	"IpAddress":         true,
+	"NetworkAddress":    true,
+	"Opaque":            true,
+}
+
+// node returns the node defined for exactly the given OID
+func (r *mibRegistry) node(oid string) *mibNode {
+	if r == nil {
+		return nil
+	}
+	return r.byOid[normalizeOid(oid)]
+}
+
+// translate renders an OID as `MODULE::name.suffix` using the longest matching
+// MIB node. The OID is returned unchanged when no node matches
+func (r *mibRegistry) translate(oid string) string {
+	node, suffix := r.longestMatch(oid)
+	if node == nil {
+		return normalizeOid(oid)
+	}
+	return node.module + "::" + node.name + suffix
+}
+
+// translateOids renders, like snmptranslate, each of a comma separated list of
+// OIDs on its own line: numeric OIDs as `MODULE::name.suffix` and symbolic
+// OIDs as numeric OIDs
+func translateOids(oids string) (string, error) {
+	if mibs == nil {
+		return "", fmt.Errorf("no MIBs are loaded, set mib_dirs")
+	}
+	var lines []string
+	for _, oid := range strings.Split(oids, ",") {
+		oid = strings.TrimSpace(oid)
+		if oid == "" {
+			continue
+		}
+		if strings.HasPrefix(oid, "iso.") {
+			oid = "1." + strings.TrimPrefix(oid, "iso.")
+		}
+		if strings.Trim(oid, ".0123456789") != "" {
+			resolved, err := resolveOid(oid)
+			if err != nil {
+				return "", err
+			}
+			lines = append(lines, resolved)
+			continue
+		}
+		if node, _ := mibs.longestMatch(oid); node == nil {
+			return "", fmt.Errorf("no MIB object defines %s", normalizeOid(oid))
+		}
+		lines = append(lines, mibs.translate(oid))
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// objectName renders an OID as `name.suffix`, e.g. `ifInDiscards.3`, using the
+// longest matching MIB node. The OID is returned unchanged when no node matches
+func (r *mibRegistry) objectName(oid string) string {
+	node, suffix := r.longestMatch(oid)
+	if node == nil {
+		return normalizeOid(oid)
+	}
+	return node.name + suffix
+}
+
+// longestMatch finds the MIB object defining the longest prefix of an OID
+// and returns it with the rest of the OID
+func (r *mibRegistry) longestMatch(oid string) (*mibNode, string) {
+	if r == nil {
+		return nil, ""
+	}
+	oid = normalizeOid(oid)
+	for prefix := oid; prefix != ""; prefix = parentOid(prefix) {
+		node, ok := r.byOid[prefix]
+		if !ok || node.module == "" {
+			continue
+		}
+		return node, oid[len(prefix):]
+	}
+	return nil, ""
+}
+
+// baseType follows textual conventions down to the SMI base type of a syntax
+func (r *mibRegistry) baseType(syntax mibSyntax) string {
+	typeName := syntax.typeName
+	for depth := 0; depth < 10 && !smiBaseTypes[typeName]; depth++ {
+		tc, ok := r.textualConventions[typeName]
+		if !ok || tc.typeName == typeName {
+			break
+		}
+		typeName = tc.typeName
+	}
+	return typeName
+}
+
+// isAccessible reports whether the object can be read with a get or walk
+func (n *mibNode) isAccessible() bool {
+	return n.access != "not-accessible" && n.access != "accessible-for-notify"
+}
+
+// mibMetricType derives the metric type used for an object from its SMI base type,
+// counters are reported as rates like from_mib proposes.
+// Opaque objects are left to the PDU type, as Opaque wrapped floats are reported as gauges
+func (r *mibRegistry) mibMetricType(node *mibNode) metricSourceType {
+	switch r.baseType(node.syntax) {
+	case "Counter", "Counter32", "Counter64":
+		return rate
+	case "OCTET STRING", "OBJECT IDENTIFIER", "IpAddress", "NetworkAddress", "BITS":
+		return attribute
+	}
+	return unset
+}
+
+// enumeration returns the labels of the enumerated INTEGER object an OID is
+// an instance of, following textual conventions. It is nil when the loaded
+// MIBs define no enumeration for the OID
+func (r *mibRegistry) enumeration(oid string) map[int]string {
+	node, _ := r.longestMatch(oid)
+	if node == nil || len(node.children) > 0 || r.baseType(node.syntax) != "INTEGER" {
+		return nil
+	}
+	return r.namedNumbers(node.syntax)
+}
+
+// loadMibDirs loads the MIB modules configured in the mib_dirs argument,
+// from the index cached in mib_cache while none of their files changed
+func loadMibDirs() error {
+	if strings.TrimSpace(args.MibDirs) == "" {
+		return nil
+	}
+	dirs := strings.Split(args.MibDirs, ",")
+	var registry *mibRegistry
+	var err error
+	if path := mibCachePath(); path != "" {
+		registry, err = loadCachedMibs(dirs, path)
+	} else {
+		registry, err = loadMibs(dirs)
+	}
+	if err != nil {
+		return err
+	}
+	mibs = registry
+	return nil
+}
+
+// tableDefinition derives the root OID, index definitions and column metrics
+// of a conceptual table from its MIB definition. Columns that cannot be read
+// and the columns used as index are not returned as metrics
+func (r *mibRegistry) tableDefinition(name string) (string, []*index, []*metricDef, error) {
+	table := r.lookup(name)
+	if table == nil {
+		return "", nil, nil, fmt.Errorf("table %s not found in the loaded MIBs", name)
+	}
+	if len(table.children) == 0 || !strings.HasPrefix(table.syntax.typeName, "SEQUENCE OF") {
+		return "", nil, nil, fmt.Errorf("%s is not a MIB table", name)
+	}
+	entry := table.children[0]
+
+	indexNames := entry.index
+	if entry.augments != "" {
+		if base := r.lookup(entry.augments); base != nil {
+			indexNames = base.index
+		}
+	}
+	var indexes []*index
+	indexColumns := make(map[string]bool)
+	for _, indexName := range indexNames {
+		node := r.lookup(indexName)
+		if node == nil {
+			return "", nil, nil, fmt.Errorf("index %s of table %s not found in the loaded MIBs", indexName, name)
+		}
+		indexColumns[node.oid] = true
+		if node.isAccessible() {
+			indexes = append(indexes, &index{oid: node.oid, name: node.name})
+		}
+	}
+
+	var metrics []*metricDef
+	for _, column := range entry.children {
+		if indexColumns[column.oid] || !column.isAccessible() {
+			continue
+		}
+		def := &metricDef{
+			oid:        column.oid,
+			metricName: column.name,
+			metricType: r.mibMetricType(column),
+		}
+		if r.baseType(column.syntax) == "BITS" && len(column.syntax.namedNumbers) > 0 {
+			def.metricType, def.format, def.bits = unset, "bits", column.syntax.namedNumbers
+		}
+		def.values = r.enumeration(column.oid)
+		r.applyHints(def)
+		metrics = append(metrics, def)
+	}
+	return table.oid, indexes, metrics, nil
+}
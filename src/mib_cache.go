@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/log"
+	"github.com/newrelic/infra-integrations-sdk/persist"
+)
+
+// mibCacheVersion is bumped whenever the layout of the cache or the way MIBs
+// are parsed changes, so caches written by older versions are rebuilt
+const mibCacheVersion = 2
+
+// noMibCache is the mib_cache value disabling the cache
+const noMibCache = "none"
+
+// mibCache is the compiled index of the MIBs of a set of directories, stored
+// with the fingerprint of the files it was built from
+type mibCache struct {
+	Version            int
+	Fingerprint        string
+	Nodes              []cachedMibNode
+	Names              map[string]int
+	Oids               map[string]int
+	TextualConventions map[string]cachedMibSyntax
+}
+
+type cachedMibNode struct {
+	Name        string
+	Module      string
+	Oid         string
+	Syntax      cachedMibSyntax
+	Access      string
+	Index       []string
+	Augments    string
+	Units       string
+	DisplayHint string
+}
+
+type cachedMibSyntax struct {
+	TypeName     string
+	NamedNumbers map[int]string
+	DisplayHint  string
+}
+
+// mibCachePath returns the file caching the MIB index, empty when disabled
+func mibCachePath() string {
+	switch path := strings.TrimSpace(args.MibCache); path {
+	case noMibCache:
+		return ""
+	case "":
+		return filepath.Join(filepath.Dir(persist.DefaultPath(integrationName)), integrationName+".mibs.gob")
+	default:
+		return path
+	}
+}
+
+// loadCachedMibs returns the MIBs of the given directories from the cache,
+// parsing them and refreshing the cache when a file was added, removed or
+// modified since it was built
+func loadCachedMibs(dirs []string, path string) (*mibRegistry, error) {
+	fingerprint, err := mibFingerprint(dirs)
+	if err != nil {
+		return nil, err
+	}
+	if registry, ok := readMibCache(path, fingerprint); ok {
+		log.Debug("loaded the MIB index from %s", path)
+		return registry, nil
+	}
+	registry, err := loadMibs(dirs)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeMibCache(path, fingerprint, registry); err != nil {
+		log.Warn("unable to cache the MIB index in %s: %v", path, err)
+	}
+	return registry, nil
+}
+
+// mibFingerprint hashes the names, sizes and modification times of the files
+// of the MIB directories
+func mibFingerprint(dirs []string) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d\n", mibCacheVersion)
+	for _, dir := range dirs {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return "", fmt.Errorf("unable to read MIB directory %s: %v", dir, err)
+		}
+		fmt.Fprintf(hash, "%s\n", dir)
+		for _, file := range files {
+			if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+				continue
+			}
+			fmt.Fprintf(hash, "%s %d %d\n", file.Name(), file.Size(), file.ModTime().UnixNano())
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// readMibCache loads the cached index, ok is false if it is missing, unreadable or stale
+func readMibCache(path string, fingerprint string) (*mibRegistry, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+	var cache mibCache
+	if err := gob.NewDecoder(file).Decode(&cache); err != nil {
+		log.Debug("discarding the MIB index cached in %s: %v", path, err)
+		return nil, false
+	}
+	if cache.Version != mibCacheVersion || cache.Fingerprint != fingerprint {
+		return nil, false
+	}
+	return cache.registry(), true
+}
+
+// writeMibCache replaces the cached index atomically, so concurrent runs never read a partial file
+func writeMibCache(path string, fingerprint string, r *mibRegistry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := gob.NewEncoder(file).Encode(newMibCache(fingerprint, r)); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// newMibCache flattens a resolved registry. Nodes are shared between their
+// names, `MODULE::name` keys and OIDs, so both indexes refer to them by position
+func newMibCache(fingerprint string, r *mibRegistry) *mibCache {
+	cache := &mibCache{
+		Version:            mibCacheVersion,
+		Fingerprint:        fingerprint,
+		Names:              make(map[string]int),
+		Oids:               make(map[string]int),
+		TextualConventions: make(map[string]cachedMibSyntax),
+	}
+	positions := make(map[*mibNode]int)
+	position := func(node *mibNode) int {
+		if i, ok := positions[node]; ok {
+			return i
+		}
+		positions[node] = len(cache.Nodes)
+		cache.Nodes = append(cache.Nodes, cachedMibNode{
+			Name:        node.name,
+			Module:      node.module,
+			Oid:         node.oid,
+			Syntax:      cachedMibSyntax{TypeName: node.syntax.typeName, NamedNumbers: node.syntax.namedNumbers},
+			Access:      node.access,
+			Index:       node.index,
+			Augments:    node.augments,
+			Units:       node.units,
+			DisplayHint: node.displayHint,
+		})
+		return positions[node]
+	}
+	for name, node := range r.nodes {
+		cache.Names[name] = position(node)
+	}
+	for oid, node := range r.byOid {
+		cache.Oids[oid] = position(node)
+	}
+	for name, syntax := range r.textualConventions {
+		cache.TextualConventions[name] = cachedMibSyntax{TypeName: syntax.typeName, NamedNumbers: syntax.namedNumbers, DisplayHint: syntax.displayHint}
+	}
+	return cache
+}
+
+// registry rebuilds the registry and the OID tree from the cached index
+func (c *mibCache) registry() *mibRegistry {
+	r := &mibRegistry{
+		nodes:              make(map[string]*mibNode, len(c.Names)),
+		byOid:              make(map[string]*mibNode, len(c.Oids)),
+		textualConventions: make(map[string]mibSyntax, len(c.TextualConventions)),
+	}
+	nodes := make([]*mibNode, len(c.Nodes))
+	for i, cached := range c.Nodes {
+		nodes[i] = &mibNode{
+			name:        cached.Name,
+			module:      cached.Module,
+			oid:         cached.Oid,
+			syntax:      mibSyntax{typeName: cached.Syntax.TypeName, namedNumbers: cached.Syntax.NamedNumbers},
+			access:      cached.Access,
+			index:       cached.Index,
+			augments:    cached.Augments,
+			units:       cached.Units,
+			displayHint: cached.DisplayHint,
+		}
+	}
+	for name, i := range c.Names {
+		r.nodes[name] = nodes[i]
+	}
+	for oid, i := range c.Oids {
+		r.byOid[oid] = nodes[i]
+	}
+	for name, syntax := range c.TextualConventions {
+		r.textualConventions[name] = mibSyntax{typeName: syntax.TypeName, namedNumbers: syntax.NamedNumbers, displayHint: syntax.DisplayHint}
+	}
+	r.resolve()
+	return r
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMibCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mibs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "TEST-IF-MIB.txt"), []byte(testMib), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "cache", "mibs.gob")
+	dirs := []string{dir}
+
+	parsed, err := loadCachedMibs(dirs, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := mibFingerprint(dirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, ok := readMibCache(path, fingerprint)
+	if !ok {
+		t.Fatal("the MIB index was not cached")
+	}
+	for _, name := range []string{"ifTable", "TEST-IF-MIB::ifName", "ifOperStatus"} {
+		if cached.lookup(name) == nil || cached.lookup(name).oid != parsed.lookup(name).oid {
+			t.Errorf("%s differs in the cached index", name)
+		}
+	}
+	if _, _, metrics, err := cached.tableDefinition("ifTable"); err != nil || len(metrics) != 3 {
+		t.Errorf("unexpected cached table definition %v %v", metrics, err)
+	}
+	if voltage := cached.lookup("testVoltage"); voltage.units != "volts" || cached.displayHint(voltage) != "d-2" {
+		t.Errorf("unexpected cached units %q and display hint %q", voltage.units, cached.displayHint(voltage))
+	}
+	if cached.objectName(".1.3.6.1.2.1.2.2.1.10.3") != "ifInOctets.3" {
+		t.Errorf("unexpected object name %s", cached.objectName(".1.3.6.1.2.1.2.2.1.10.3"))
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "OTHER-MIB.txt"), []byte("OTHER-MIB DEFINITIONS ::= BEGIN\nEND\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := mibFingerprint(dirs); changed == fingerprint {
+		t.Error("adding a MIB file should change the fingerprint")
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// generatedCollection is the layout of a collection file generated from a MIB,
+// a walk or a profile of another tool
+type generatedCollection struct {
+	SysObjectIds []string          `yaml:"sys_object_ids,omitempty"`
+	Extends      []string          `yaml:"extends,omitempty"`
+	Collect      []generatedDevice `yaml:"collect"`
+}
+
+type generatedDevice struct {
+	Device     string               `yaml:"device"`
+	MetricSets []generatedMetricSet `yaml:"metric_sets"`
+}
+
+type generatedMetricSet struct {
+	Name      string            `yaml:"name"`
+	Type      string            `yaml:"type"`
+	EventType string            `yaml:"event_type"`
+	RootOid   string            `yaml:"root_oid,omitempty"`
+	Table     string            `yaml:"table,omitempty"`
+	Index     []generatedMetric `yaml:"index,omitempty"`
+	Metrics   []generatedMetric `yaml:"metrics"`
+
+	IndexComponents []generatedIndexComponent `yaml:"index_components,omitempty"`
+	Attributes      map[string]string         `yaml:"attributes,omitempty"`
+}
+
+type generatedIndexComponent struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+}
+
+type generatedMetric struct {
+	MetricName string         `yaml:"metric_name,omitempty"`
+	Oid        string         `yaml:"oid"`
+	MetricType string         `yaml:"metric_type,omitempty"`
+	Format     string         `yaml:"format,omitempty"`
+	Bits       map[int]string `yaml:"bits,omitempty"`
+	Values     map[int]string `yaml:"values,omitempty"`
+	EmitCode   bool           `yaml:"emit_code,omitempty"`
+	Scale      *float64       `yaml:"scale,omitempty"`
+	Extract    string         `yaml:"extract,omitempty"`
+}
+
+// textualConventionFormats are the formats used for the columns of well known textual conventions
+var textualConventionFormats = map[string]string{
+	"PhysAddress": "mac",
+	"MacAddress":  "mac",
+	"DateAndTime": "date_and_time",
+	"TruthValue":  "truthvalue",
+}
+
+// generateCollection renders a collection file with a table metric set for
+// every table of a MIB module, or for a single table named `MODULE::table`
+func generateCollection(name string) ([]byte, error) {
+	if mibs == nil {
+		return nil, fmt.Errorf("no MIBs are loaded, set mib_dirs")
+	}
+	name = strings.TrimSpace(name)
+	module := name
+	var tables []*mibNode
+	if i := strings.Index(name, "::"); i >= 0 {
+		module = name[:i]
+		table := mibs.lookup(name)
+		if table == nil {
+			return nil, fmt.Errorf("table %s not found in the loaded MIBs", name)
+		}
+		tables = append(tables, table)
+	} else {
+		tables = mibs.moduleTables(module)
+		if len(tables) == 0 {
+			return nil, fmt.Errorf("no tables of MIB module %s found in the loaded MIBs", module)
+		}
+	}
+
+	device := generatedDevice{Device: module}
+	for _, table := range tables {
+		metricSet, err := mibs.generateMetricSet(table)
+		if err != nil {
+			return nil, err
+		}
+		device.MetricSets = append(device.MetricSets, metricSet)
+	}
+	return yaml.Marshal(generatedCollection{Collect: []generatedDevice{device}})
+}
+
+// moduleTables returns the tables defined by a MIB module ordered by OID
+func (r *mibRegistry) moduleTables(module string) []*mibNode {
+	var tables []*mibNode
+	for key, node := range r.nodes {
+		//nodes are indexed both by name and by MODULE::name, only keep one of them
+		if node.module != module || key != node.name || len(node.children) == 0 || !strings.HasPrefix(node.syntax.typeName, "SEQUENCE OF") {
+			continue
+		}
+		tables = append(tables, node)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return compareOids(tables[i].oid, tables[j].oid) < 0
+	})
+	return tables
+}
+
+// generateMetricSet builds the metric set of a table with all its readable
+// columns, typed from their MIB syntax
+func (r *mibRegistry) generateMetricSet(table *mibNode) (generatedMetricSet, error) {
+	rootOid, indexes, metrics, err := r.tableDefinition(table.module + "::" + table.name)
+	if err != nil {
+		return generatedMetricSet{}, err
+	}
+	metricSet := generatedMetricSet{
+		Name:      table.name,
+		Type:      "table",
+		EventType: sampleEventType(table.name),
+		RootOid:   rootOid,
+	}
+	for _, index := range indexes {
+		metricSet.Index = append(metricSet.Index, generatedMetric{MetricName: index.name, Oid: index.oid})
+	}
+	for _, def := range metrics {
+		metricSet.Metrics = append(metricSet.Metrics, r.generateMetric(r.node(def.oid)))
+	}
+	return metricSet, nil
+}
+
+// generateMetric types a column from its MIB syntax: counters are reported as
+// rates, strings and enumerations as attributes and anything else as gauges
+func (r *mibRegistry) generateMetric(column *mibNode) generatedMetric {
+	generated := generatedMetric{MetricName: column.name, Oid: column.oid}
+	if format, ok := textualConventionFormats[column.syntax.typeName]; ok {
+		generated.Format = format
+		return generated
+	}
+	baseType := r.baseType(column.syntax)
+	if namedNumbers := r.namedNumbers(column.syntax); len(namedNumbers) > 0 {
+		if baseType == "BITS" {
+			generated.Format, generated.Bits = "bits", namedNumbers
+		} else {
+			generated.MetricType, generated.Values = "attribute", namedNumbers
+		}
+		return generated
+	}
+	switch baseType {
+	case "Counter", "Counter32", "Counter64":
+		generated.MetricType = "rate"
+	case "OCTET STRING", "OBJECT IDENTIFIER", "IpAddress", "NetworkAddress", "BITS":
+		generated.MetricType = "attribute"
+	default:
+		generated.MetricType = "gauge"
+	}
+	return generated
+}
+
+// namedNumbers returns the enumeration of a syntax, following textual conventions
+func (r *mibRegistry) namedNumbers(syntax mibSyntax) map[int]string {
+	for depth := 0; depth < 10 && len(syntax.namedNumbers) == 0; depth++ {
+		tc, ok := r.textualConventions[syntax.typeName]
+		if !ok || tc.typeName == syntax.typeName {
+			break
+		}
+		syntax = tc
+	}
+	return syntax.namedNumbers
+}
+
+// sampleEventType names the event type of a generated metric set, e.g. IfTableSample
+func sampleEventType(name string) string {
+	if name == "" {
+		return "SNMPSample"
+	}
+	return strings.ToUpper(name[:1]) + name[1:] + "Sample"
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestGenerateCollection(t *testing.T) {
+	mibs = loadTestMib(t)
+	defer func() { mibs = nil }()
+	out, err := generateCollection("TEST-IF-MIB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := collectionParser{}
+	if err := yaml.Unmarshal(out, &parser); err != nil {
+		t.Fatal(err)
+	}
+	collections, err := parseCollection(&parser)
+	if err != nil {
+		t.Fatalf("generated collection does not parse: %v\n%s", err, out)
+	}
+	metricSets := collections[0].MetricSets
+	if len(metricSets) != 2 || metricSets[0].Name != "ifTable" || metricSets[1].Name != "ifXTable" {
+		t.Fatalf("unexpected metric sets %+v", metricSets)
+	}
+	types := []metricSourceType{attribute, attribute, rate}
+	for i, def := range metricSets[0].Metrics {
+		if def.metricType != types[i] {
+			t.Errorf("unexpected type of %s: %v", def.metricName, def.metricType)
+		}
+	}
+	if status := metricSets[0].Metrics[1]; status.values[3] != "testing" {
+		t.Errorf("unexpected enumeration of %s: %v", status.metricName, status.values)
+	}
+
+	if _, err := generateCollection("TEST-IF-MIB::ifUnknown"); err == nil {
+		t.Error("expected error for an unknown table")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// fractionalUnits are the UNITS prefixes of values counted in fractions of
+// a unit, such as "hundredths of a second" or "deci-degrees Celsius"
+var fractionalUnits = []struct {
+	prefix string
+	scale  float64
+}{
+	{"tenths of a ", 0.1},
+	{"tenths of ", 0.1},
+	{"hundredths of a ", 0.01},
+	{"hundredths of ", 0.01},
+	{"thousandths of a ", 0.001},
+	{"thousandths of ", 0.001},
+	{"deci-", 0.1},
+	{"centi-", 0.01},
+	{"0.1 ", 0.1},
+	{"0.01 ", 0.01},
+	{"0.001 ", 0.001},
+}
+
+// scaledBaseTypes are the SMI base types whose values are scaled by their
+// UNITS and DISPLAY-HINT. TimeTicks are already reported in seconds
+var scaledBaseTypes = map[string]bool{
+	"INTEGER":    true,
+	"Integer32":  true,
+	"Unsigned32": true,
+	"Gauge":      true,
+	"Gauge32":    true,
+	"Counter":    true,
+	"Counter32":  true,
+	"Counter64":  true,
+}
+
+// unitScale splits UNITS such as "hundredths of a second" into the scale
+// converting values to the unit, 0.01, and that unit, "second". Units that
+// are not fractional are returned as is with a scale of 0
+func unitScale(units string) (float64, string) {
+	lower := strings.ToLower(units)
+	for _, fractional := range fractionalUnits {
+		if strings.HasPrefix(lower, fractional.prefix) && len(units) > len(fractional.prefix) {
+			return fractional.scale, units[len(fractional.prefix):]
+		}
+	}
+	return 0, units
+}
+
+// displayHintScale returns the scale of an integer DISPLAY-HINT such as
+// "d-2", which places a decimal point before the last 2 digits, or 0
+func displayHintScale(hint string) float64 {
+	if !strings.HasPrefix(hint, "d-") {
+		return 0
+	}
+	decimals, err := strconv.Atoi(hint[2:])
+	if err != nil || decimals <= 0 || decimals > 10 {
+		return 0
+	}
+	return math.Pow10(-decimals)
+}
+
+// displayHintFormat returns the metric format rendering an OctetString like
+// its DISPLAY-HINT, empty for text and hints without a matching format
+func displayHintFormat(hint string) string {
+	switch {
+	case hint == "1x:":
+		return "mac"
+	case hint == "1x":
+		return "hex"
+	case strings.HasPrefix(hint, "2d-1d-1d,"):
+		return "date_and_time"
+	}
+	return ""
+}
+
+// displayHint returns the DISPLAY-HINT of an object, or of the textual
+// convention it is defined with
+func (r *mibRegistry) displayHint(node *mibNode) string {
+	if node.displayHint != "" {
+		return node.displayHint
+	}
+	syntax := node.syntax
+	for depth := 0; depth < 10; depth++ {
+		tc, ok := r.textualConventions[syntax.typeName]
+		if !ok || tc.typeName == syntax.typeName {
+			break
+		}
+		if tc.displayHint != "" {
+			return tc.displayHint
+		}
+		syntax = tc
+	}
+	return ""
+}
+
+// applyHints completes a metric with the UNITS and DISPLAY-HINT of the MIB
+// object defining its OID. Numeric values are scaled by a fractional unit or
+// a "d-N" hint and the unit is reported as `<metricName>Unit`; OctetStrings
+// are formatted like their hint. Metrics with a configured scale, or already
+// converted by a format, mask, lookup or enumeration, are left untouched
+func (r *mibRegistry) applyHints(def *metricDef) {
+	node, _ := r.longestMatch(def.oid)
+	if node == nil || len(node.children) > 0 {
+		return
+	}
+	if def.format != "" || def.mask != 0 || def.shift != 0 || def.lookup != nil || len(def.values) > 0 {
+		return
+	}
+	baseType := r.baseType(node.syntax)
+	if baseType == "OCTET STRING" {
+		switch def.metricType {
+		case unset, auto, attribute:
+		default:
+			return
+		}
+		format, ok := textualConventionFormats[node.syntax.typeName]
+		if !ok {
+			format = displayHintFormat(r.displayHint(node))
+		}
+		def.format = format
+		return
+	}
+	if !scaledBaseTypes[baseType] || def.isScaled() {
+		return
+	}
+	scale, unit := unitScale(node.units)
+	if hintScale := displayHintScale(r.displayHint(node)); hintScale != 0 {
+		if scale != hintScale {
+			//the units name the scaled value, e.g. "degrees Celsius" for "d-1"
+			unit = node.units
+		}
+		scale = hintScale
+	}
+	def.scale = scale
+	if def.unit == "" {
+		def.unit = unit
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestMibHints(t *testing.T) {
+	mibs = loadTestMib(t)
+	defer func() { mibs = nil }()
+	var parsers []metricParser
+	source := `
+- oid: testTemperature.0
+- oid: testVoltage.0
+- oid: testVoltage.0
+  scale: 1
+- oid: ifInOctets.1
+  unit: bytes
+`
+	if err := yaml.Unmarshal([]byte(source), &parsers); err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		scale float64
+		unit  string
+	}{
+		{0.1, "degrees Celsius"},
+		{0.01, "volts"},
+		{1, ""},
+		{0, "bytes"},
+	}
+	for i, parser := range parsers {
+		def, err := parseMetric(parser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if def.scale != expected[i].scale || def.unit != expected[i].unit {
+			t.Errorf("%s: unexpected scale %v and unit %q", parser.Oid, def.scale, def.unit)
+		}
+	}
+	if scale, unit := unitScale("hundredths of a second"); scale != 0.01 || unit != "second" {
+		t.Errorf("unexpected scale %v and unit %q", scale, unit)
+	}
+	if format := displayHintFormat("2d-1d-1d,1d:1d:1d.1d,1a1d:1d"); format != "date_and_time" {
+		t.Errorf("unexpected format %s", format)
+	}
+}
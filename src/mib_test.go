@@ -0,0 +1,288 @@
+package main
+
+import (
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const testMib = `
+TEST-IF-MIB DEFINITIONS ::= BEGIN
+
+IMPORTS
+    MODULE-IDENTITY, OBJECT-TYPE, Counter32, Integer32, mib-2
+        FROM SNMPv2-SMI
+    TEXTUAL-CONVENTION, DisplayString FROM SNMPv2-TC;
+
+testIfMIB MODULE-IDENTITY
+    LAST-UPDATED "200006140000Z"
+    ORGANIZATION "IETF -- not a comment"
+    DESCRIPTION  "A trimmed down interfaces MIB."
+    ::= { mib-2 31 }
+
+InterfaceIndex ::= TEXTUAL-CONVENTION
+    DISPLAY-HINT "d"
+    STATUS       current
+    DESCRIPTION  "An interface index."
+    SYNTAX       Integer32 (1..2147483647)
+
+interfaces OBJECT IDENTIFIER ::= { mib-2 2 }
+
+ifTable OBJECT-TYPE
+    SYNTAX      SEQUENCE OF IfEntry
+    MAX-ACCESS  not-accessible
+    STATUS      current
+    DESCRIPTION "A list of interface entries."
+    ::= { interfaces 2 }
+
+ifEntry OBJECT-TYPE
+    SYNTAX      IfEntry
+    MAX-ACCESS  not-accessible
+    STATUS      current
+    DESCRIPTION "An entry."
+    INDEX   { ifIndex }
+    ::= { ifTable 1 }
+
+IfEntry ::=
+    SEQUENCE {
+        ifIndex       InterfaceIndex,
+        ifDescr       DisplayString,
+        ifOperStatus  INTEGER,
+        ifInOctets    Counter32
+    }
+
+ifIndex OBJECT-TYPE
+    SYNTAX      InterfaceIndex
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "Index."
+    ::= { ifEntry 1 }
+
+ifDescr OBJECT-TYPE
+    SYNTAX      DisplayString (SIZE (0..255))
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "Description."
+    ::= { ifEntry 2 }
+
+ifOperStatus OBJECT-TYPE
+    SYNTAX  INTEGER {
+                up(1),        -- ready to pass packets
+                down(2),
+                testing(3)
+            }
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "Status."
+    ::= { ifEntry 8 }
+
+ifInOctets OBJECT-TYPE
+    SYNTAX      Counter32
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "Octets."
+    ::= { ifEntry 10 }
+
+ifXTable OBJECT-TYPE
+    SYNTAX      SEQUENCE OF IfXEntry
+    MAX-ACCESS  not-accessible
+    STATUS      current
+    DESCRIPTION "Extension."
+    ::= { testIfMIB 1 1 }
+
+ifXEntry OBJECT-TYPE
+    SYNTAX      IfXEntry
+    MAX-ACCESS  not-accessible
+    STATUS      current
+    DESCRIPTION "Extension entry."
+    AUGMENTS    { ifEntry }
+    ::= { ifXTable 1 }
+
+ifName OBJECT-TYPE
+    SYNTAX      DisplayString
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "Name."
+    ::= { ifXEntry 1 }
+
+Hundredths ::= TEXTUAL-CONVENTION
+    DISPLAY-HINT "d-2"
+    STATUS       current
+    DESCRIPTION  "A value in hundredths."
+    SYNTAX       Integer32
+
+testTemperature OBJECT-TYPE
+    SYNTAX      Integer32
+    UNITS       "deci-degrees Celsius"
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "Temperature."
+    ::= { testIfMIB 2 }
+
+testVoltage OBJECT-TYPE
+    SYNTAX      Hundredths
+    UNITS       "volts"
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "Voltage."
+    ::= { testIfMIB 3 }
+
+END
+`
+
+func loadTestMib(t *testing.T) *mibRegistry {
+	r := newMibRegistry()
+	if err := r.parse(testMib); err != nil {
+		t.Fatal(err)
+	}
+	r.resolve()
+	return r
+}
+
+func TestMibResolvesOids(t *testing.T) {
+	r := loadTestMib(t)
+	cases := map[string]string{
+		"ifTable":                   ".1.3.6.1.2.1.2.2",
+		"TEST-IF-MIB::ifInOctets":   ".1.3.6.1.2.1.2.2.1.10",
+		"ifXTable":                  ".1.3.6.1.2.1.31.1.1",
+		"TEST-IF-MIB::ifName":       ".1.3.6.1.2.1.31.1.1.1.1",
+		"TEST-IF-MIB::ifOperStatus": ".1.3.6.1.2.1.2.2.1.8",
+	}
+	for name, expected := range cases {
+		node := r.lookup(name)
+		if node == nil {
+			t.Errorf("%s not found", name)
+			continue
+		}
+		if node.oid != expected {
+			t.Errorf("%s resolved to %s, expected %s", name, node.oid, expected)
+		}
+	}
+	if status := r.lookup("ifOperStatus"); status.syntax.namedNumbers[2] != "down" {
+		t.Errorf("unexpected enumeration %v", status.syntax.namedNumbers)
+	}
+	if base := r.baseType(r.lookup("ifIndex").syntax); base != "Integer32" {
+		t.Errorf("unexpected base type %s", base)
+	}
+}
+
+func TestMibTableDefinition(t *testing.T) {
+	r := loadTestMib(t)
+	rootOid, indexes, metrics, err := r.tableDefinition("ifTable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rootOid != ".1.3.6.1.2.1.2.2" {
+		t.Errorf("unexpected root OID %s", rootOid)
+	}
+	if len(indexes) != 1 || indexes[0].name != "ifIndex" {
+		t.Fatalf("unexpected indexes %v", indexes)
+	}
+	if len(metrics) != 3 {
+		t.Fatalf("expected 3 columns, got %d", len(metrics))
+	}
+	if metrics[0].metricName != "ifDescr" || metrics[0].metricType != attribute {
+		t.Errorf("unexpected first column %+v", metrics[0])
+	}
+	if metrics[2].metricName != "ifInOctets" || metrics[2].metricType != rate {
+		t.Errorf("unexpected last column %+v", metrics[2])
+	}
+
+	if metrics[1].metricName != "ifOperStatus" || metrics[1].values[1] != "up" {
+		t.Errorf("unexpected enumeration column %+v", metrics[1])
+	}
+
+	_, indexes, _, err = r.tableDefinition("ifXTable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexes) != 1 || indexes[0].oid != ".1.3.6.1.2.1.2.2.1.1" {
+		t.Errorf("augmenting table should use the base table index, got %v", indexes)
+	}
+}
+
+func TestMibMetricType(t *testing.T) {
+	r := loadTestMib(t)
+	cases := map[string]metricSourceType{
+		"Counter32":    rate,
+		"Counter64":    rate,
+		"Gauge32":      unset,
+		"Integer32":    unset,
+		"Opaque":       unset,
+		"OCTET STRING": attribute,
+		"IpAddress":    attribute,
+		// textual conventions are followed down to their base type
+		"DisplayString":  attribute,
+		"InterfaceIndex": unset,
+	}
+	for typeName, expected := range cases {
+		node := &mibNode{syntax: mibSyntax{typeName: typeName}}
+		if metricType := r.mibMetricType(node); metricType != expected {
+			t.Errorf("%s: expected %s, got %s", typeName, metricTypeName(expected), metricTypeName(metricType))
+		}
+	}
+	if metricType := r.mibMetricType(r.lookup("ifInOctets")); metricType != rate {
+		t.Errorf("expected ifInOctets to be a rate, got %s", metricTypeName(metricType))
+	}
+}
+
+func TestResolveOid(t *testing.T) {
+	mibs = loadTestMib(t)
+	defer func() { mibs = nil }()
+	cases := map[string]string{
+		"1.3.6.1.2.1.1.3.0":           ".1.3.6.1.2.1.1.3.0",
+		"TEST-IF-MIB::ifInOctets":     ".1.3.6.1.2.1.2.2.1.10",
+		"ifInOctets.3":                ".1.3.6.1.2.1.2.2.1.10.3",
+		"TEST-IF-MIB::ifOperStatus.1": ".1.3.6.1.2.1.2.2.1.8.1",
+	}
+	for oid, expected := range cases {
+		if resolved, err := resolveOid(oid); err != nil || resolved != expected {
+			t.Errorf("resolveOid(%s) = %s, %v, expected %s", oid, resolved, err, expected)
+		}
+	}
+	if name := mibs.objectName(".1.3.6.1.2.1.2.2.1.10.3"); name != "ifInOctets.3" {
+		t.Errorf("unexpected object name %s", name)
+	}
+	for _, invalid := range []string{"ifUnknown", "ifInOctets.x"} {
+		if _, err := resolveOid(invalid); err == nil {
+			t.Errorf("expected error resolving %s", invalid)
+		}
+	}
+
+	translated, err := translateOids("1.3.6.1.2.1.2.2.1.10.1, ifDescr.2")
+	if err != nil || translated != "TEST-IF-MIB::ifInOctets.1\n.1.3.6.1.2.1.2.2.1.2.2\n" {
+		t.Errorf("unexpected translation %q, %v", translated, err)
+	}
+	if _, err := translateOids(".1.3.6.1.4.1.9999.1"); err == nil {
+		t.Error("expected error translating an OID no MIB object defines")
+	}
+}
+
+func TestMibEnumeration(t *testing.T) {
+	mibs = loadTestMib(t)
+	defer func() { mibs = nil }()
+	var parsers []metricParser
+	source := `
+- oid: ifOperStatus.2
+- oid: ifOperStatus.3
+  values: {1: ready}
+- oid: ifOperStatus.4
+  values: {}
+- oid: ifOperStatus.5
+  metric_type: gauge
+- oid: ifInOctets.1
+`
+	if err := yaml.Unmarshal([]byte(source), &parsers); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"up", "ready", "", "", ""}
+	for i, parser := range parsers {
+		def, err := parseMetric(parser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if def.values[1] != expected[i] {
+			t.Errorf("%s: unexpected values %v", parser.Oid, def.values)
+		}
+	}
+}
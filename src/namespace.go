@@ -0,0 +1,42 @@
+package main
+
+import (
+	"regexp"
+
+	"github.com/newrelic/infra-integrations-sdk/integration"
+)
+
+// validNamespace matches the event_type_namespace and metric_prefix accepted
+// in event types and metric names
+var validNamespace = regexp.MustCompile(`^[A-Za-z0-9_:]*$`)
+
+// applyNamespaces prefixes the event type of every metric set of the payload
+// with the event_type_namespace, such as `Staging` reporting
+// `StagingSNMPSample`, and the name of every numeric metric with the
+// metric_prefix. Attributes, such as device, name and index, keep their name
+func applyNamespaces(i *integration.Integration, eventTypeNamespace string, metricPrefix string) {
+	if eventTypeNamespace == "" && metricPrefix == "" {
+		return
+	}
+	for _, entity := range i.Entities {
+		for _, ms := range entity.Metrics {
+			if eventType, ok := ms.Metrics["event_type"].(string); ok {
+				ms.Metrics["event_type"] = eventTypeNamespace + eventType
+			}
+			if metricPrefix == "" {
+				continue
+			}
+			renamed := make(map[string]interface{})
+			for name, value := range ms.Metrics {
+				if _, attribute := value.(string); attribute || name == "event_type" {
+					continue
+				}
+				renamed[metricPrefix+name] = value
+				delete(ms.Metrics, name)
+			}
+			for name, value := range renamed {
+				ms.Metrics[name] = value
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/integration"
+)
+
+func TestApplyNamespaces(t *testing.T) {
+	i, err := integration.New("test", "0.0.0", integration.InMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity, err := i.Entity("router:161", "address")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := entity.NewMetricSet("SNMPInterfaceSample")
+	ms.SetMetric("name", "interfaces", metric.ATTRIBUTE)
+	ms.SetMetric("ifMtu", 1500, metric.GAUGE)
+	applyNamespaces(i, "Staging", "staging_")
+	expected := map[string]interface{}{
+		"event_type":    "StagingSNMPInterfaceSample",
+		"name":          "interfaces",
+		"staging_ifMtu": 1500.0,
+	}
+	if fmt.Sprint(ms.Metrics) != fmt.Sprint(expected) {
+		t.Errorf("unexpected metrics %v", ms.Metrics)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// formatDateAndTime decodes an RFC 2579 DateAndTime octet string (8 or 11 bytes)
+// into an ISO-8601 timestamp. Without timezone information the time is reported as is
+func formatDateAndTime(b []byte) (string, error) {
+	if len(b) != 8 && len(b) != 11 {
+		return "", fmt.Errorf("DateAndTime must be 8 or 11 bytes long, got %d", len(b))
+	}
+	year := int(b[0])<<8 | int(b[1])
+	value := fmt.Sprintf("%04d-%02d-%02dT%02d:%02d:%02d.%d", year, b[2], b[3], b[4], b[5], b[6], b[7])
+	if len(b) == 11 {
+		value += fmt.Sprintf("%c%02d:%02d", b[8], b[9], b[10])
+	}
+	return value, nil
+}
+
+// parseDateAndTime decodes an RFC 2579 DateAndTime octet string into a time.
+// Without timezone information the time is taken as UTC
+func parseDateAndTime(b []byte) (time.Time, error) {
+	if len(b) != 8 && len(b) != 11 {
+		return time.Time{}, fmt.Errorf("DateAndTime must be 8 or 11 bytes long, got %d", len(b))
+	}
+	location := time.UTC
+	if len(b) == 11 {
+		offset := int(b[9])*3600 + int(b[10])*60
+		if b[8] == '-' {
+			offset = -offset
+		}
+		location = time.FixedZone("", offset)
+	}
+	year := int(b[0])<<8 | int(b[1])
+	return time.Date(year, time.Month(b[2]), int(b[3]), int(b[4]), int(b[5]), int(b[6]), int(b[7])*int(100*time.Millisecond), location), nil
+}
+
+// formatInetAddress renders the octets of an InetAddress as an IPv4 or IPv6 address.
+// IPv4z and IPv6z addresses carry a 4 byte zone index after the address
+func formatInetAddress(b []byte) (string, error) {
+	switch len(b) {
+	case 4, 16:
+		return net.IP(b).String(), nil
+	case 8, 20:
+		zone := int(b[len(b)-4])<<24 | int(b[len(b)-3])<<16 | int(b[len(b)-2])<<8 | int(b[len(b)-1])
+		return fmt.Sprintf("%s%%%d", net.IP(b[:len(b)-4]).String(), zone), nil
+	case 0:
+		return "", nil
+	}
+	return "", fmt.Errorf("unsupported InetAddress length %d", len(b))
+}
+
+// formatMacAddress renders the octets of a MacAddress as colon separated
+// lowercase hex pairs, aa:bb:cc:dd:ee:ff
+func formatMacAddress(b []byte) string {
+	parts := make([]string, len(b))
+	for i, octet := range b {
+		parts[i] = fmt.Sprintf("%02x", octet)
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// normalizeOid trims an OID and forces the leading dot used by gosnmp
+// for absolute OIDs
+func normalizeOid(oid string) string {
+	oid = strings.TrimSpace(oid)
+	if !strings.HasPrefix(oid, ".") {
+		oid = "." + oid
+	}
+	return oid
+}
+
+// oidArcs splits a dotted OID into its arcs
+func oidArcs(oid string) []string {
+	oid = strings.Trim(strings.TrimSpace(oid), ".")
+	if oid == "" {
+		return nil
+	}
+	return strings.Split(oid, ".")
+}
+
+// oidHasPrefix reports whether an OID is prefix or one of its descendants
+func oidHasPrefix(oid, prefix string) bool {
+	oid, prefix = normalizeOid(oid), normalizeOid(prefix)
+	return oid == prefix || strings.HasPrefix(oid, prefix+".")
+}
+
+// compareOids orders two dotted OIDs arc by arc numerically, the way an
+// SNMP agent orders them. It returns -1, 0 or 1
+func compareOids(a, b string) int {
+	aArcs := oidArcs(a)
+	bArcs := oidArcs(b)
+	for i := 0; i < len(aArcs) && i < len(bArcs); i++ {
+		if aArcs[i] == bArcs[i] {
+			continue
+		}
+		aArc, aErr := strconv.ParseUint(aArcs[i], 10, 64)
+		bArc, bErr := strconv.ParseUint(bArcs[i], 10, 64)
+		if aErr != nil || bErr != nil {
+			if aArcs[i] < bArcs[i] {
+				return -1
+			}
+			return 1
+		}
+		if aArc < bArc {
+			return -1
+		}
+		return 1
+	}
+	switch {
+	case len(aArcs) < len(bArcs):
+		return -1
+	case len(aArcs) > len(bArcs):
+		return 1
+	}
+	return 0
+}
+
+// oidTree is a prefix tree over the arcs of column OIDs, matching a walked
+// OID to its column in a single pass over its arcs
+type oidTree struct {
+	children map[string]*oidTree
+	column   string
+}
+
+// newOidTree builds the prefix tree of the given column OIDs
+func newOidTree(columns []string) *oidTree {
+	root := &oidTree{}
+	for _, column := range columns {
+		node := root
+		for _, arc := range oidArcs(column) {
+			if node.children == nil {
+				node.children = make(map[string]*oidTree)
+			}
+			child, ok := node.children[arc]
+			if !ok {
+				child = &oidTree{}
+				node.children[arc] = child
+			}
+			node = child
+		}
+		node.column = normalizeOid(column)
+	}
+	return root
+}
+
+// match returns the deepest column the OID belongs to and the index key
+// following the column OID. ok is false if the OID is under no column
+func (t *oidTree) match(oid string) (column string, indexKey string, ok bool) {
+	if t == nil {
+		return "", "", false
+	}
+	arcs := oidArcs(oid)
+	node := t
+	for i, arc := range arcs {
+		child, found := node.children[arc]
+		if !found {
+			break
+		}
+		node = child
+		if node.column != "" && i+1 < len(arcs) {
+			column, indexKey, ok = node.column, strings.Join(arcs[i+1:], "."), true
+		}
+	}
+	return column, indexKey, ok
+}
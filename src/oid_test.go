@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestOidTreeMatch(t *testing.T) {
+	tree := newOidTree([]string{".1.3.6.1.2.1.2.2.1.2", "1.3.6.1.2.1.2.2.1.10", ".1.3.6.1.2.1.31.1.1.1.6"})
+	cases := []struct {
+		oid      string
+		column   string
+		indexKey string
+		ok       bool
+	}{
+		{".1.3.6.1.2.1.2.2.1.2.7", ".1.3.6.1.2.1.2.2.1.2", "7", true},
+		{".1.3.6.1.2.1.2.2.1.10.1.4", ".1.3.6.1.2.1.2.2.1.10", "1.4", true},
+		{".1.3.6.1.2.1.2.2.1.1.7", "", "", false},
+		{".1.3.6.1.2.1.2.2.1.2", "", "", false},
+		{".1.3.6.1.2.1.2.2.1.20.1", "", "", false},
+		{".1.3.6.1.2.1.31.1.1.1.6.3", ".1.3.6.1.2.1.31.1.1.1.6", "3", true},
+	}
+	for _, c := range cases {
+		column, indexKey, ok := tree.match(c.oid)
+		if column != c.column || indexKey != c.indexKey || ok != c.ok {
+			t.Errorf("match(%s) = %s, %s, %v, expected %s, %s, %v", c.oid, column, indexKey, ok, c.column, c.indexKey, c.ok)
+		}
+	}
+}
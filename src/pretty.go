@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/integration"
+)
+
+// groupMetricSets orders the entities of the payload by name and the metric
+// sets of each entity by event type, metric set name and row index, so the
+// rows of a table, walked concurrently or page by page, are printed together
+// and in index order by `pretty`
+func groupMetricSets(i *integration.Integration) {
+	sort.SliceStable(i.Entities, func(a, b int) bool {
+		return entityName(i.Entities[a]) < entityName(i.Entities[b])
+	})
+	for _, entity := range i.Entities {
+		sets := entity.Metrics
+		sort.SliceStable(sets, func(a, b int) bool {
+			return compareMetricSets(sets[a], sets[b]) < 0
+		})
+	}
+}
+
+// entityName is the name of an entity, empty for the local entity
+func entityName(entity *integration.Entity) string {
+	if entity.Metadata == nil {
+		return ""
+	}
+	return entity.Metadata.Name
+}
+
+func compareMetricSets(a, b *metric.Set) int {
+	for _, attribute := range []string{"event_type", "name"} {
+		aValue := fmt.Sprint(a.Metrics[attribute])
+		bValue := fmt.Sprint(b.Metrics[attribute])
+		switch {
+		case aValue < bValue:
+			return -1
+		case aValue > bValue:
+			return 1
+		}
+	}
+	aIndex, aOk := a.Metrics["index"].(string)
+	bIndex, bOk := b.Metrics["index"].(string)
+	if !aOk || !bOk {
+		return 0
+	}
+	return compareOids(aIndex, bIndex)
+}
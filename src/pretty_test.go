@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/integration"
+)
+
+func TestGroupMetricSets(t *testing.T) {
+	i, err := integration.New("test", "0.0.0", integration.InMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity, err := i.Entity("router:161", "address")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []struct{ eventType, name, index string }{
+		{"SNMPInterfaceSample", "ifTable", "10"},
+		{"SNMPSystemSample", "system", ""},
+		{"SNMPInterfaceSample", "ifTable", "2"},
+		{"SNMPInterfaceSample", "ifTable", "1"},
+	} {
+		ms := entity.NewMetricSet(row.eventType)
+		ms.SetMetric("name", row.name, metric.ATTRIBUTE)
+		if row.index != "" {
+			ms.SetMetric("index", row.index, metric.ATTRIBUTE)
+		}
+	}
+	groupMetricSets(i)
+	var order []string
+	for _, ms := range entity.Metrics {
+		order = append(order, fmt.Sprint(ms.Metrics["event_type"], "/", ms.Metrics["index"]))
+	}
+	expected := "SNMPInterfaceSample/1 SNMPInterfaceSample/2 SNMPInterfaceSample/10 SNMPSystemSample/<nil>"
+	if strings.Join(order, " ") != expected {
+		t.Errorf("unexpected order %v", order)
+	}
+}
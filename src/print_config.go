@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// redactedArguments are the arguments holding credentials, never printed
+var redactedArguments = map[string]bool{
+	"community":       true,
+	"auth_passphrase": true,
+	"priv_passphrase": true,
+	"obfuscation_key": true,
+}
+
+// mergedKeys are the keys of a collection file already merged into its
+// devices, or substituted, once it is loaded
+var mergedKeys = map[string]bool{
+	"variables":     true,
+	"defaults":      true,
+	"metric_groups": true,
+	"include":       true,
+	"extends":       true,
+	"metric_sets":   true,
+	"inventory":     true,
+}
+
+// effectiveArguments are the values of every argument, from the command
+// line, the environment or their defaults, with the credentials redacted
+func effectiveArguments() yaml.MapSlice {
+	var arguments yaml.MapSlice
+	flag.VisitAll(func(f *flag.Flag) {
+		var value interface{} = f.Value.String()
+		if getter, ok := f.Value.(flag.Getter); ok {
+			value = getter.Get()
+		}
+		if redactedArguments[f.Name] && f.Value.String() != "" {
+			value = "<redacted>"
+		}
+		arguments = append(arguments, yaml.MapItem{Key: f.Name, Value: value})
+	})
+	sort.SliceStable(arguments, func(i, j int) bool {
+		return arguments[i].Key.(string) < arguments[j].Key.(string)
+	})
+	return arguments
+}
+
+// effectiveCollection is a collection file as the integration reads it: its
+// variables substituted and the libraries it includes and the profiles it
+// extends merged into its devices, without the keys left empty
+func effectiveCollection(file string) (yaml.MapSlice, error) {
+	parser, err := loadCollectionFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parseCollection(parser); err != nil {
+		return nil, err
+	}
+	out, err := yaml.Marshal(parser)
+	if err != nil {
+		return nil, err
+	}
+	var collection yaml.MapSlice
+	if err := yaml.Unmarshal(out, &collection); err != nil {
+		return nil, err
+	}
+	effective := yaml.MapSlice{{Key: "file", Value: file}}
+	for _, item := range collection {
+		if mergedKeys[fmt.Sprint(item.Key)] {
+			continue
+		}
+		if value, ok := pruneEmpty(item.Value); ok {
+			effective = append(effective, yaml.MapItem{Key: item.Key, Value: value})
+		}
+	}
+	return effective, nil
+}
+
+// pruneEmpty removes the empty values, the zero values of the unset keys of
+// the collection format, from a decoded YAML value. It reports whether
+// anything is left
+func pruneEmpty(value interface{}) (interface{}, bool) {
+	switch value := value.(type) {
+	case nil:
+		return nil, false
+	case yaml.MapSlice:
+		var pruned yaml.MapSlice
+		for _, item := range value {
+			//enabled: false is set, not empty
+			if item.Key == "enabled" && item.Value == false {
+				pruned = append(pruned, item)
+				continue
+			}
+			if element, ok := pruneEmpty(item.Value); ok {
+				pruned = append(pruned, yaml.MapItem{Key: item.Key, Value: element})
+			}
+		}
+		return pruned, len(pruned) > 0
+	case []interface{}:
+		var pruned []interface{}
+		for _, element := range value {
+			if element, ok := pruneEmpty(element); ok {
+				pruned = append(pruned, element)
+			}
+		}
+		return pruned, len(pruned) > 0
+	case string:
+		return value, value != ""
+	case bool:
+		return value, value
+	case int:
+		return value, value != 0
+	case float64:
+		return value, value != 0
+	}
+	return value, true
+}
+
+// effectiveConfiguration prints the arguments and every collection file and
+// profile they configure, as the integration reads them
+func effectiveConfiguration(arguments yaml.MapSlice) (string, error) {
+	if err := loadMibDirs(); err != nil {
+		return "", fmt.Errorf("failed to load MIB files: %v", err)
+	}
+	files, err := configuredCollectionFiles()
+	if err != nil {
+		return "", err
+	}
+	var collections []yaml.MapSlice
+	for _, file := range files {
+		collection, err := effectiveCollection(strings.TrimSpace(file))
+		if err != nil {
+			return "", fmt.Errorf("failed to read collection definition file %s: %v", file, err)
+		}
+		collections = append(collections, collection)
+	}
+	out, err := yaml.Marshal(yaml.MapSlice{
+		{Key: "arguments", Value: arguments},
+		{Key: "collections", Value: collections},
+	})
+	if err != nil {
+		return "", err
+	}
+	config := string(out)
+	for _, name := range strings.Split(args.Profiles, ",") {
+		if strings.TrimSpace(name) == autoProfile {
+			config += "# the profile selected by profiles: auto depends on the target and is not printed\n"
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEffectiveConfiguration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "print-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { args.CollectionFiles, args.ProfileDirs, args.Profiles = "", "", "" }()
+	file := filepath.Join(dir, "router.yml")
+	collection := `variables: {SITE: paris}
+include: [shared.yml]
+collect:
+- device: router-${SITE}
+`
+	shared := `metric_sets:
+- {name: system, type: scalar, event_type: SNMPSample, metrics: [{metric_name: sysName, oid: .1.3.6.1.2.1.1.5.0}]}
+`
+	if err := ioutil.WriteFile(file, []byte(collection), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "shared.yml"), []byte(shared), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args.CollectionFiles, args.ProfileDirs, args.Profiles = file, "../profiles", "auto"
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("community", "private", "")
+	fs.Int("snmp_port", 161, "")
+	flag.CommandLine, fs = fs, flag.CommandLine
+	defer func() { flag.CommandLine = fs }()
+
+	config, err := effectiveConfiguration(effectiveArguments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"community: <redacted>",
+		"snmp_port: 161",
+		"- file: " + file,
+		"device: router-paris",
+		"metric_name: sysName",
+		"not printed",
+	} {
+		if !strings.Contains(config, expected) {
+			t.Errorf("expected %q in %s", expected, config)
+		}
+	}
+	for _, unexpected := range []string{"private", "include", "variables", "null_policy", "metric_type"} {
+		if strings.Contains(config, unexpected) {
+			t.Errorf("unexpected %q in %s", unexpected, config)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/soniah/gosnmp"
+)
+
+// checkedOid is an OID a profile reads, with where it is used in the profile
+type checkedOid struct {
+	oid         string
+	usedBy      string
+	fallbackOid string
+}
+
+// oidExists reports whether a target has a value for an OID or under it
+type oidExists func(oid string) bool
+
+// checkProfiles checks that every OID read by a comma separated list of
+// profiles, by name or absolute path, exists on a target. It returns the
+// report, listing the missing OIDs of each profile, and the number of
+// missing OIDs
+func checkProfiles(names string, exists oidExists) (string, int, error) {
+	var report strings.Builder
+	missing := 0
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		file := name
+		if !filepath.IsAbs(name) {
+			var err error
+			if file, err = profilePath(name); err != nil {
+				return "", 0, err
+			}
+		}
+		oids, err := profileOids(file)
+		if err != nil {
+			return "", 0, fmt.Errorf("%s: %v", file, err)
+		}
+		found := 0
+		var lines []string
+		for _, checked := range oids {
+			if exists(checked.oid) {
+				found++
+				continue
+			}
+			line := fmt.Sprintf("  missing %s %s", checked.usedBy, checked.oid)
+			if objectName := mibs.translate(checked.oid); objectName != checked.oid {
+				line += " (" + objectName + ")"
+			}
+			if checked.fallbackOid != "" && exists(checked.fallbackOid) {
+				line += ", its fallback_oid " + checked.fallbackOid + " exists"
+			}
+			lines = append(lines, line)
+		}
+		missing += len(oids) - found
+		fmt.Fprintf(&report, "%s: %d of %d OIDs found\n", name, found, len(oids))
+		for _, line := range lines {
+			fmt.Fprintln(&report, line)
+		}
+	}
+	return report.String(), missing, nil
+}
+
+// profileOids returns the OIDs of the metrics, indexes and inventory of a
+// profile and of the profiles it extends, each once
+func profileOids(file string) ([]checkedOid, error) {
+	parser, err := loadCollectionFile(file)
+	if err != nil {
+		return nil, err
+	}
+	collections, err := parseCollection(parser)
+	if err != nil {
+		return nil, err
+	}
+	var oids []checkedOid
+	seen := make(map[string]bool)
+	add := func(oid string, usedBy string, fallbackOid string) {
+		if oid = normalizeOid(oid); oid != "." && !seen[oid] {
+			seen[oid] = true
+			oids = append(oids, checkedOid{oid: oid, usedBy: usedBy, fallbackOid: fallbackOid})
+		}
+	}
+	for _, collection := range collections {
+		for _, metricSet := range collection.MetricSets {
+			for _, index := range metricSet.Index {
+				add(index.oid, metricSet.Name+"/"+index.name, "")
+			}
+			for _, metric := range metricSet.Metrics {
+				name := metric.metricName
+				if name == "" {
+					name = mibs.objectName(metric.oid)
+				}
+				add(metric.oid, metricSet.Name+"/"+name, metric.fallbackOid)
+			}
+			if metricSet.CollectAllColumns {
+				add(metricSet.RootOid, metricSet.Name, "")
+			}
+		}
+		for _, item := range collection.Inventory {
+			add(item.oid, "inventory/"+item.category+"/"+item.name, "")
+		}
+	}
+	return oids, nil
+}
+
+// walkOidExists checks OIDs against the variables of an snmpwalk output file
+func walkOidExists(path string) (oidExists, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	variables, err := parseWalk(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(variables) == 0 {
+		return nil, fmt.Errorf("no variables found in %s", path)
+	}
+	//every prefix of a walked OID exists, as a column or a subtree of the dump
+	prefixes := make(map[string]bool)
+	for _, variable := range variables {
+		oid := ""
+		for _, arc := range variable.arcs {
+			oid += "." + arc
+			prefixes[oid] = true
+		}
+	}
+	return func(oid string) bool {
+		return prefixes[normalizeOid(oid)]
+	}, nil
+}
+
+// deviceOidExists checks OIDs against the connected target: an OID exists
+// when it has a value or when the next OID is below it
+func deviceOidExists(oid string) bool {
+	if _, ok := getScalar(oid); ok {
+		return true
+	}
+	result, err := theSNMP.GetNext([]string{oid})
+	if err != nil || result.Error != gosnmp.NoError || len(result.Variables) == 0 {
+		return false
+	}
+	next := result.Variables[0]
+	return next.Type != gosnmp.EndOfMibView && !isNullPDU(next) && oidHasPrefix(next.Name, oid)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckProfiles(t *testing.T) {
+	args.ProfileDirs = "../profiles"
+	defer func() { args.ProfileDirs = "" }()
+	walk := filepath.Join(os.TempDir(), "check-profile.walk")
+	dump := `.1.3.6.1.2.1.1.1.0 = STRING: "Linux router"
+.1.3.6.1.2.1.1.2.0 = OID: .1.3.6.1.4.1.8072.3.2.10
+.1.3.6.1.2.1.1.5.0 = STRING: "router"
+`
+	if err := ioutil.WriteFile(walk, []byte(dump), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(walk)
+	exists, err := walkOidExists(walk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, missing, err := checkProfiles("generic-system", exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing != 1 || !strings.Contains(report, "generic-system: 3 of 4 OIDs found") || !strings.Contains(report, "missing system/sysUpTime .1.3.6.1.2.1.1.3.0") {
+		t.Errorf("unexpected report %q with %d missing", report, missing)
+	}
+	if _, _, err := checkProfiles("no-such-profile", exists); err == nil {
+		t.Error("expected error checking an unknown profile")
+	}
+}
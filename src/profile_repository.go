@@ -0,0 +1,193 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/log"
+	"github.com/newrelic/infra-integrations-sdk/persist"
+)
+
+const (
+	// maxProfileArchiveSize bounds the download of a profile repository
+	maxProfileArchiveSize = 64 << 20
+	// profileFetchTimeout bounds the download so an unreachable repository does not stall the run
+	profileFetchTimeout = 30 * time.Second
+	// profileChecksumFile records the checksum of the archive a cached repository was extracted from
+	profileChecksumFile = ".sha256"
+)
+
+// repositoryProfileDir holds the profiles of the profile_repository, searched
+// after the profile_dirs and before the bundled profiles. It is empty when no
+// repository is configured or none of its versions could be fetched
+var repositoryProfileDir string
+
+// syncProfileRepository makes the profiles of the profile_repository
+// available. The archive is fetched again once the cache is older than
+// profile_repository_refresh, unless it is pinned by profile_repository_sha256,
+// and the cached profiles keep being used while the repository is unreachable
+func syncProfileRepository() error {
+	url := strings.TrimSpace(args.ProfileRepository)
+	if url == "" {
+		return nil
+	}
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "file://") {
+		return fmt.Errorf("profile_repository %s is neither an https:// nor a file:// URL", url)
+	}
+	pinned := strings.ToLower(strings.TrimSpace(args.ProfileRepositorySha256))
+	dir := profileRepositoryCacheDir(url)
+
+	cachedChecksum, cachedAt, cached := readProfileChecksum(dir)
+	fresh := cached && time.Since(cachedAt) < profileRepositoryRefresh
+	switch {
+	case cached && pinned != "" && cachedChecksum == pinned:
+		repositoryProfileDir = dir
+		return nil
+	case cached && pinned == "" && fresh:
+		repositoryProfileDir = dir
+		return nil
+	}
+
+	err := fetchProfileRepository(url, pinned, dir)
+	if err == nil {
+		repositoryProfileDir = dir
+		return nil
+	}
+	if cached && (pinned == "" || cachedChecksum == pinned) {
+		log.Warn("unable to refresh the profile repository, using the profiles fetched %s: %v", cachedAt.Format(time.RFC3339), err)
+		repositoryProfileDir = dir
+		return nil
+	}
+	return err
+}
+
+// profileRepositoryCacheDir is where the profiles of a repository are extracted
+func profileRepositoryCacheDir(url string) string {
+	hash := sha256.Sum256([]byte(url))
+	return filepath.Join(filepath.Dir(persist.DefaultPath(integrationName)), integrationName+".profiles", hex.EncodeToString(hash[:8]))
+}
+
+// readProfileChecksum returns the checksum of the archive a cached repository
+// was extracted from and when it was fetched
+func readProfileChecksum(dir string) (string, time.Time, bool) {
+	file := filepath.Join(dir, profileChecksumFile)
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	checksum, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return strings.TrimSpace(string(checksum)), info.ModTime(), true
+}
+
+// fetchProfileRepository downloads the archive of a repository, verifies its
+// checksum when pinned and replaces the cached profiles with its content
+func fetchProfileRepository(url string, pinned string, dir string) error {
+	archive, err := downloadProfileArchive(url)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(archive)
+	checksum := hex.EncodeToString(hash[:])
+	if pinned != "" && checksum != pinned {
+		return fmt.Errorf("profile repository %s has checksum %s, expected %s", url, checksum, pinned)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	staging, err := ioutil.TempDir(filepath.Dir(dir), filepath.Base(dir)+".")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+	count, err := extractProfiles(bytes.NewReader(archive), staging)
+	if err != nil {
+		return fmt.Errorf("profile repository %s: %v", url, err)
+	}
+	if count == 0 {
+		return fmt.Errorf("profile repository %s contains no profiles", url)
+	}
+	if err := ioutil.WriteFile(filepath.Join(staging, profileChecksumFile), []byte(checksum+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		return err
+	}
+	log.Debug("fetched %d profiles from %s", count, url)
+	return nil
+}
+
+func downloadProfileArchive(url string) ([]byte, error) {
+	if strings.HasPrefix(url, "file://") {
+		return ioutil.ReadFile(strings.TrimPrefix(url, "file://"))
+	}
+	client := &http.Client{Timeout: profileFetchTimeout}
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch profile repository %s: %s", url, response.Status)
+	}
+	archive, err := ioutil.ReadAll(io.LimitReader(response.Body, maxProfileArchiveSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(archive) > maxProfileArchiveSize {
+		return nil, fmt.Errorf("profile repository %s is larger than %d bytes", url, maxProfileArchiveSize)
+	}
+	return archive, nil
+}
+
+// extractProfiles writes the `.yml` files found anywhere in a gzipped tarball
+// to a directory, by file name, and returns how many were written
+func extractProfiles(r io.Reader, dir string) (int, error) {
+	compressed, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer compressed.Close()
+	archive := tar.NewReader(compressed)
+	count := 0
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		//only the base name is kept, which also keeps entries from escaping the directory
+		name := path.Base(header.Name)
+		if header.Typeflag != tar.TypeReg || path.Ext(name) != ".yml" || strings.HasPrefix(name, ".") {
+			continue
+		}
+		content, err := ioutil.ReadAll(archive)
+		if err != nil {
+			return count, err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfileRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repository")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var archive bytes.Buffer
+	compressed := gzip.NewWriter(&archive)
+	tarball := tar.NewWriter(compressed)
+	profile := []byte("sys_object_ids: [.1.3.6.1.4.1.2021]\nextends: [generic-system]\n")
+	for _, name := range []string{"profiles-main/vendors/net-snmp.yml", "profiles-main/README.md"} {
+		tarball.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(profile)), Typeflag: tar.TypeReg})
+		tarball.Write(profile)
+	}
+	tarball.Close()
+	compressed.Close()
+	archivePath := filepath.Join(dir, "profiles.tar.gz")
+	if err := ioutil.WriteFile(archivePath, archive.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checksum := sha256.Sum256(archive.Bytes())
+
+	args.ProfileRepository = "file://" + archivePath
+	defer func() { args.ProfileRepository, args.ProfileRepositorySha256, repositoryProfileDir = "", "", "" }()
+	defer os.RemoveAll(profileRepositoryCacheDir(args.ProfileRepository))
+	args.ProfileRepositorySha256 = strings.Repeat("0", 64)
+	if err := syncProfileRepository(); err == nil {
+		t.Error("expected a checksum mismatch")
+	}
+	args.ProfileRepositorySha256 = hex.EncodeToString(checksum[:])
+	if err := syncProfileRepository(); err != nil {
+		t.Fatal(err)
+	}
+	file, err := profilePath("net-snmp")
+	if err != nil || filepath.Dir(file) != repositoryProfileDir {
+		t.Errorf("unexpected profile path %s, %v", file, err)
+	}
+	if _, err := os.Stat(filepath.Join(repositoryProfileDir, "README.md")); err == nil {
+		t.Error("only profiles should be extracted")
+	}
+
+	//the pinned cache is used without reading the archive again
+	os.Remove(archivePath)
+	repositoryProfileDir = ""
+	if err := syncProfileRepository(); err != nil || repositoryProfileDir == "" {
+		t.Errorf("pinned profiles should be served from the cache: %v", err)
+	}
+}
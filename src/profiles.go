@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/log"
+)
+
+// bundledProfileDir is where the packages install the bundled device profiles
+const bundledProfileDir = "/var/db/newrelic-infra/newrelic-integrations/snmp-profiles"
+
+const (
+	sysObjectIdOid = ".1.3.6.1.2.1.1.2.0"
+	sysDescrOid    = ".1.3.6.1.2.1.1.1.0"
+	// autoProfile selects the profile matching the sysObjectID of the device
+	autoProfile = "auto"
+	// genericProfile is selected when no profile matches the sysObjectID of the device
+	genericProfile = "generic"
+)
+
+// profileCandidate is a profile that can be selected by the sysObjectID and
+// the sysDescr of a device
+type profileCandidate struct {
+	file         string
+	sysObjectIds []string
+	sysDescrs    []*regexp.Regexp
+}
+
+// profileFiles returns the collection files of the profiles selected by name.
+// A profile is looked up as `<name>.yml` in the profile_dirs first, so users
+// can override the bundled profiles, then among the bundled profiles
+func profileFiles(names string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var file string
+		var err error
+		if name == autoProfile {
+			file, err = selectProfile()
+		} else {
+			file, err = profilePath(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		//a profile both enabled and selected, or selected twice, is collected once
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// enabledProfiles adds the bundled profiles of the standard MIBs enabled by
+// the enable_*_mib arguments to a comma separated list of profiles
+func enabledProfiles(names string) string {
+	enabled := []struct {
+		flag    bool
+		profile string
+	}{
+		{args.EnableIfMib, "generic-if"},
+		{args.EnableHostResourcesMib, "generic-host-resources"},
+		{args.EnableEntitySensorMib, "generic-entity-sensor"},
+		{args.EnableUpsMib, "generic-ups"},
+		{args.EnablePrinterMib, "generic-printer"},
+	}
+	for _, mib := range enabled {
+		if !mib.flag {
+			continue
+		}
+		if names != "" {
+			names += ","
+		}
+		names += mib.profile
+	}
+	return names
+}
+
+// profilePath finds the collection file of a named profile
+func profilePath(name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid profile name %s", name)
+	}
+	for _, dir := range profileDirs() {
+		file := filepath.Join(dir, name+".yml")
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("profile %s not found in %s", name, strings.Join(profileDirs(), ", "))
+}
+
+// profileDirs are the directories profiles are looked up in, in order
+func profileDirs() []string {
+	var dirs []string
+	for _, dir := range strings.Split(args.ProfileDirs, ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	if repositoryProfileDir != "" {
+		dirs = append(dirs, repositoryProfileDir)
+	}
+	return append(dirs, bundledProfileDir)
+}
+
+// selectProfile finds the profile whose sys_object_ids has the longest prefix
+// of the sysObjectID of the device, and whose sys_descr matches its sysDescr,
+// falling back to the generic profile. The sysObjectID and the sysDescr are
+// read on first contact and kept in the state store
+func selectProfile() (string, error) {
+	candidates := loadProfileCandidates()
+	sysObjectId := cachedSystemValue("sysObjectID", sysObjectIdOid)
+	sysDescr := ""
+	for _, candidate := range candidates {
+		if len(candidate.sysDescrs) > 0 {
+			sysDescr = cachedSystemValue("sysDescr", sysDescrOid)
+			break
+		}
+	}
+	if sysObjectId != "" || sysDescr != "" {
+		if file, ok := matchProfile(sysObjectId, sysDescr, candidates); ok {
+			log.Debug("selected profile %s for sysObjectID %s of %s", file, sysObjectId, targetHost)
+			return file, nil
+		}
+	}
+	log.Debug("no profile matches the sysObjectID %s of %s, using the %s profile", sysObjectId, targetHost, genericProfile)
+	return profilePath(genericProfile)
+}
+
+// cachedSystemValue reads a system group scalar of the device, or the value
+// kept in the state store since it was first read
+func cachedSystemValue(name string, oid string) string {
+	key := fmt.Sprintf("%s:%s:%d", name, targetHost, targetPort)
+	var value string
+	if _, ok := getState(key, &value); ok {
+		return value
+	}
+	pdu, ok := getScalar(oid)
+	if !ok {
+		return ""
+	}
+	switch v := pdu.Value.(type) {
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	}
+	setState(key, value)
+	return value
+}
+
+// loadProfileCandidates reads the sys_object_ids and sys_descr of the
+// profiles in the profile_dirs and of the bundled profiles, in lookup order
+func loadProfileCandidates() []profileCandidate {
+	var candidates []profileCandidate
+	for _, dir := range profileDirs() {
+		files, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+		if err != nil {
+			continue
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			parser, err := parseYaml(file)
+			if err != nil {
+				log.Warn("skipping profile %s: %v", file, err)
+				continue
+			}
+			if len(parser.SysObjectIds) == 0 && len(parser.SysDescrs) == 0 {
+				continue
+			}
+			candidate := profileCandidate{file: file, sysObjectIds: parser.SysObjectIds}
+			for _, pattern := range parser.SysDescrs {
+				sysDescr, err := regexp.Compile(pattern)
+				if err != nil {
+					log.Warn("invalid sys_descr %s in profile %s: %v", pattern, file, err)
+					continue
+				}
+				candidate.sysDescrs = append(candidate.sysDescrs, sysDescr)
+			}
+			if len(candidate.sysDescrs) < len(parser.SysDescrs) {
+				continue
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+// matchProfile returns the candidate with the longest sysObjectID prefix
+// matching the given sysObjectID. Candidates with a sys_descr are only
+// selected when one of its expressions matches the sysDescr, and win over
+// the candidates without one for the same prefix. The first candidate wins a
+// tie, so the profile_dirs take precedence over the bundled profiles
+func matchProfile(sysObjectId string, sysDescr string, candidates []profileCandidate) (string, bool) {
+	var best string
+	bestLength, bestDescr := -1, false
+	for _, candidate := range candidates {
+		length := -1
+		if len(candidate.sysObjectIds) == 0 {
+			length = 0
+		}
+		for _, prefix := range candidate.sysObjectIds {
+			if arcs := len(oidArcs(prefix)); arcs > length && oidHasPrefix(sysObjectId, prefix) {
+				length = arcs
+			}
+		}
+		if length < 0 {
+			continue
+		}
+		matchesDescr := false
+		for _, pattern := range candidate.sysDescrs {
+			if pattern.MatchString(sysDescr) {
+				matchesDescr = true
+				break
+			}
+		}
+		if len(candidate.sysDescrs) > 0 && !matchesDescr {
+			continue
+		}
+		if length > bestLength || length == bestLength && matchesDescr && !bestDescr {
+			best, bestLength, bestDescr = candidate.file, length, matchesDescr
+		}
+	}
+	return best, best != ""
+}
+
+// loadCollectionFile parses a collection file and merges in the profiles it extends
+func loadCollectionFile(file string) (*collectionParser, error) {
+	return loadExtendedCollection(file, nil)
+}
+
+// loadExtendedCollection parses a collection file extending other profiles.
+// The metric sets and inventory of the extended profiles are collected for
+// every device of the file, before its own, except for the metric sets the
+// file redefines by name. Type conversions are inherited unless overridden
+func loadExtendedCollection(file string, extendedBy []string) (*collectionParser, error) {
+	for _, extending := range extendedBy {
+		if extending == file {
+			return nil, fmt.Errorf("profile %s extends itself through %s", file, strings.Join(extendedBy, ", "))
+		}
+	}
+	parser, err := parseYaml(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := mergeIncludes(file, parser, nil); err != nil {
+		return nil, err
+	}
+	if err := useMetricGroups(file, parser); err != nil {
+		return nil, err
+	}
+	if len(parser.Collect) == 0 && len(parser.Extends) > 0 {
+		parser.Collect = []deviceParser{{Device: strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))}}
+	}
+	shareMetricSets(parser)
+	if len(parser.Extends) == 0 {
+		return parser, nil
+	}
+
+	var inheritedSets []metricSetParser
+	var inheritedInventory []inventoryParser
+	for _, name := range parser.Extends {
+		name = strings.TrimSpace(name)
+		baseFile := name
+		if !filepath.IsAbs(name) {
+			if baseFile, err = profilePath(name); err != nil {
+				return nil, fmt.Errorf("%s extends an unknown profile: %v", file, err)
+			}
+		}
+		base, err := loadExtendedCollection(baseFile, append(extendedBy, file))
+		if err != nil {
+			return nil, err
+		}
+		for _, device := range base.Collect {
+			inheritedSets = append(inheritedSets, device.MetricSets...)
+			inheritedInventory = append(inheritedInventory, device.Inventory...)
+		}
+		if len(base.Collect) == 0 {
+			inheritedSets = append(inheritedSets, base.MetricSets...)
+			inheritedInventory = append(inheritedInventory, base.Inventory...)
+		}
+		for pduType, metricType := range base.TypeConversions {
+			if _, ok := parser.TypeConversions[pduType]; ok {
+				continue
+			}
+			if parser.TypeConversions == nil {
+				parser.TypeConversions = make(map[string]string)
+			}
+			parser.TypeConversions[pduType] = metricType
+		}
+	}
+
+	for i := range parser.Collect {
+		device := &parser.Collect[i]
+		redefined := make(map[string]bool)
+		for _, metricSet := range device.MetricSets {
+			redefined[metricSet.Name] = true
+		}
+		var metricSets []metricSetParser
+		for _, metricSet := range inheritedSets {
+			if !redefined[metricSet.Name] {
+				metricSets = append(metricSets, metricSet)
+			}
+		}
+		device.MetricSets = append(metricSets, device.MetricSets...)
+		device.Inventory = append(append([]inventoryParser(nil), inheritedInventory...), device.Inventory...)
+	}
+	return parser, nil
+}
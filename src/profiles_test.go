@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestBundledProfiles(t *testing.T) {
+	args.ProfileDirs = "../profiles"
+	defer func() { args.ProfileDirs = "" }()
+	files, err := filepath.Glob("../profiles/*.yml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no bundled profiles found: %v", err)
+	}
+	for _, file := range files {
+		parser, err := loadCollectionFile(file)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if _, err := parseCollection(parser); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestProfilePath(t *testing.T) {
+	args.ProfileDirs = "../profiles"
+	defer func() { args.ProfileDirs = "" }()
+	if file, err := profilePath("cisco-ios"); err != nil || file != "../profiles/cisco-ios.yml" {
+		t.Errorf("unexpected profile path %s, %v", file, err)
+	}
+	for _, name := range []string{"unknown-vendor", "../profiles/apc"} {
+		if _, err := profilePath(name); err == nil {
+			t.Errorf("expected error for profile %s", name)
+		}
+	}
+}
+
+func TestMatchProfile(t *testing.T) {
+	candidates := []profileCandidate{
+		{file: "user/cisco.yml", sysObjectIds: []string{".1.3.6.1.4.1.9"}},
+		{file: "cisco-ios.yml", sysObjectIds: []string{".1.3.6.1.4.1.9.1"}},
+		{file: "cisco-nxos.yml", sysObjectIds: []string{".1.3.6.1.4.1.9.12.3.1.3"}},
+		{file: "bundled/cisco.yml", sysObjectIds: []string{".1.3.6.1.4.1.9"}},
+	}
+	cases := map[string]string{
+		".1.3.6.1.4.1.9.1.1208":     "cisco-ios.yml",
+		".1.3.6.1.4.1.9.12.3.1.3.1": "cisco-nxos.yml",
+		".1.3.6.1.4.1.9.10.1":       "user/cisco.yml",
+		".1.3.6.1.4.1.99":           "",
+	}
+	for sysObjectId, expected := range cases {
+		if file, _ := matchProfile(sysObjectId, "", candidates); file != expected {
+			t.Errorf("sysObjectID %s matched %s, expected %s", sysObjectId, file, expected)
+		}
+	}
+
+	candidates = []profileCandidate{
+		{file: "whitebox.yml", sysObjectIds: []string{".1.3.6.1.4.1.8072.3.2.10"}},
+		{file: "cumulus.yml", sysObjectIds: []string{".1.3.6.1.4.1.8072.3.2.10"}, sysDescrs: []*regexp.Regexp{regexp.MustCompile(`Cumulus Linux [34]\.`)}},
+		{file: "sonic.yml", sysDescrs: []*regexp.Regexp{regexp.MustCompile(`^SONiC`)}},
+	}
+	descrCases := map[string]string{
+		"Cumulus Linux 4.2.1":            "cumulus.yml",
+		"Cumulus Linux 5.0":              "whitebox.yml",
+		"Linux spine01 4.19.0 x86_64":    "whitebox.yml",
+		"SONiC Software Version: 202012": "whitebox.yml",
+	}
+	for sysDescr, expected := range descrCases {
+		if file, _ := matchProfile(".1.3.6.1.4.1.8072.3.2.10", sysDescr, candidates); file != expected {
+			t.Errorf("sysDescr %s matched %s, expected %s", sysDescr, file, expected)
+		}
+	}
+	if file, _ := matchProfile(".1.3.6.1.4.1.99", "SONiC Software Version: 202012", candidates); file != "sonic.yml" {
+		t.Errorf("sysDescr alone matched %s, expected sonic.yml", file)
+	}
+}
+
+func TestExtendedProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	args.ProfileDirs = dir
+	defer func() { args.ProfileDirs = "" }()
+	profiles := map[string]string{
+		"base": `collect:
+- device: base
+  metric_sets:
+  - {name: system, type: scalar, event_type: SNMPSample, metrics: [{metric_name: sysName, oid: .1.3.6.1.2.1.1.5.0}]}
+  - {name: uptime, type: scalar, event_type: SNMPSample, metrics: [{metric_name: sysUpTime, oid: .1.3.6.1.2.1.1.3.0}]}
+`,
+		"vendor": `extends: [base]
+collect:
+- device: vendor
+  metric_sets:
+  - {name: system, type: scalar, event_type: SNMPSample, metrics: [{metric_name: sysDescr, oid: .1.3.6.1.2.1.1.1.0}]}
+`,
+		"loop-a": "extends: [loop-b]\n",
+		"loop-b": "extends: [loop-a]\n",
+	}
+	for name, profile := range profiles {
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".yml"), []byte(profile), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parser, err := loadCollectionFile(filepath.Join(dir, "vendor.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	metricSets := parser.Collect[0].MetricSets
+	if len(metricSets) != 2 || metricSets[0].Name != "uptime" || metricSets[1].Metrics[0].MetricName != "sysDescr" {
+		t.Errorf("unexpected metric sets %+v", metricSets)
+	}
+	if _, err := loadCollectionFile(filepath.Join(dir, "loop-a.yml")); err == nil {
+		t.Error("expected error for a profile extending itself")
+	}
+}
+
+func TestEnabledProfiles(t *testing.T) {
+	args.ProfileDirs = "../profiles"
+	args.EnableIfMib, args.EnableUpsMib = true, true
+	defer func() { args.ProfileDirs, args.EnableIfMib, args.EnableUpsMib = "", false, false }()
+	names := enabledProfiles("apc,generic-if")
+	if names != "apc,generic-if,generic-if,generic-ups" {
+		t.Fatalf("unexpected profiles %s", names)
+	}
+	files, err := profileFiles(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 || files[1] != "../profiles/generic-if.yml" || files[2] != "../profiles/generic-ups.yml" {
+		t.Errorf("unexpected profile files %v", files)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/log"
+)
+
+// reverseLookupTimeout bounds each reverse DNS lookup so that an unresponsive
+// resolver can't stall the collection
+const reverseLookupTimeout = 2 * time.Second
+
+var (
+	// reverseNames caches the reverse DNS lookups of a run, as the same address
+	// is often found in many rows
+	reverseNames     = make(map[string]string)
+	reverseNamesLock sync.Mutex
+)
+
+// reverseLookup returns the host name of an address, or "" if it has none
+func reverseLookup(address string) string {
+	reverseNamesLock.Lock()
+	name, ok := reverseNames[address]
+	reverseNamesLock.Unlock()
+	if ok {
+		return name
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reverseLookupTimeout)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, address)
+	if err != nil {
+		log.Debug("reverse lookup of %s failed: %v", address, err)
+	} else if len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+
+	reverseNamesLock.Lock()
+	reverseNames[address] = name
+	reverseNamesLock.Unlock()
+	return name
+}
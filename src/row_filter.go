@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rowFilter selects table rows by their index key. An index key is kept
+// when it equals one of the explicit keys or, for single arc integer keys,
+// falls within one of the configured ranges
+type rowFilter struct {
+	keys   map[string]bool
+	ranges []indexRange
+}
+
+// indexRange is an inclusive range of integer index keys
+type indexRange struct {
+	from int64
+	to   int64
+}
+
+// parseRowFilter builds a rowFilter from the `indexes` list of a metric set.
+// Entries are either explicit index keys (`3`, `1.4`) or integer ranges (`10-20`)
+func parseRowFilter(entries []string) (*rowFilter, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	filter := &rowFilter{keys: make(map[string]bool)}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		bounds := strings.SplitN(entry, "-", 2)
+		if len(bounds) == 1 {
+			filter.keys[strings.TrimPrefix(entry, ".")] = true
+			continue
+		}
+		from, err := strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index range %s: %v", entry, err)
+		}
+		to, err := strconv.ParseInt(strings.TrimSpace(bounds[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index range %s: %v", entry, err)
+		}
+		if from > to {
+			return nil, fmt.Errorf("invalid index range %s: lower bound is greater than upper bound", entry)
+		}
+		filter.ranges = append(filter.ranges, indexRange{from: from, to: to})
+	}
+	return filter, nil
+}
+
+// matches reports whether the row identified by indexKey should be collected.
+// A nil filter matches every row
+func (f *rowFilter) matches(indexKey string) bool {
+	if f == nil {
+		return true
+	}
+	if f.keys[indexKey] {
+		return true
+	}
+	if len(f.ranges) == 0 {
+		return false
+	}
+	key, err := strconv.ParseInt(indexKey, 10, 64)
+	if err != nil {
+		return false
+	}
+	for _, r := range f.ranges {
+		if key >= r.from && key <= r.to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestRowFilter(t *testing.T) {
+	var parsed metricSetParser
+	if err := yaml.Unmarshal([]byte("indexes: [1, 2, 10-20, \"4.7\"]"), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	filter, err := parseRowFilter(parsed.Indexes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"1":   true,
+		"2":   true,
+		"3":   false,
+		"10":  true,
+		"15":  true,
+		"20":  true,
+		"21":  false,
+		"4.7": true,
+		"4.8": false,
+	}
+	for key, expected := range cases {
+		if filter.matches(key) != expected {
+			t.Errorf("matches(%s) = %v, expected %v", key, !expected, expected)
+		}
+	}
+}
+
+func TestRowFilterInvalidRange(t *testing.T) {
+	if _, err := parseRowFilter([]string{"20-10"}); err == nil {
+		t.Error("expected error for inverted range")
+	}
+	if _, err := parseRowFilter([]string{"a-b"}); err == nil {
+		t.Error("expected error for non numeric range")
+	}
+}
+
+func TestNilRowFilterMatchesAll(t *testing.T) {
+	var filter *rowFilter
+	if !filter.matches("42") {
+		t.Error("nil filter should match every row")
+	}
+}
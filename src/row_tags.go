@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// rowTags holds static attributes merged into the rows of a table, keyed by
+// the index key of the row or, when key is set, by the value of that index
+type rowTags struct {
+	key  string
+	tags map[string]map[string]string
+}
+
+// parseRowTags builds the row tags of a metric set from its inline `row_tags`
+// and its `row_tags_file`. Inline tags take precedence over the file
+func parseRowTags(p metricSetParser) (*rowTags, error) {
+	if len(p.RowTags) == 0 && strings.TrimSpace(p.RowTagsFile) == "" {
+		return nil, nil
+	}
+	tags := make(map[string]map[string]string)
+	if file := strings.TrimSpace(p.RowTagsFile); file != "" {
+		if !filepath.IsAbs(file) {
+			return nil, fmt.Errorf("row_tags_file %s must be an absolute path", file)
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var fileTags map[string]map[string]string
+		if err := yaml.Unmarshal(content, &fileTags); err != nil {
+			return nil, fmt.Errorf("unable to parse row_tags_file %s: %v", file, err)
+		}
+		mergeRowTags(tags, fileTags)
+	}
+	mergeRowTags(tags, p.RowTags)
+	return &rowTags{key: strings.TrimSpace(p.RowTagsKey), tags: tags}, nil
+}
+
+func mergeRowTags(dst, src map[string]map[string]string) {
+	for key, labels := range src {
+		key = strings.TrimPrefix(strings.TrimSpace(key), ".")
+		if dst[key] == nil {
+			dst[key] = make(map[string]string)
+		}
+		for name, value := range labels {
+			dst[key][name] = value
+		}
+	}
+}
+
+// lookup returns the tags of the row with the given index key and index values
+func (t *rowTags) lookup(indexKey string, indexValues map[string]string) map[string]string {
+	if t == nil {
+		return nil
+	}
+	if t.key != "" {
+		return t.tags[indexValues[t.key]]
+	}
+	return t.tags[indexKey]
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestRowTags(t *testing.T) {
+	var parsed metricSetParser
+	config := `
+row_tags:
+  "3": {circuit: MPLS-A, provider: Lumen}
+  ".1.4": {circuit: backup}
+`
+	if err := yaml.Unmarshal([]byte(config), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := parseRowTags(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels := tags.lookup("3", nil); labels["circuit"] != "MPLS-A" || labels["provider"] != "Lumen" {
+		t.Errorf("unexpected tags %v for row 3", labels)
+	}
+	if labels := tags.lookup("1.4", nil); labels["circuit"] != "backup" {
+		t.Errorf("unexpected tags %v for row 1.4", labels)
+	}
+	if labels := tags.lookup("5", nil); labels != nil {
+		t.Errorf("unexpected tags %v for untagged row", labels)
+	}
+
+	tags.key = "ifName"
+	if labels := tags.lookup("7", map[string]string{"ifName": "3"}); labels["circuit"] != "MPLS-A" {
+		t.Errorf("unexpected tags %v looked up by ifName", labels)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/log"
+	"github.com/soniah/gosnmp"
+)
+
+// counterSample is the previous value of a rate or delta metric, persisted
+// between runs in the state store
+type counterSample struct {
+	Value   float64
+	TakenAt int64 // unix milliseconds
+}
+
+// negativeDeltaPolicies are the accepted values of the negative_deltas argument,
+// deciding what is reported when a counter decreased without wrapping
+var negativeDeltaPolicies = map[string]bool{
+	"drop":      true,
+	"zero":      true,
+	"attribute": true,
+}
+
+// sysUpTimeOid is the SNMPv2-MIB sysUpTime scalar
+const sysUpTimeOid = ".1.3.6.1.2.1.1.3.0"
+
+// deviceBootTime is when the target device last restarted, derived from its
+// sysUpTime. Samples taken before it are not used as baselines. It is zero
+// when the uptime of the device is unknown
+var deviceBootTime time.Time
+
+// readDeviceUptime reads the sysUpTime of the target device to detect the
+// restarts that reset its counters
+func readDeviceUptime() {
+	result, err := theSNMP.Get([]string{sysUpTimeOid})
+	if err != nil || result.Error != gosnmp.NoError || len(result.Variables) == 0 || result.Variables[0].Type != gosnmp.TimeTicks {
+		log.Debug("unable to read sysUpTime of %s, counter discontinuities after restarts won't be detected", targetHost)
+		return
+	}
+	ticks := gosnmp.ToBigInt(result.Variables[0].Value).Int64()
+	deviceBootTime = time.Now().Add(-time.Duration(ticks) * 10 * time.Millisecond)
+}
+
+// resetRowSamples discards the baselines of the rate and delta metrics of a
+// table row when its discontinuity timer changed since the previous run
+func resetRowSamples(metricSet metricSet, row *tableRow) {
+	pdu, ok := row.pdus[metricSet.DiscontinuityOid]
+	if !ok || pdu.Type != gosnmp.TimeTicks {
+		return
+	}
+	current := gosnmp.ToBigInt(pdu.Value).Int64()
+	key := fmt.Sprintf("discontinuity:%s:%d:%s.%s", targetHost, targetPort, metricSet.DiscontinuityOid, row.indexKey)
+	var previous int64
+	_, found := getState(key, &previous)
+	setState(key, current)
+	if !found || previous == current {
+		return
+	}
+	log.Debug("counter discontinuity in row %s of %s, discarding rate baselines", row.indexKey, metricSet.Name)
+	for _, def := range metricSet.Metrics {
+		metricName := def.metricName
+		if metricName == "" {
+			metricName = mibs.objectName(def.oid + "." + row.indexKey)
+		}
+		deleteState(sampleKey(def.oid+"."+row.indexKey, metricName))
+		if def.fallbackOid != "" {
+			deleteState(sampleKey(def.fallbackOid+"."+row.indexKey, metricName))
+		}
+	}
+	for _, d := range metricSet.Derived {
+		deleteState(sampleKey(metricSet.Name+"."+row.indexKey, d.metricName))
+	}
+}
+
+// sampleKey identifies the series of a rate or delta metric on the target
+// device. source is the full OID of the value, index included, or another
+// identifier of the row for derived metrics
+func sampleKey(source string, metricName string) string {
+	return fmt.Sprintf("sample:%s:%d:%s:%s", targetHost, targetPort, source, metricName)
+}
+
+// sampleType is how a numeric metric is reported: with an SDK source type
+// and, for pdelta and prate metrics, never for a decreasing value whatever
+// the negative_deltas policy
+type sampleType struct {
+	sourceType metric.SourceType
+	positive   bool
+}
+
+// sampledType returns how a numeric metric of the given metric type is reported
+func sampledType(metricType metricSourceType) sampleType {
+	switch metricType {
+	case delta:
+		return sampleType{sourceType: metric.DELTA}
+	case rate:
+		return sampleType{sourceType: metric.RATE}
+	case pdelta:
+		return sampleType{sourceType: metric.DELTA, positive: true}
+	case prate:
+		return sampleType{sourceType: metric.RATE, positive: true}
+	}
+	return sampleType{sourceType: metric.GAUGE}
+}
+
+// setSampledMetric reports a metric of the given sample type. Rates and deltas
+// are computed by the integration from the previous sample of the series, so
+// they are correct for table rows and in run-once mode. The first sample of a
+// series is stored but not reported. Without a state store the SDK computes them
+func setSampledMetric(ms *metric.Set, metricName string, source string, value interface{}, st sampleType) error {
+	return setCounterMetric(ms, metricName, source, value, st, 0)
+}
+
+// setCounterMetric is setSampledMetric for a counter that wraps to 0 once it
+// reaches wrapAt, 0 when it is not a wrapping counter
+func setCounterMetric(ms *metric.Set, metricName string, source string, value interface{}, st sampleType, wrapAt float64) error {
+	sourceType := st.sourceType
+	if (sourceType != metric.RATE && sourceType != metric.DELTA) || stateStore == nil {
+		if err := ms.SetMetric(metricName, value, sourceType); err != nil {
+			return err
+		}
+		//the SDK reports decreases, pdelta and prate metrics never do
+		if reported, ok := ms.Metrics[metricName].(float64); ok && st.positive && reported < 0 {
+			delete(ms.Metrics, metricName)
+		}
+		return nil
+	}
+	current, err := sampleValue(value)
+	if err != nil {
+		return fmt.Errorf("%v for %v", err, metricName)
+	}
+	key := sampleKey(source, metricName)
+	now := time.Now()
+	var previous counterSample
+	_, found := getState(key, &previous)
+	setState(key, counterSample{Value: current, TakenAt: now.UnixNano() / int64(time.Millisecond)})
+	if !found {
+		return nil
+	}
+	if !deviceBootTime.IsZero() && previous.TakenAt < deviceBootTime.UnixNano()/int64(time.Millisecond) {
+		log.Debug("%s restarted since the previous sample of %s, discarding it", targetHost, metricName)
+		return nil
+	}
+
+	difference, ok := counterDifference(previous.Value, current, wrapAt)
+	if !ok {
+		log.Debug("%s went from %v to %v, assuming a counter reset", metricName, previous.Value, current)
+		if st.positive {
+			return nil
+		}
+		switch args.NegativeDeltas {
+		case "zero":
+			return ms.SetMetric(metricName, 0, metric.GAUGE)
+		case "attribute":
+			//an attribute keeps the artifact visible without firing alerts on the metric
+			return ms.SetMetric(metricName+"NegativeDelta", fmt.Sprintf("%v", current-previous.Value), metric.ATTRIBUTE)
+		}
+		return nil
+	}
+	if sourceType == metric.DELTA {
+		return ms.SetMetric(metricName, difference, metric.GAUGE)
+	}
+	elapsed := time.Duration(now.UnixNano()/int64(time.Millisecond)-previous.TakenAt) * time.Millisecond
+	if elapsed <= 0 {
+		return nil
+	}
+	return ms.SetMetric(metricName, difference/elapsed.Seconds(), metric.GAUGE)
+}
+
+// counterDifference returns how much a counter increased between two samples.
+// A decrease is corrected as a wrap when the counter wraps at wrapAt and the
+// corrected increase is under half of its range, which would otherwise take
+// a reset with the counter back near 0 for a wrap. Other decreases are resets
+func counterDifference(previous, current, wrapAt float64) (float64, bool) {
+	if current >= previous {
+		return current - previous, true
+	}
+	if wrapAt > 0 && previous < wrapAt {
+		if wrapped := wrapAt - previous + current; wrapped < wrapAt/2 {
+			return wrapped, true
+		}
+	}
+	return 0, false
+}
+
+// counterWrap returns the value at which counters of the given type wrap.
+// Counter64 values are not expected to wrap, a decrease is a reset
+func counterWrap(pduType gosnmp.Asn1BER) float64 {
+	if pduType == gosnmp.Counter32 {
+		return 1 << 32
+	}
+	return 0
+}
+
+// sampleValue converts the numeric value of a metric to a float64
+func sampleValue(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f, nil
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	}
+	return 0, fmt.Errorf("non numeric value %v", value)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/persist"
+	"github.com/soniah/gosnmp"
+)
+
+func TestSetSampledMetric(t *testing.T) {
+	stateStore = persist.NewInMemoryStore()
+	defer func() { stateStore = nil }()
+
+	ms := metric.NewSet("TestSample", nil)
+	if err := setSampledMetric(ms, "ifInOctets", ".1.3.6.1.2.1.2.2.1.10.1", 1000.0, sampleType{sourceType: metric.RATE}); err != nil {
+		t.Fatal(err)
+	}
+	if _, reported := ms.Metrics["ifInOctets"]; reported {
+		t.Error("first sample of a series should not be reported")
+	}
+
+	takenAt := time.Now().Add(-10*time.Second).UnixNano() / int64(time.Millisecond)
+	setState(sampleKey(".1.3.6.1.2.1.2.2.1.10.1", "ifInOctets"), counterSample{Value: 1000, TakenAt: takenAt})
+	if err := setSampledMetric(ms, "ifInOctets", ".1.3.6.1.2.1.2.2.1.10.1", 6000.0, sampleType{sourceType: metric.RATE}); err != nil {
+		t.Fatal(err)
+	}
+	if rate, ok := ms.Metrics["ifInOctets"].(float64); !ok || rate < 490 || rate > 510 {
+		t.Errorf("unexpected rate %v", ms.Metrics["ifInOctets"])
+	}
+
+	//rows of the same table are separate series
+	if err := setSampledMetric(ms, "ifInOctets", ".1.3.6.1.2.1.2.2.1.10.2", 50.0, sampleType{sourceType: metric.DELTA}); err != nil {
+		t.Fatal(err)
+	}
+	if err := setSampledMetric(ms, "ifInOctets", ".1.3.6.1.2.1.2.2.1.10.2", 80.0, sampleType{sourceType: metric.DELTA}); err != nil {
+		t.Fatal(err)
+	}
+	if delta := ms.Metrics["ifInOctets"]; delta != 30.0 {
+		t.Errorf("unexpected delta %v", delta)
+	}
+}
+
+func TestCounterDifference(t *testing.T) {
+	cases := []struct {
+		previous, current, wrapAt float64
+		difference                float64
+		ok                        bool
+	}{
+		{100, 250, 1 << 32, 150, true},
+		{4294967000, 704, 1 << 32, 1000, true},
+		{1000, 10, 1 << 32, 0, false},
+		{4294967000, 704, 0, 0, false},
+	}
+	for _, c := range cases {
+		difference, ok := counterDifference(c.previous, c.current, c.wrapAt)
+		if difference != c.difference || ok != c.ok {
+			t.Errorf("counterDifference(%v, %v, %v) = %v, %v, expected %v, %v", c.previous, c.current, c.wrapAt, difference, ok, c.difference, c.ok)
+		}
+	}
+}
+
+func TestPositiveDelta(t *testing.T) {
+	stateStore = persist.NewInMemoryStore()
+	defer func() { stateStore = nil }()
+	args.NegativeDeltas = "zero"
+	defer func() { args.NegativeDeltas = "" }()
+
+	ms := metric.NewSet("TestSample", nil)
+	setState(sampleKey(".1.3.6.1.4.1.9.9.1", "sessions"), counterSample{Value: 50, TakenAt: 1})
+	if err := setSampledMetric(ms, "sessions", ".1.3.6.1.4.1.9.9.1", 20.0, sampledType(pdelta)); err != nil {
+		t.Fatal(err)
+	}
+	if _, reported := ms.Metrics["sessions"]; reported {
+		t.Errorf("decrease reported as %v for pdelta", ms.Metrics["sessions"])
+	}
+	if err := setSampledMetric(ms, "sessions", ".1.3.6.1.4.1.9.9.1", 35.0, sampledType(pdelta)); err != nil {
+		t.Fatal(err)
+	}
+	if ms.Metrics["sessions"] != 15.0 {
+		t.Errorf("unexpected pdelta %v", ms.Metrics["sessions"])
+	}
+}
+
+func TestPositiveDeltaWithoutStateStore(t *testing.T) {
+	//without a state store the SDK computes deltas and rates, and reports decreases
+	now := time.Now()
+	persist.SetNow(func() time.Time { return now })
+	defer persist.SetNow(time.Now)
+	ms := metric.NewSet("TestSample", persist.NewInMemoryStore(), metric.Attr("device", "router"))
+	cases := []struct {
+		value    float64
+		expected interface{}
+	}{
+		{50, 0.0},
+		{20, nil},
+		{35, 15.0},
+	}
+	for _, c := range cases {
+		now = now.Add(10 * time.Second)
+		delete(ms.Metrics, "sessions")
+		if err := setSampledMetric(ms, "sessions", ".1.3.6.1.4.1.9.9.1", c.value, sampledType(pdelta)); err != nil {
+			t.Fatal(err)
+		}
+		if value := ms.Metrics["sessions"]; value != c.expected {
+			t.Errorf("pdelta of %v reported as %#v, expected %#v", c.value, value, c.expected)
+		}
+	}
+}
+
+func TestDeviceRestart(t *testing.T) {
+	stateStore = persist.NewInMemoryStore()
+	defer func() { stateStore = nil }()
+	defer func() { deviceBootTime = time.Time{} }()
+	takenAt := time.Now().Add(-time.Minute)
+	cases := []struct {
+		name     string
+		bootTime time.Time
+		reported bool
+	}{
+		{"unknown uptime", time.Time{}, true},
+		{"uptime increased", takenAt.Add(-time.Hour), true},
+		{"uptime went backwards", takenAt.Add(30 * time.Second), false},
+	}
+	for _, c := range cases {
+		deviceBootTime = c.bootTime
+		setState(sampleKey(".1.3.6.1.2.1.2.2.1.10.1", "ifInOctets"), counterSample{Value: 1000, TakenAt: takenAt.UnixNano() / int64(time.Millisecond)})
+		ms := metric.NewSet("TestSample", nil)
+		if err := setSampledMetric(ms, "ifInOctets", ".1.3.6.1.2.1.2.2.1.10.1", 7000.0, sampleType{sourceType: metric.DELTA}); err != nil {
+			t.Fatal(err)
+		}
+		if _, reported := ms.Metrics["ifInOctets"]; reported != c.reported {
+			t.Errorf("%s: delta reported %v, expected %v", c.name, reported, c.reported)
+		}
+	}
+}
+
+func TestResetRowSamples(t *testing.T) {
+	defer func() { stateStore = nil }()
+	discontinuityOid := ".1.3.6.1.2.1.31.1.1.1.19"
+	metricSet := metricSet{
+		Name:             "interfaces",
+		DiscontinuityOid: discontinuityOid,
+		Metrics:          []*metricDef{{oid: ".1.3.6.1.2.1.31.1.1.1.6", metricName: "ifHCInOctets", metricType: rate}},
+	}
+	timer := func(ticks uint) gosnmp.SnmpPDU {
+		return gosnmp.SnmpPDU{Name: discontinuityOid + ".1", Type: gosnmp.TimeTicks, Value: ticks}
+	}
+	cases := []struct {
+		name     string
+		previous []gosnmp.SnmpPDU
+		current  gosnmp.SnmpPDU
+		reset    bool
+	}{
+		{"first run", nil, timer(500), false},
+		{"unchanged timer", []gosnmp.SnmpPDU{timer(500)}, timer(500), false},
+		{"changed timer", []gosnmp.SnmpPDU{timer(500)}, timer(9000), true},
+		{"timer back to 0", []gosnmp.SnmpPDU{timer(500)}, timer(0), true},
+		{"no timer", []gosnmp.SnmpPDU{timer(500)}, gosnmp.SnmpPDU{Name: discontinuityOid + ".1", Type: gosnmp.NoSuchInstance}, false},
+	}
+	for _, c := range cases {
+		stateStore = persist.NewInMemoryStore()
+		for _, pdu := range c.previous {
+			resetRowSamples(metricSet, &tableRow{indexKey: "1", pdus: map[string]gosnmp.SnmpPDU{discontinuityOid: pdu}})
+		}
+		for _, indexKey := range []string{"1", "2"} {
+			setState(sampleKey(".1.3.6.1.2.1.31.1.1.1.6."+indexKey, "ifHCInOctets"), counterSample{Value: 1000, TakenAt: 1})
+		}
+		resetRowSamples(metricSet, &tableRow{indexKey: "1", pdus: map[string]gosnmp.SnmpPDU{discontinuityOid: c.current}})
+		var sample counterSample
+		if _, kept := getState(sampleKey(".1.3.6.1.2.1.31.1.1.1.6.1", "ifHCInOctets"), &sample); kept == c.reset {
+			t.Errorf("%s: baseline of the row kept %v, expected %v", c.name, kept, !c.reset)
+		}
+		if _, kept := getState(sampleKey(".1.3.6.1.2.1.31.1.1.1.6.2", "ifHCInOctets"), &sample); !kept {
+			t.Errorf("%s: baseline of another row discarded", c.name)
+		}
+	}
+}
+
+func TestNegativeDeltas(t *testing.T) {
+	stateStore = persist.NewInMemoryStore()
+	defer func() { stateStore = nil }()
+	defer func() { args.NegativeDeltas = "" }()
+	cases := []struct {
+		policy     string
+		sourceType metric.SourceType
+		expected   map[string]interface{}
+	}{
+		{"", metric.DELTA, map[string]interface{}{}},
+		{"drop", metric.DELTA, map[string]interface{}{}},
+		{"drop", metric.RATE, map[string]interface{}{}},
+		{"zero", metric.DELTA, map[string]interface{}{"ifInOctets": 0.0}},
+		{"zero", metric.RATE, map[string]interface{}{"ifInOctets": 0.0}},
+		{"attribute", metric.DELTA, map[string]interface{}{"ifInOctetsNegativeDelta": "-900"}},
+		{"attribute", metric.RATE, map[string]interface{}{"ifInOctetsNegativeDelta": "-900"}},
+	}
+	for _, c := range cases {
+		args.NegativeDeltas = c.policy
+		setState(sampleKey(".1.3.6.1.2.1.2.2.1.10.1", "ifInOctets"), counterSample{Value: 1000, TakenAt: 1})
+		ms := metric.NewSet("TestSample", nil)
+		if err := setSampledMetric(ms, "ifInOctets", ".1.3.6.1.2.1.2.2.1.10.1", 100.0, sampleType{sourceType: c.sourceType}); err != nil {
+			t.Fatal(err)
+		}
+		delete(ms.Metrics, "event_type")
+		if !reflect.DeepEqual(ms.Metrics, c.expected) {
+			t.Errorf("negative_deltas %q with source type %v reported %v, expected %v", c.policy, c.sourceType, ms.Metrics, c.expected)
+		}
+	}
+}
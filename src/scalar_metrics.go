@@ -12,23 +12,26 @@ import (
 
 func populateScalarMetrics(device string, metricSet metricSet, entity *integration.Entity) error {
 	var oids []string
-	oidToMetricMap := make(map[string]*metricDef)
+	//several metrics can read the same OID, e.g. the fields of a packed integer
+	oidToMetricMap := make(map[string][]*metricDef)
 	for _, metric := range metricSet.Metrics {
 		oid := strings.TrimSpace(metric.oid)
-		oids = append(oids, oid)
-		oidToMetricMap[oid] = metric
+		if _, ok := oidToMetricMap[oid]; !ok {
+			oids = append(oids, oid)
+		}
+		oidToMetricMap[oid] = append(oidToMetricMap[oid], metric)
 		//All scalar OIDs must end with a .0 suffix by convention.
 		//But they are not always specified with their .0 suffix in MIBs and elsewhere
 		//So be nice and treat an OID and and its variant with .0 suffix as equivalent
 		if !strings.HasSuffix(oid, ".0") {
-			oidToMetricMap[oid+".0"] = metric
+			oidToMetricMap[oid+".0"] = append(oidToMetricMap[oid+".0"], metric)
 		}
 	}
 	if len(oids) == 0 {
 		return nil
 	}
-	if len(oids) > 200 {
-		return fmt.Errorf("Metric Set %s has %d metrics, the current limit is 200. This metric set will not be reported", metricSet.Name, len(oids))
+	if len(oids) > maxScalarOids {
+		return fmt.Errorf("Metric Set %s has %d metrics, the current limit is %d. This metric set will not be reported", metricSet.Name, len(oids), maxScalarOids)
 	}
 
 	ms := entity.NewMetricSet(metricSet.EventType, metric.Attr("IntegrationVersion", integrationVersion))
@@ -59,30 +62,109 @@ func populateScalarMetrics(device string, metricSet metricSet, entity *integrati
 		return nil
 	}
 
+	var fallbackOids []string
+	fallbackMetrics := make(map[string][]*metricDef)
+	values := make(map[string]float64)
 	for _, pdu := range snmpGetResult.Variables {
-		if pdu.Type == gosnmp.NoSuchObject || pdu.Type == gosnmp.NoSuchInstance {
-			log.Warn("OID %s not supported by target %s", pdu.Name, targetHost)
-			continue
-		}
 		oid := strings.TrimSpace(pdu.Name)
-		metric, ok := oidToMetricMap[oid]
-		if ok {
-			metricName := metric.metricName
-			if metricName == "" {
-				metricName = metric.oid
+		metrics, ok := oidToMetricMap[oid]
+		if !ok {
+			if isNullPDU(pdu) {
+				setNullScalarMetric(nil, pdu, ms)
+				continue
 			}
-			err := createMetric(metricName, metric.metricType, pdu, ms)
-			if err != nil {
-				log.Error(err.Error())
-			}
-		} else {
 			errorMessage, ok := knownErrorOids[oid]
 			if ok {
 				log.Error(errorMessage)
 			} else {
-				log.Debug("unexpected OID %s received")
+				log.Debug("unexpected OID %s received", oid)
+			}
+			continue
+		}
+		for _, metric := range metrics {
+			if isNullPDU(pdu) {
+				if metric.fallbackOid != "" {
+					log.Debug("OID %s not supported by target %s, falling back to %s", pdu.Name, targetHost, metric.fallbackOid)
+					if _, ok := fallbackMetrics[metric.fallbackOid]; !ok {
+						fallbackOids = append(fallbackOids, metric.fallbackOid)
+					}
+					fallbackMetrics[metric.fallbackOid] = append(fallbackMetrics[metric.fallbackOid], metric)
+					if !strings.HasSuffix(metric.fallbackOid, ".0") {
+						fallbackMetrics[metric.fallbackOid+".0"] = append(fallbackMetrics[metric.fallbackOid+".0"], metric)
+					}
+					continue
+				}
+				setNullScalarMetric(metric, pdu, ms)
+				continue
+			}
+			setScalarMetric(metric, pdu, values, ms)
+		}
+	}
+	if len(fallbackOids) > 0 {
+		if err := populateFallbackMetrics(fallbackOids, fallbackMetrics, values, ms); err != nil {
+			return err
+		}
+	}
+	setDerivedMetrics(metricSet.Derived, values, metricSet.Name, ms)
+	setTemplateAttributes(metricSet.Attributes, ms)
+	return nil
+}
+
+// populateFallbackMetrics reads the fallback OIDs of the metrics the target
+// does not support and reports them under the names of those metrics
+func populateFallbackMetrics(oids []string, oidToMetricMap map[string][]*metricDef, values map[string]float64, ms *metric.Set) error {
+	snmpGetResult, err := theSNMP.Get(oids)
+	if err != nil {
+		return err
+	}
+	if snmpGetResult.Error != gosnmp.NoError {
+		return fmt.Errorf("%s: %s", getErrorCode(snmpGetResult.Error), getErrorMessage(snmpGetResult.Error))
+	}
+	for _, pdu := range snmpGetResult.Variables {
+		for _, metric := range oidToMetricMap[strings.TrimSpace(pdu.Name)] {
+			if isNullPDU(pdu) {
+				setNullScalarMetric(metric, pdu, ms)
+				continue
 			}
+			setScalarMetric(metric, pdu, values, ms)
 		}
 	}
 	return nil
 }
+
+// setScalarMetric reports the value of a scalar metric, keeping its numeric
+// value by metric name and by OID for derived metrics
+func setScalarMetric(def *metricDef, pdu gosnmp.SnmpPDU, values map[string]float64, ms *metric.Set) {
+	metricName := def.metricName
+	if metricName == "" {
+		metricName = mibs.objectName(def.oid)
+	}
+	if value, ok := def.numericValue(pdu); ok {
+		values[metricName] = value
+		values[def.oid] = value
+	}
+	if def.hidden {
+		return
+	}
+	if err := setMetric(def, metricName, pdu, ms); err != nil {
+		log.Error(err.Error())
+	}
+}
+
+// setNullScalarMetric applies the null policy of the metric of a scalar OID
+// that returned no value. Unknown and hidden metrics are only logged
+func setNullScalarMetric(def *metricDef, pdu gosnmp.SnmpPDU, ms *metric.Set) {
+	if def == nil || def.nullPolicy == nullSkip && def.format != "present" {
+		log.Warn("OID %s not supported by target %s", pdu.Name, targetHost)
+	}
+	if def == nil || def.hidden {
+		return
+	}
+	metricName := def.metricName
+	if metricName == "" {
+		metricName = mibs.objectName(def.oid)
+	}
+	if err := setNullMetric(def, metricName, ms); err != nil {
+		log.Error(err.Error())
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/soniah/gosnmp"
+)
+
+func TestScalarNullPolicy(t *testing.T) {
+	cases := []struct {
+		policy   nullPolicy
+		expected map[string]interface{}
+	}{
+		{nullSkip, map[string]interface{}{}},
+		{nullZero, map[string]interface{}{"sysUpTime": 0.0}},
+		{nullDefault, map[string]interface{}{"sysUpTime": -1.0}},
+		{nullFlag, map[string]interface{}{"sysUpTimeIsNull": "true"}},
+	}
+	for _, pduType := range []gosnmp.Asn1BER{gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.Null} {
+		pdu := gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.1.3.0", Type: pduType}
+		if !isNullPDU(pdu) {
+			t.Errorf("PDU type %x not recognized as null", pduType)
+		}
+		for _, c := range cases {
+			def := &metricDef{oid: ".1.3.6.1.2.1.1.3.0", metricName: "sysUpTime", metricType: gauge, nullPolicy: c.policy, defaultValue: "-1", defaultNumber: -1}
+			ms := metric.NewSet("TestSample", nil)
+			setNullScalarMetric(def, pdu, ms)
+			delete(ms.Metrics, "event_type")
+			if !reflect.DeepEqual(ms.Metrics, c.expected) {
+				t.Errorf("null policy %d for PDU type %x reported %v, expected %v", c.policy, pduType, ms.Metrics, c.expected)
+			}
+		}
+
+		//hidden and unknown metrics are never reported
+		ms := metric.NewSet("TestSample", nil)
+		setNullScalarMetric(&metricDef{oid: pdu.Name, metricName: "sysUpTime", nullPolicy: nullZero, hidden: true}, pdu, ms)
+		setNullScalarMetric(nil, pdu, ms)
+		if len(ms.Metrics) != 1 {
+			t.Errorf("unexpected metrics %v", ms.Metrics)
+		}
+	}
+}
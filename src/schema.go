@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// schemaError is a key or value of a collection file not matching the
+// schema of the collection parser. line and column are 0 when unknown
+type schemaError struct {
+	file    string
+	line    int
+	column  int
+	message string
+}
+
+func (e schemaError) Error() string {
+	location := e.file
+	if e.line > 0 {
+		location += ":" + strconv.Itoa(e.line)
+		if e.column > 0 {
+			location += ":" + strconv.Itoa(e.column)
+		}
+	}
+	return location + ": " + e.message
+}
+
+// schemaErrors are all the schema errors of a collection file
+type schemaErrors []schemaError
+
+func (e schemaErrors) Error() string {
+	var messages []string
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+var (
+	// yamlLineError matches the line and message of an error of the YAML decoder
+	yamlLineError = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	// unknownField matches a key without a field in the collection parser
+	unknownField = regexp.MustCompile(`^field (\S+) not found in type main\.(\w+)$`)
+	// wrongType matches a value that can not be decoded into its field
+	wrongType = regexp.MustCompile("^cannot unmarshal (!!\\w+) `(.*)` into (.+)$")
+	// duplicateKey matches a key set twice in the same mapping
+	duplicateKey = regexp.MustCompile(`^key "(.*)" already set in map$`)
+)
+
+// yamlKinds are the names of the YAML tags in schema errors
+var yamlKinds = map[string]string{
+	"!!str":   "a string",
+	"!!int":   "an integer",
+	"!!float": "a number",
+	"!!bool":  "a boolean",
+	"!!map":   "a mapping",
+	"!!seq":   "a list",
+	"!!null":  "null",
+}
+
+// unmarshalCollection decodes a collection file, rejecting the keys that are
+// not part of the schema, the values of the wrong type and the duplicate
+// keys. The errors are located at the line and column of the key or value in
+// src, the source of the file, and reported together as schemaErrors. The
+// lines of the decoded YAML are only those of src when it is not JSON
+func unmarshalCollection(file string, src []byte, decoded []byte, isJSON bool) (*collectionParser, error) {
+	var c collectionParser
+	err := yaml.UnmarshalStrict(decoded, &c)
+	if err == nil {
+		return &c, nil
+	}
+	var messages []string
+	if typeError, ok := err.(*yaml.TypeError); ok {
+		messages = typeError.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+	lines := strings.Split(string(src), "\n")
+	var errors schemaErrors
+	for _, message := range messages {
+		e := schemaError{file: file, message: strings.TrimPrefix(message, "yaml: ")}
+		match := yamlLineError.FindStringSubmatch(message)
+		if match == nil {
+			errors = append(errors, e)
+			continue
+		}
+		line, _ := strconv.Atoi(match[1])
+		e.message = match[2]
+		var text string
+		if field := unknownField.FindStringSubmatch(match[2]); field != nil {
+			e.message = fmt.Sprintf("unknown key %s in %s", field[1], schemaTypeName(field[2]))
+			text = field[1]
+		} else if value := wrongType.FindStringSubmatch(match[2]); value != nil {
+			e.message = fmt.Sprintf("invalid value %s, expected %s", value[2], schemaGoKind(value[3]))
+			if kind, ok := yamlKinds[value[1]]; ok {
+				e.message = fmt.Sprintf("invalid value %s, %s where %s is expected", value[2], kind, schemaGoKind(value[3]))
+			}
+			//long values are truncated by the decoder
+			text = strings.TrimSuffix(value[2], "...")
+		} else if key := duplicateKey.FindStringSubmatch(match[2]); key != nil {
+			e.message = "duplicate key " + key[1]
+			text = key[1]
+		}
+		if isJSON {
+			e.line, e.column = findJSONText(src, text)
+		} else {
+			e.line = line
+			if text != "" && line <= len(lines) {
+				e.column = strings.Index(lines[line-1], text) + 1
+			}
+		}
+		errors = append(errors, e)
+	}
+	return nil, errors
+}
+
+// findJSONText locates the first occurrence of a key or value in a JSON
+// source, as the decoded YAML does not keep its lines
+func findJSONText(src []byte, text string) (int, int) {
+	if text == "" {
+		return 0, 0
+	}
+	offset := bytes.Index(src, []byte(`"`+text))
+	if offset < 0 {
+		if offset = bytes.Index(src, []byte(text)); offset < 0 {
+			return 0, 0
+		}
+	}
+	line := bytes.Count(src[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(src[:offset], '\n')
+	return line, column
+}
+
+// schemaTypeName names the part of a collection file a parser type decodes,
+// such as `metric set` for metricSetParser
+func schemaTypeName(typeName string) string {
+	var name []rune
+	for _, r := range strings.TrimSuffix(typeName, "Parser") {
+		if unicode.IsUpper(r) {
+			name = append(name, ' ')
+		}
+		name = append(name, unicode.ToLower(r))
+	}
+	if string(name) == "collection" {
+		return "collection file"
+	}
+	return string(name)
+}
+
+// schemaGoKind describes the values a field of a parser type accepts
+func schemaGoKind(goType string) string {
+	switch {
+	case strings.HasPrefix(goType, "[]"):
+		return "a list"
+	case strings.HasPrefix(goType, "map["):
+		return "a mapping"
+	case strings.HasPrefix(goType, "main."):
+		return "a " + schemaTypeName(strings.TrimPrefix(goType, "main."))
+	case strings.HasPrefix(goType, "int"), strings.HasPrefix(goType, "uint"):
+		return "an integer"
+	case strings.HasPrefix(goType, "float"):
+		return "a number"
+	case goType == "bool":
+		return "a boolean"
+	case goType == "string":
+		return "a string"
+	}
+	return goType
+}
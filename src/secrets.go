@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// obfuscatedPrefix marks a credential obfuscated with the obfuscation_key, as
+// the infrastructure agent obfuscates secrets with `newrelic-infra -obfuscate`
+const obfuscatedPrefix = "obfuscated:"
+
+// deobfuscate decodes a value obfuscated by the infrastructure agent: the
+// base64 encoding of the value XORed with the repeated key
+func deobfuscate(obfuscated string, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("obfuscated values require an obfuscation_key")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(obfuscated))
+	if err != nil {
+		return "", fmt.Errorf("invalid obfuscated value: %v", err)
+	}
+	for i := range decoded {
+		decoded[i] ^= key[i%len(key)]
+	}
+	return string(decoded), nil
+}
+
+// resolveCredentials replaces the community and SNMPv3 passphrases given as
+// `obfuscated:<value>` by their plaintext, so that only their obfuscated form
+// is stored in the configuration of the integration. Credentials resolved by
+// the agent from its secrets providers, such as vault or CyberArk, are
+// received in plaintext and left unchanged
+func resolveCredentials() error {
+	credentials := []struct {
+		name  string
+		value *string
+	}{
+		{"community", &args.Community},
+		{"auth_passphrase", &args.AuthPassphrase},
+		{"priv_passphrase", &args.PrivPassphrase},
+	}
+	for _, credential := range credentials {
+		if !strings.HasPrefix(*credential.value, obfuscatedPrefix) {
+			continue
+		}
+		plaintext, err := deobfuscate(strings.TrimPrefix(*credential.value, obfuscatedPrefix), args.ObfuscationKey)
+		if err != nil {
+			return fmt.Errorf("failed to read the %s: %v", credential.name, err)
+		}
+		*credential.value = plaintext
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestResolveCredentials(t *testing.T) {
+	defer func() { args = argumentList{} }()
+
+	//obfuscated with `newrelic-infra -obfuscate -value s3cr3t -key secretKey`
+	args = argumentList{
+		Community:      "public",
+		AuthPassphrase: "obfuscated:AFYAAFYA",
+		ObfuscationKey: "secretKey",
+	}
+	if err := resolveCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	if args.AuthPassphrase != "s3cr3t" {
+		t.Errorf("unexpected auth_passphrase %q", args.AuthPassphrase)
+	}
+	if args.Community != "public" {
+		t.Errorf("a plaintext community should be unchanged, got %q", args.Community)
+	}
+
+	args = argumentList{PrivPassphrase: "obfuscated:AFYAAFYA"}
+	if err := resolveCredentials(); err == nil {
+		t.Error("expected error without an obfuscation_key")
+	}
+	args = argumentList{Community: "obfuscated:not base64", ObfuscationKey: "secretKey"}
+	if err := resolveCredentials(); err == nil {
+		t.Error("expected error for an invalid obfuscated value")
+	}
+}
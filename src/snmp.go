@@ -1,9 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"path/filepath"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	sdkArgs "github.com/newrelic/infra-integrations-sdk/args"
@@ -15,17 +17,59 @@ import (
 
 type argumentList struct {
 	sdkArgs.DefaultArgumentList
-	SNMPHost        string `default:"127.0.0.1" help:"Hostname or IP where the SNMP server is running."`
-	SNMPPort        int    `default:"161" help:"Port on which SNMP server is listening."`
-	Community       string `default:"public" help:"SNMP Version 2 Community string "`
-	V3              bool   `default:"false" help:"Use SNMP Version 3."`
-	SecurityLevel   string `default:"" help:"Valid values are noAuthnoPriv, authNoPriv or authPriv"`
-	Username        string `default:"" help:"The security name that identifies the SNMPv3 user."`
-	AuthProtocol    string `default:"SHA" help:"The algorithm used for SNMPv3 authentication (SHA or MD5)."`
-	AuthPassphrase  string `default:"" help:"The password used to generate the key used for SNMPv3 authentication."`
-	PrivProtocol    string `default:"AES" help:"The algorithm used for SNMPv3 message integrity."`
-	PrivPassphrase  string `default:"" help:"The password used to generate the key used to verify SNMPv3 message integrity."`
-	CollectionFiles string `default:"" help:"A comma separated list of full paths to metrics configuration files"`
+	SNMPHost                 string `default:"127.0.0.1" help:"Hostname or IP where the SNMP server is running."`
+	SNMPPort                 int    `default:"161" help:"Port on which SNMP server is listening."`
+	Community                string `default:"public" help:"SNMP Version 2 Community string "`
+	V3                       bool   `default:"false" help:"Use SNMP Version 3."`
+	SecurityLevel            string `default:"" help:"Valid values are noAuthnoPriv, authNoPriv or authPriv"`
+	Username                 string `default:"" help:"The security name that identifies the SNMPv3 user."`
+	AuthProtocol             string `default:"SHA" help:"The algorithm used for SNMPv3 authentication (SHA or MD5)."`
+	AuthPassphrase           string `default:"" help:"The password used to generate the key used for SNMPv3 authentication."`
+	PrivProtocol             string `default:"AES" help:"The algorithm used for SNMPv3 message integrity."`
+	PrivPassphrase           string `default:"" help:"The password used to generate the key used to verify SNMPv3 message integrity."`
+	ObfuscationKey           string `default:"" help:"The key the community, auth_passphrase and priv_passphrase given as obfuscated:<value> were obfuscated with by newrelic-infra -obfuscate"`
+	CollectionFiles          string `default:"" help:"A comma separated list of full paths to metrics configuration files, directories of configuration files or globs such as /etc/nri-snmp/collections.d/*.yml"`
+	Profiles                 string `default:"" help:"A comma separated list of device profiles collected in addition to the collection files, such as cisco-ios or apc, or auto to select one by the sysObjectID of the device"`
+	ProfileDirs              string `default:"" help:"A comma separated list of directories searched for profiles before the bundled ones"`
+	ProfileRepository        string `default:"" help:"The https:// or file:// URL of a .tar.gz archive of profiles, searched after the profile_dirs and before the bundled profiles, and cached locally"`
+	ProfileRepositorySha256  string `default:"" help:"The SHA-256 checksum the profile_repository archive must have, pinning its version"`
+	ProfileRepositoryRefresh string `default:"1h" help:"How long the profiles of an unpinned profile_repository are cached before being fetched again, such as 30m"`
+	EnableIfMib              bool   `default:"false" help:"Collect the IF-MIB interfaces with the bundled generic-if profile"`
+	EnableHostResourcesMib   bool   `default:"false" help:"Collect the HOST-RESOURCES-MIB processors and storage with the bundled generic-host-resources profile"`
+	EnableEntitySensorMib    bool   `default:"false" help:"Collect the ENTITY-SENSOR-MIB sensors with the bundled generic-entity-sensor profile"`
+	EnableUpsMib             bool   `default:"false" help:"Collect the UPS-MIB battery, input and output with the bundled generic-ups profile"`
+	EnablePrinterMib         bool   `default:"false" help:"Collect the Printer-MIB supplies and page counters with the bundled generic-printer profile"`
+	HardwareInventory        bool   `default:"false" help:"Walk the ENTITY-MIB entPhysicalTable and report the chassis, stacks, modules, power supplies, fans and the entities with a serial number, such as transceivers, as inventory"`
+	Variables                string `default:"" help:"A YAML mapping of the values substituted for ${NAME} references in collection files and profiles, such as {WAN_IF_REGEX: ^ge-, TEMP_WARN: 75}. Variables not set are looked up in the environment, then in the variables defaults of the file"`
+	Validate                 bool   `default:"false" help:"Validate the arguments and the collection files and profiles they configure, print the problems found with their file and line and exit, with a non-zero status on errors"`
+	Test                     bool   `default:"false" help:"Read sysDescr and sysUpTime from the target with the configured credentials, print whether it passed, naming the usmStats counter of a rejected SNMPv3 request, and exit"`
+	Init                     bool   `default:"false" help:"Ask for the target, its SNMP version and credentials and the profiles to collect, test the connectivity and write the integration configuration and a starter collection file"`
+	PrintConfig              bool   `default:"false" help:"Print the arguments, with the credentials redacted, and every collection file and profile they configure with their variables substituted and their includes and extended profiles merged, and exit"`
+	MigrateConfig            bool   `default:"false" help:"Upgrade the collection files of collection_files written in an older format_version to the current one in place, keeping the originals as .bak files, and exit"`
+	Browse                   string `default:"" help:"Walk the subtree of a numeric OID or MIB name on the target, list its variables with their MIB names, types and values, read commands to walk other subtrees and mark variables appended to a collection file as metric sets, and exit"`
+	DryRun                   bool   `default:"false" help:"Print the GET and GETBULK requests a run would send to the target, with their OIDs and max-repetitions, without connecting to it, and exit"`
+	Timeout                  string `default:"10s" help:"Timeout of the requests sent to the target, such as 5s, overridden by the timeout of a metric set"`
+	MetricAllowlist          string `default:"" help:"A comma separated list of metric name globs, such as ifHC*, or numeric OIDs of subtrees; only the configured metrics matching one are reported"`
+	MetricBlocklist          string `default:"" help:"A comma separated list of metric name globs, such as ifHC*, or numeric OIDs of subtrees; the metrics matching one are not reported, whatever collection file or profile defines them"`
+	EventTypeNamespace       string `default:"" help:"Prefix of the event type of every metric set reported, such as Staging reporting StagingSNMPSample, so several environments can share an account"`
+	MetricPrefix             string `default:"" help:"Prefix of the name of every numeric metric reported, attributes such as device, name and index keep their name"`
+	TableWorkers             int    `default:"1" help:"Maximum number of tables walked concurrently, each over its own connection to the device"`
+	MibDirs                  string `default:"" help:"A comma separated list of directories containing MIB files used to resolve symbolic names"`
+	MibCache                 string `default:"" help:"File caching the index compiled from the MIBs of mib_dirs, rebuilt when one of their files changes. Defaults to a file next to the state of the integration, none disables the cache"`
+	WalkTelemetry            bool   `default:"false" help:"Report requests, retries, PDUs, rows and duration of every table walk as SNMPWalkSample events"`
+	NegativeDeltas           string `default:"drop" help:"What is reported when a rate or delta goes negative after a counter reset: drop, zero or attribute"`
+	RawStrings               bool   `default:"false" help:"Report OctetString values as returned by the target, without trimming trailing NUL and whitespace padding"`
+	DeviceTime               bool   `default:"false" help:"Report the boot time and wall clock of the device, from snmpEngineTime, sysUpTime and hrSystemDate, and the offset of its clock, on every metric set"`
+	StrictTypes              bool   `default:"false" help:"Report metrics whose metric_type does not match the type returned by the target as SNMPValidationSample events instead of coercing them"`
+	FloatPrecision           int    `default:"-1" help:"Number of decimals float metrics such as rates and scaled values are rounded to, -1 to keep full precision"`
+	FromMib                  string `default:"" help:"Print a collection file with the tables of a MIB module, or of a single MODULE::table, loaded from mib_dirs and exit"`
+	FromWalk                 string `default:"" help:"Print a collection file proposing the scalars and tables found in an snmpwalk output file and exit"`
+	ImportDatadogProfile     string `default:"" help:"Print the collection file converted from a Datadog SNMP profile and exit"`
+	ImportTelegraf           string `default:"" help:"Print the collection file converted from the [[inputs.snmp]] of a Telegraf configuration file and exit"`
+	ImportLibrenms           string `default:"" help:"Print the profile converted from a comma separated list of LibreNMS OS and discovery definitions of an OS and exit"`
+	Translate                string `default:"" help:"Print the MIB names of a comma separated list of numeric OIDs, or the numeric OIDs of MIB names, resolved with the MIBs of mib_dirs and exit"`
+	CheckProfile             string `default:"" help:"Check that every OID of a comma separated list of profiles, by name or absolute path, exists on the target, or in the check_walk file, print the missing ones and exit"`
+	CheckWalk                string `default:"" help:"The snmpwalk output file check_profile checks the profiles against instead of the target"`
 }
 
 const (
@@ -41,6 +85,9 @@ var theSNMP *gosnmp.GoSNMP
 var targetHost string
 var targetPort int
 
+// entityLock serializes the creation of metric sets when tables are walked concurrently
+var entityLock sync.Mutex
+
 func main() {
 	// Create Integration
 	snmpIntegration, err := integration.New(integrationName, integrationVersion, integration.Args(&args))
@@ -48,60 +95,217 @@ func main() {
 		log.Error(err.Error())
 		return
 	}
+	command, oids, err := parseSubcommand(flag.Args())
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
 	//log execution time
 	if args.Verbose {
 		startTime := time.Now()
 		defer logExecutionTime(startTime)
 	}
 
-	targetHost = strings.TrimSpace(args.SNMPHost)
-	targetPort = args.SNMPPort
-	err = connect(targetHost, targetPort)
+	if args.Validate {
+		printValidation()
+		return
+	}
+
+	if _, ok := negativeDeltaPolicies[args.NegativeDeltas]; !ok {
+		log.Error("invalid negative_deltas %s, valid values are drop, zero and attribute", args.NegativeDeltas)
+		return
+	}
+
+	templateVariables, err = parseTemplateVariables(args.Variables)
 	if err != nil {
-		log.Error("Error connecting to snmp server " + targetHost)
 		log.Error(err.Error())
 		return
 	}
-	defer disconnect()
+	if err := resolveCredentials(); err != nil {
+		log.Error(err.Error())
+		return
+	}
+	if err := parseDurationArguments(); err != nil {
+		log.Error(err.Error())
+		return
+	}
+	metricFilters, err = parseMetricFilter(args.MetricAllowlist, args.MetricBlocklist)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	if !validNamespace.MatchString(args.EventTypeNamespace) || !validNamespace.MatchString(args.MetricPrefix) {
+		log.Error("event_type_namespace and metric_prefix can only contain letters, digits, underscores and colons")
+		return
+	}
 
-	// Ensure a collection file is specified
-	if args.CollectionFiles == "" {
-		log.Error("Must specify at least one collection file")
+	if args.Init {
+		if err := runInitWizard(os.Stdin, os.Stdout, testConnectivity); err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
 		return
 	}
 
-	// For each collection definition file, parse and collect it
-	collectionFiles := strings.Split(args.CollectionFiles, ",")
-	for _, collectionFile := range collectionFiles {
+	if args.FromMib != "" {
+		printGeneratedCollection(args.FromMib, generateCollection)
+		return
+	}
+	if args.FromWalk != "" {
+		printGeneratedCollection(args.FromWalk, generateWalkCollection)
+		return
+	}
+	if args.ImportDatadogProfile != "" {
+		printGeneratedCollection(args.ImportDatadogProfile, importDatadogProfile)
+		return
+	}
+	if args.ImportTelegraf != "" {
+		printGeneratedCollection(args.ImportTelegraf, importTelegrafConfig)
+		return
+	}
+	if args.ImportLibrenms != "" {
+		printGeneratedCollection(args.ImportLibrenms, importLibrenmsDefinitions)
+		return
+	}
+	if args.Translate != "" {
+		printTranslatedOids(args.Translate)
+		return
+	}
 
-		// Check that the filepath is an absolute path
-		if !filepath.IsAbs(collectionFile) {
-			log.Error("invalid metrics collection path %s. Metrics collection files must be specified as absolute paths.", collectionFile)
+	if args.CheckProfile != "" && args.CheckWalk != "" {
+		exists, err := walkOidExists(args.CheckWalk)
+		if err != nil {
+			log.Error("failed to read the walk file %s", args.CheckWalk)
+			log.Error(err.Error())
 			return
 		}
+		printProfileCheck(args.CheckProfile, exists)
+		return
+	}
 
-		// Parse the yaml file into a raw definition
-		collectionParser, err := parseYaml(collectionFile)
+	if args.MigrateConfig {
+		report, err := migrateConfig()
+		fmt.Print(report)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args.PrintConfig {
+		config, err := effectiveConfiguration(effectiveArguments())
 		if err != nil {
-			log.Error("failed to parse collection definition file: " + collectionFile)
 			log.Error(err.Error())
 			return
 		}
-		collections, err := parseCollection(collectionParser)
+		fmt.Print(config)
+		return
+	}
+
+	targetHost = strings.TrimSpace(args.SNMPHost)
+	targetPort = args.SNMPPort
+	if args.DryRun {
+		plan, err := dryRun()
 		if err != nil {
-			log.Error("failed to parse collection definition: " + collectionFile)
 			log.Error(err.Error())
 			return
 		}
+		fmt.Print(plan)
+		return
+	}
+	if command != "" {
+		if err := runSubcommand(os.Stdout, command, oids); err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if args.Browse != "" {
+		if err := browse(args.Browse); err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if args.Test {
+		printConnectivityTest()
+		return
+	}
+	err = connect(targetHost, targetPort)
+	if err != nil {
+		log.Error("Error connecting to snmp server " + targetHost)
+		log.Error(err.Error())
+		return
+	}
+	defer disconnect()
 
-		for _, collection := range collections {
-			if err := runCollection(collection, snmpIntegration); err != nil {
-				log.Error("failed to complete collection execution")
-				log.Error(err.Error())
-			}
+	if args.CheckProfile != "" {
+		printProfileCheck(args.CheckProfile, deviceOidExists)
+		return
+	}
+
+	// Ensure a collection file is specified
+	if args.CollectionFiles == "" && enabledProfiles(args.Profiles) == "" && !args.HardwareInventory {
+		log.Error("Must specify at least one collection file, profile, enabled MIB or hardware_inventory")
+		return
+	}
+
+	if err := loadMibDirs(); err != nil {
+		log.Error("failed to load MIB files")
+		log.Error(err.Error())
+		return
+	}
+
+	if err := openStateStore(); err != nil {
+		log.Warn("unable to open the state store, cached columns will be walked every run: %v", err)
+	}
+	defer saveStateStore()
+	readDeviceUptime()
+	if args.DeviceTime {
+		readDeviceTime()
+	}
+
+	collectionFiles, err := collectionFilePaths(args.CollectionFiles)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	if err := syncProfileRepository(); err != nil {
+		log.Error("failed to fetch the profile repository")
+		log.Error(err.Error())
+		return
+	}
+	profiles, err := profileFiles(enabledProfiles(args.Profiles))
+	if err != nil {
+		log.Error("failed to find the configured profiles")
+		log.Error(err.Error())
+		return
+	}
+	collectionFiles = append(collectionFiles, profiles...)
+
+	// Parse every collection definition file, then collect them
+	for _, collection := range loadCollections(collectionFiles) {
+		metricFilters.filterCollection(collection)
+		if err := runCollection(collection, snmpIntegration); err != nil {
+			log.Error("failed to complete collection execution")
+			log.Error(err.Error())
 		}
 	}
 
+	if args.HardwareInventory {
+		if err := populateHardwareInventory(snmpIntegration); err != nil {
+			log.Error("unable to populate the hardware inventory. %v", err)
+		}
+	}
+	if args.DeviceTime {
+		attachDeviceTime(snmpIntegration)
+	}
+	metricFilters.filterPayload(snmpIntegration)
+	applyNamespaces(snmpIntegration, args.EventTypeNamespace, args.MetricPrefix)
+	if args.Pretty {
+		groupMetricSets(snmpIntegration)
+	}
 	if err := snmpIntegration.Publish(); err != nil {
 		log.Error(err.Error())
 	}
@@ -116,25 +320,32 @@ func runCollection(collection *collection, i *integration.Integration) error {
 	}
 
 	device := collection.Device
+	typeConversions = collection.TypeConversions
+	//table metric sets sharing a root OID are walked once
+	tableGroups := make(map[string][]metricSet)
+	var tableRootOids []string
 	for _, metricSet := range collection.MetricSets {
 		metricSetType := metricSet.Type
 		switch metricSetType {
 		case "scalar":
+			restoreTimeout := setTimeout(theSNMP, metricSet.Timeout)
 			err = populateScalarMetrics(device, metricSet, entity)
+			restoreTimeout()
 			if err != nil {
 				log.Error("unable to populate metrics for scalar metric set [%s]. %v", metricSet.Name, err)
 				reportError(device, metricSet, entity, err.Error())
 			}
 		case "table":
-			err = populateTableMetrics(device, metricSet, entity)
-			if err != nil {
-				log.Error("unable to populate metrics for table [%v] %v", metricSet.RootOid, err)
-				reportError(device, metricSet, entity, err.Error())
+			if _, ok := tableGroups[metricSet.RootOid]; !ok {
+				tableRootOids = append(tableRootOids, metricSet.RootOid)
 			}
+			tableGroups[metricSet.RootOid] = append(tableGroups[metricSet.RootOid], metricSet)
 		default:
 			log.Error("invalid `metric_set` type: %s. check collection file", metricSetType)
 		}
 	}
+	walkTables(device, tableRootOids, tableGroups, entity)
+	reportTypeMismatches(device, entity)
 	err = populateInventory(collection.Inventory, entity)
 	if err != nil {
 		log.Error("unable to populate inventory. %s", err)
@@ -142,6 +353,66 @@ func runCollection(collection *collection, i *integration.Integration) error {
 	return nil
 }
 
+// walkTables walks the table groups, up to table_workers of them at a time.
+// Every concurrent walk uses its own connection as a connection can only
+// have one outstanding request
+func walkTables(device string, rootOids []string, tableGroups map[string][]metricSet, entity *integration.Entity) {
+	walk := func(client *gosnmp.GoSNMP, rootOid string) {
+		metricSets := tableGroups[rootOid]
+		err := populateTableGroupMetrics(client, device, metricSets, entity)
+		if err != nil {
+			log.Error("unable to populate metrics for table [%v] %v", rootOid, err)
+			entityLock.Lock()
+			for _, metricSet := range metricSets {
+				reportError(device, metricSet, entity, err.Error())
+			}
+			entityLock.Unlock()
+		}
+	}
+
+	if args.TableWorkers <= 1 || len(rootOids) <= 1 {
+		for _, rootOid := range rootOids {
+			walk(theSNMP, rootOid)
+		}
+		return
+	}
+
+	workers := args.TableWorkers
+	if workers > len(rootOids) {
+		workers = len(rootOids)
+	}
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 1; i < workers; i++ {
+		client := cloneSNMP(theSNMP)
+		if err := client.Connect(); err != nil {
+			log.Warn("unable to open an additional connection to %s, walking with fewer workers: %v", targetHost, err)
+			continue
+		}
+		wg.Add(1)
+		go func(client *gosnmp.GoSNMP) {
+			defer wg.Done()
+			defer client.Conn.Close() // nolint: errcheck
+			for rootOid := range queue {
+				walk(client, rootOid)
+			}
+		}(client)
+	}
+	//the main connection always takes part so the walk proceeds even if no extra connection could be opened
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for rootOid := range queue {
+			walk(theSNMP, rootOid)
+		}
+	}()
+	for _, rootOid := range rootOids {
+		queue <- rootOid
+	}
+	close(queue)
+	wg.Wait()
+}
+
 func reportError(device string, metricSet metricSet, entity *integration.Entity, errorMessage string) {
 	ms := entity.NewMetricSet(metricSet.EventType)
 	err := ms.SetMetric("device", device, metric.ATTRIBUTE)
@@ -162,6 +433,88 @@ func reportError(device string, metricSet metricSet, entity *integration.Entity,
 	}
 }
 
+// printGeneratedCollection prints the collection file generated from source,
+// with the MIBs of mib_dirs loaded to resolve and name OIDs
+func printGeneratedCollection(source string, generate func(source string) ([]byte, error)) {
+	if err := loadMibDirs(); err != nil {
+		log.Error("failed to load MIB files")
+		log.Error(err.Error())
+		return
+	}
+	collection, err := generate(source)
+	if err != nil {
+		log.Error("failed to generate a collection file from %s", source)
+		log.Error(err.Error())
+		return
+	}
+	fmt.Print(string(collection))
+}
+
+// printTranslatedOids prints the translations of OIDs with the MIBs of mib_dirs
+func printTranslatedOids(oids string) {
+	if err := loadMibDirs(); err != nil {
+		log.Error("failed to load MIB files")
+		log.Error(err.Error())
+		return
+	}
+	translated, err := translateOids(oids)
+	if err != nil {
+		log.Error("failed to translate %s", oids)
+		log.Error(err.Error())
+		return
+	}
+	fmt.Print(translated)
+}
+
+// printProfileCheck prints the OIDs of profiles missing from a target, with
+// the MIBs of mib_dirs loaded to resolve and name them, and exits with a
+// non-zero status when any is missing
+func printProfileCheck(profiles string, exists oidExists) {
+	if err := loadMibDirs(); err != nil {
+		log.Error("failed to load MIB files")
+		log.Error(err.Error())
+		return
+	}
+	report, missing, err := checkProfiles(profiles, exists)
+	if err != nil {
+		log.Error("failed to check the profiles %s", profiles)
+		log.Error(err.Error())
+		return
+	}
+	fmt.Print(report)
+	if missing > 0 {
+		os.Exit(1)
+	}
+}
+
+// printValidation prints the problems found in the configuration and exits
+// with a non-zero status when any is an error
+func printValidation() {
+	errors := 0
+	for _, d := range validateConfiguration() {
+		fmt.Println(d)
+		if !d.warning {
+			errors++
+		}
+	}
+	if errors > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("configuration is valid")
+}
+
+// printConnectivityTest prints whether the target could be read and exits
+// with a non-zero status when it could not
+func printConnectivityTest() {
+	target := fmt.Sprintf("%s:%d", targetHost, targetPort)
+	summary, err := testConnectivity()
+	if err != nil {
+		fmt.Printf("FAIL %s %v\n", target, err)
+		os.Exit(1)
+	}
+	fmt.Printf("PASS %s %s\n", target, summary)
+}
+
 func logExecutionTime(start time.Time) {
 	elapsed := time.Since(start)
 	log.Info("Execution took %s seconds", elapsed)
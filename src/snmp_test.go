@@ -1,10 +1,209 @@
 package main
 
 import (
+	"net"
+	"sort"
+	"strconv"
 	"testing"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/integration"
+	"github.com/newrelic/infra-integrations-sdk/persist"
+	"github.com/soniah/gosnmp"
 )
 
 // Insert here the logic for your tests
 func TestPlaceholder(t *testing.T) {
 	t.Skipped()
 }
+
+// serveFakeAgent answers the SNMPv2c GETBULK requests sent to a local UDP
+// port with the given agent, one request at a time. The first requests are
+// held until connections coming from holdFor different ports are waiting, or
+// for a second, so that concurrent walks cannot all end up on one connection.
+// It returns the port and a function stopping the agent, which returns the
+// number of connections requests came from
+func serveFakeAgent(t *testing.T, agent *fakeAgent, holdFor int) (uint16, func() int) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder := &gosnmp.GoSNMP{Version: gosnmp.Version2c, SecurityParameters: &gosnmp.UsmSecurityParameters{}}
+	type request struct {
+		packet *gosnmp.SnmpPacket
+		addr   net.Addr
+	}
+	answer := func(r request) {
+		oids := make([]string, 0, len(r.packet.Variables))
+		for _, pdu := range r.packet.Variables {
+			oids = append(oids, pdu.Name)
+		}
+		response, _ := agent.GetBulk(oids, r.packet.NonRepeaters, r.packet.MaxRepetitions)
+		//gosnmp marshals no endOfMibView, the repetitions reaching past the last variable are left out
+		for i, pdu := range response.Variables {
+			if pdu.Type == gosnmp.EndOfMibView {
+				response.Variables = response.Variables[:i-i%len(oids)]
+				break
+			}
+		}
+		response.Version, response.Community, response.PDUType, response.RequestID = gosnmp.Version2c, r.packet.Community, gosnmp.GetResponse, r.packet.RequestID
+		out, err := response.MarshalMsg()
+		if err != nil {
+			t.Errorf("unable to marshal the response: %v", err)
+			return
+		}
+		conn.WriteTo(out, r.addr) // nolint: errcheck
+	}
+
+	addrs := make(map[string]bool)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var held []request
+		if holdFor > 1 {
+			held = []request{}
+		}
+		holdUntil := time.Now().Add(time.Second)
+		buf := make([]byte, 65535)
+		for {
+			if held != nil {
+				conn.SetReadDeadline(holdUntil) // nolint: errcheck
+			}
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+					return
+				}
+			} else {
+				packet, err := decoder.SnmpDecodePacket(buf[:n])
+				if err != nil {
+					t.Errorf("unable to decode the request: %v", err)
+					continue
+				}
+				addrs[addr.String()] = true
+				if held == nil {
+					answer(request{packet, addr})
+					continue
+				}
+				held = append(held, request{packet, addr})
+			}
+			if len(addrs) >= holdFor || !time.Now().Before(holdUntil) {
+				for _, r := range held {
+					answer(r)
+				}
+				held = nil
+				conn.SetReadDeadline(time.Time{}) // nolint: errcheck
+			}
+		}
+	}()
+	port := uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+	return port, func() int {
+		conn.Close() // nolint: errcheck
+		<-done
+		return len(addrs)
+	}
+}
+
+func TestWalkTablesConcurrently(t *testing.T) {
+	columns := map[string]string{
+		".1.3.6.1.2.1.2.2.1.2":     "ifDescr",
+		".1.3.6.1.2.1.2.2.1.5":     "ifSpeed",
+		".1.3.6.1.2.1.31.1.1.1.1":  "ifName",
+		".1.3.6.1.2.1.31.1.1.1.15": "ifHighSpeed",
+		".1.3.6.1.2.1.25.2.3.1.3":  "hrStorageDescr",
+		".1.3.6.1.2.1.25.2.3.1.5":  "hrStorageSize",
+	}
+	tables := []struct {
+		rootOid  string
+		name     string
+		attr     string
+		counter  string
+		rowCount int
+	}{
+		{".1.3.6.1.2.1.2.2", "interfaces", ".1.3.6.1.2.1.2.2.1.2", ".1.3.6.1.2.1.2.2.1.5", 25},
+		{".1.3.6.1.2.1.31.1.1", "interfacesX", ".1.3.6.1.2.1.31.1.1.1.1", ".1.3.6.1.2.1.31.1.1.1.15", 25},
+		{".1.3.6.1.2.1.25.2.3", "storage", ".1.3.6.1.2.1.25.2.3.1.3", ".1.3.6.1.2.1.25.2.3.1.5", 12},
+	}
+	agent := &fakeAgent{}
+	tableGroups := make(map[string][]metricSet)
+	var rootOids []string
+	for _, table := range tables {
+		for _, column := range []string{table.attr, table.counter} {
+			for row := 1; row <= table.rowCount; row++ {
+				name := column + "." + strconv.Itoa(row)
+				if column == table.attr {
+					agent.pdus = append(agent.pdus, gosnmp.SnmpPDU{Name: name, Type: gosnmp.OctetString, Value: []byte(columns[column] + strconv.Itoa(row))})
+				} else {
+					agent.pdus = append(agent.pdus, gosnmp.SnmpPDU{Name: name, Type: gosnmp.Counter32, Value: uint32(row)})
+				}
+			}
+		}
+		metricSets := []metricSet{{
+			Name:      table.name,
+			Type:      "table",
+			EventType: "SNMPSample",
+			RootOid:   table.rootOid,
+			Metrics: []*metricDef{
+				{oid: table.attr, metricName: columns[table.attr], metricType: attribute},
+				{oid: table.counter, metricName: columns[table.counter], metricType: rate},
+			},
+		}}
+		buildColumnTrees(metricSets)
+		tableGroups[table.rootOid] = metricSets
+		rootOids = append(rootOids, table.rootOid)
+	}
+	//the walks end on the variable following the tables
+	agent.pdus = append(agent.pdus, gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.47.1.1.1.1.7.1", Type: gosnmp.OctetString, Value: []byte("chassis")})
+	sort.Slice(agent.pdus, func(i, j int) bool {
+		return compareOids(agent.pdus[i].Name, agent.pdus[j].Name) < 0
+	})
+
+	//the rates are sampled in the state store shared by the walks
+	stateStore = persist.NewInMemoryStore()
+	port, stop := serveFakeAgent(t, agent, len(tables))
+	theSNMP = &gosnmp.GoSNMP{Target: "127.0.0.1", Port: port, Community: "public", Version: gosnmp.Version2c, Timeout: 5 * time.Second}
+	args.TableWorkers = len(tables)
+	defer func() {
+		theSNMP = nil
+		stateStore = nil
+		args = argumentList{}
+	}()
+	if err := theSNMP.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer theSNMP.Conn.Close() // nolint: errcheck
+
+	i, err := integration.New("test", "0.0.0", integration.InMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity := i.LocalEntity()
+	walkTables("router", rootOids, tableGroups, entity)
+	if connections := stop(); connections != len(tables) {
+		t.Errorf("expected the tables to be walked over %d connections, got %d", len(tables), connections)
+	}
+
+	rows := make(map[string]int)
+	for _, ms := range entity.Metrics {
+		if message, ok := ms.Metrics["errorMessage"]; ok {
+			t.Errorf("table %v failed: %v", ms.Metrics["name"], message)
+			continue
+		}
+		name := ms.Metrics["name"].(string)
+		rows[name]++
+		for _, table := range tables {
+			if table.name != name {
+				continue
+			}
+			index := ms.Metrics["index"].(string)
+			if ms.Metrics[columns[table.attr]] != columns[table.attr]+index {
+				t.Errorf("row %s of %s mixes the rows of other tables: %v", index, name, ms.Metrics)
+			}
+		}
+	}
+	for _, table := range tables {
+		if rows[table.name] != table.rowCount {
+			t.Errorf("expected %d rows of %s, got %d", table.rowCount, table.name, rows[table.name])
+		}
+	}
+}
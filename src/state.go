@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/log"
+	"github.com/newrelic/infra-integrations-sdk/persist"
+)
+
+// stateStoreTTL is how old the state file can get before it is discarded.
+// It bounds the cache_ttl that can be honoured across runs
+const stateStoreTTL = 24 * time.Hour
+
+// stateStore keeps the values that must survive between runs, such as
+// cached table columns. It is nil until openStateStore succeeds
+var stateStore persist.Storer
+
+// stateLock serializes access to stateStore, which is not safe for concurrent use
+var stateLock sync.Mutex
+
+// openStateStore loads the state persisted by the previous runs
+func openStateStore() error {
+	store, err := persist.NewFileStore(persist.DefaultPath(integrationName+".state"), log.NewStdErr(args.Verbose), stateStoreTTL)
+	if err != nil {
+		return err
+	}
+	stateStore = store
+	return nil
+}
+
+// saveStateStore persists the state for the next run
+func saveStateStore() {
+	if stateStore == nil {
+		return
+	}
+	if err := stateStore.Save(); err != nil {
+		log.Error("unable to save state: %v", err)
+	}
+}
+
+// getState reads the value stored under key into valuePtr and returns when it
+// was stored. ok is false if there is no such value
+func getState(key string, valuePtr interface{}) (storedAt time.Time, ok bool) {
+	if stateStore == nil {
+		return time.Time{}, false
+	}
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	ts, err := stateStore.Get(key, valuePtr)
+	if err != nil {
+		if err != persist.ErrNotFound {
+			log.Warn("unable to read state %s: %v", key, err)
+		}
+		return time.Time{}, false
+	}
+	return time.Unix(ts, 0), true
+}
+
+// setState stores value under key
+func setState(key string, value interface{}) {
+	if stateStore == nil {
+		return
+	}
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	stateStore.Set(key, value)
+}
+
+// deleteState removes the value stored under key
+func deleteState(key string) {
+	if stateStore == nil {
+		return
+	}
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	if err := stateStore.Delete(key); err != nil {
+		log.Warn("unable to delete state %s: %v", key, err)
+	}
+}
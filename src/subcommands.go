@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/soniah/gosnmp"
+)
+
+// subcommands are the commands debugging the target with the configured
+// credentials, `nri-snmp get <oid>...` and `nri-snmp walk <oid>...`
+var subcommands = map[string]bool{
+	"get":  true,
+	"walk": true,
+}
+
+// parseSubcommand reads the command and its OIDs from the arguments left
+// after the flags. The flags must come before the command, they are parsed
+// with the integration arguments, before the logger is set up
+func parseSubcommand(arguments []string) (string, []string, error) {
+	if len(arguments) == 0 {
+		return "", nil, nil
+	}
+	command := arguments[0]
+	if !subcommands[command] {
+		return "", nil, fmt.Errorf("unknown command %s, the commands are get and walk", command)
+	}
+	var oids []string
+	for _, argument := range arguments[1:] {
+		if strings.HasPrefix(argument, "-") {
+			return "", nil, fmt.Errorf("flag %s given after the %s command, flags must come before it, as in nri-snmp -snmp_host 192.0.2.1 %s <oid>...", argument, command, command)
+		}
+		oids = append(oids, argument)
+	}
+	if len(oids) == 0 {
+		return "", nil, fmt.Errorf("%s takes the numeric OIDs or MIB names to read", command)
+	}
+	return command, oids, nil
+}
+
+// formatVariable renders a variable the way snmpget and snmpwalk print it,
+// such as `IF-MIB::ifDescr.1 = OctetString: "eth0"`
+func formatVariable(pdu gosnmp.SnmpPDU) string {
+	name := mibs.translate(pdu.Name)
+	switch pdu.Type {
+	case gosnmp.NoSuchObject:
+		return name + " = No Such Object available on this agent at this OID"
+	case gosnmp.NoSuchInstance:
+		return name + " = No Such Instance currently exists at this OID"
+	case gosnmp.EndOfMibView:
+		return name + " = No more variables left in this MIB View"
+	case gosnmp.Null:
+		return name + " = Null"
+	}
+	return fmt.Sprintf("%s = %s: %s", name, pduTypeName(pdu.Type), pduDisplayValue(pdu))
+}
+
+// runSubcommand connects to the target with the configured credentials and
+// prints the variables read by a get of the OIDs, or by a walk of each of
+// their subtrees
+func runSubcommand(out io.Writer, command string, oids []string) error {
+	if err := loadMibDirs(); err != nil {
+		return fmt.Errorf("failed to load MIB files: %v", err)
+	}
+	if err := connect(targetHost, targetPort); err != nil {
+		return err
+	}
+	defer disconnect()
+
+	var variables []gosnmp.SnmpPDU
+	switch command {
+	case "get":
+		var resolved []string
+		for _, oid := range oids {
+			numeric, err := resolveOid(oid)
+			if err != nil {
+				return err
+			}
+			resolved = append(resolved, numeric)
+		}
+		result, err := theSNMP.Get(resolved)
+		if err != nil {
+			return err
+		}
+		if result.Error != gosnmp.NoError {
+			return fmt.Errorf("%s: %s", getErrorCode(result.Error), getErrorMessage(result.Error))
+		}
+		variables = result.Variables
+	case "walk":
+		for _, oid := range oids {
+			walked, _, err := walkSubtree(oid, 0)
+			if err != nil {
+				return err
+			}
+			if len(walked) == 0 {
+				fmt.Fprintf(out, "%s = No Such Object available on this agent at this OID\n", oid)
+			}
+			variables = append(variables, walked...)
+		}
+	}
+	for _, variable := range variables {
+		if err := usmStatError(variable); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, formatVariable(variable))
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/soniah/gosnmp"
+)
+
+func TestParseSubcommand(t *testing.T) {
+	command, oids, err := parseSubcommand(nil)
+	if err != nil || command != "" {
+		t.Errorf("expected no command, got %s %v", command, err)
+	}
+	//the arguments left by the flags given before the command
+	command, oids, err = parseSubcommand([]string{"get", ".1.3.6.1.2.1.1.5.0", "SNMPv2-MIB::sysDescr.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command != "get" || strings.Join(oids, " ") != ".1.3.6.1.2.1.1.5.0 SNMPv2-MIB::sysDescr.0" {
+		t.Errorf("unexpected command %s %v", command, oids)
+	}
+	for _, arguments := range [][]string{{"set", ".1.3.6.1.2.1.1.5.0"}, {"walk"}, {"walk", "-community", "private"}, {"get", ".1.3.6.1.2.1.1.5.0", "-snmp_host", "192.0.2.1"}} {
+		if _, _, err := parseSubcommand(arguments); err == nil {
+			t.Errorf("expected error for %v", arguments)
+		}
+	}
+	_, _, err = parseSubcommand([]string{"walk", "IF-MIB::ifTable", "-community", "private"})
+	if err == nil || !strings.Contains(err.Error(), "flags must come before it") {
+		t.Errorf("unexpected error %v for a flag after the command", err)
+	}
+}
+
+func TestFormatVariable(t *testing.T) {
+	for _, test := range []struct {
+		pdu      gosnmp.SnmpPDU
+		expected string
+	}{
+		{gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("core-1")}, `.1.3.6.1.2.1.1.5.0 = OctetString: "core-1"`},
+		{gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(366100)}, `.1.3.6.1.2.1.1.3.0 = TimeTicks: 366100 (0d 01:01:01)`},
+		{gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.2.2.1.10.1", Type: gosnmp.Counter32, Value: uint(1200)}, `.1.3.6.1.2.1.2.2.1.10.1 = Counter32: 1200`},
+		{gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.1.2.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.9.1.1"}, `.1.3.6.1.2.1.1.2.0 = ObjectIdentifier: .1.3.6.1.4.1.9.1.1`},
+		{gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.1.9.0", Type: gosnmp.NoSuchObject}, `.1.3.6.1.2.1.1.9.0 = No Such Object available on this agent at this OID`},
+	} {
+		if formatted := formatVariable(test.pdu); formatted != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, formatted)
+		}
+	}
+}
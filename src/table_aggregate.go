@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/integration"
+	"github.com/newrelic/infra-integrations-sdk/log"
+)
+
+// aggregateFunctions are the accepted functions of table aggregates
+var aggregateFunctions = map[string]bool{
+	"sum":   true,
+	"min":   true,
+	"max":   true,
+	"avg":   true,
+	"count": true,
+}
+
+// columnSummary accumulates the values of a column across the rows of a table
+type columnSummary struct {
+	count int
+	sum   float64
+	min   float64
+	max   float64
+}
+
+func newColumnSummary() columnSummary {
+	return columnSummary{min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func (s *columnSummary) add(value float64) {
+	s.count++
+	s.sum += value
+	s.min = math.Min(s.min, value)
+	s.max = math.Max(s.max, value)
+}
+
+// value returns the result of an aggregate function over the summarized values
+func (s *columnSummary) value(function string) float64 {
+	switch function {
+	case "sum":
+		return s.sum
+	case "min":
+		return s.min
+	case "max":
+		return s.max
+	case "avg":
+		return s.sum / float64(s.count)
+	}
+	return float64(s.count)
+}
+
+// tableAggregates computes the aggregates of a table metric set while its rows are consumed
+type tableAggregates struct {
+	summaries []columnSummary
+}
+
+func newTableAggregates(metricSet metricSet) *tableAggregates {
+	summaries := make([]columnSummary, len(metricSet.Aggregates))
+	for i := range summaries {
+		summaries[i] = newColumnSummary()
+	}
+	return &tableAggregates{summaries: summaries}
+}
+
+// add accumulates the values of a row. Rows count for the aggregates without a column
+func (t *tableAggregates) add(metricSet metricSet, row *tableRow) {
+	for i, a := range metricSet.Aggregates {
+		if a.column == nil {
+			t.summaries[i].add(0)
+			continue
+		}
+		pdu, ok := row.pdus[a.column.oid]
+		if (!ok || isNullPDU(pdu)) && a.column.fallbackOid != "" {
+			pdu, ok = row.pdus[a.column.fallbackOid]
+		}
+		if !ok {
+			continue
+		}
+		if value, ok := a.column.numericValue(pdu); ok {
+			t.summaries[i].add(value)
+		}
+	}
+}
+
+// emit reports the aggregates of the table as a single metric set. Aggregates
+// without any value are not reported, except counts
+func (t *tableAggregates) emit(device string, metricSet metricSet, entity *integration.Entity) *metric.Set {
+	ms := entity.NewMetricSet(metricSet.EventType, metric.Attr("IntegrationVersion", integrationVersion), metric.Attr("aggregate", "true"))
+	err := ms.SetMetric("device", device, metric.ATTRIBUTE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("name", metricSet.Name, metric.ATTRIBUTE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	for i, a := range metricSet.Aggregates {
+		summary := t.summaries[i]
+		if summary.count == 0 && a.function != "count" {
+			continue
+		}
+		err = setSampledMetric(ms, a.metricName, metricSet.Name+"/aggregate", summary.value(a.function), sampledType(a.metricType))
+		if err != nil {
+			log.Error(err.Error())
+		}
+		roundMetric(ms, a.metricName, args.FloatPrecision)
+	}
+	return ms
+}
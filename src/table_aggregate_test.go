@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestParseAggregates(t *testing.T) {
+	metrics := []*metricDef{{oid: ".1.3.6.1.2.1.2.2.1.14", metricName: "ifInErrors", metricType: gauge}}
+	parsers := []aggregateParser{
+		{MetricName: "totalIfInErrors", Column: "ifInErrors", Function: "sum"},
+		{MetricName: "maxIfSpeed", Column: ".1.3.6.1.2.1.2.2.1.5", Function: "max"},
+		{MetricName: "interfaces", Function: "count"},
+	}
+	aggregates, metrics, err := parseAggregates(parsers, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aggregates) != 3 || aggregates[0].column != metrics[0] || aggregates[2].column != nil {
+		t.Errorf("unexpected aggregates %v", aggregates)
+	}
+	if len(metrics) != 2 || !metrics[1].hidden || metrics[1].oid != ".1.3.6.1.2.1.2.2.1.5" {
+		t.Errorf("unexpected hidden metrics %v", metrics)
+	}
+	for _, invalid := range []aggregateParser{
+		{MetricName: "a", Column: "ifInErrors", Function: "median"},
+		{MetricName: "b", Function: "sum"},
+		{MetricName: "c", Column: "ifOutErrors", Function: "sum"},
+	} {
+		if _, _, err := parseAggregates([]aggregateParser{invalid}, metrics); err == nil {
+			t.Errorf("expected error for %v", invalid)
+		}
+	}
+
+	summary := newColumnSummary()
+	for _, value := range []float64{3, 1, 8} {
+		summary.add(value)
+	}
+	if summary.value("sum") != 12 || summary.value("min") != 1 || summary.value("max") != 8 || summary.value("avg") != 4 || summary.value("count") != 3 {
+		t.Errorf("unexpected summary %v", summary)
+	}
+}
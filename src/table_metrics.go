@@ -2,8 +2,9 @@ package main
 
 import (
 	"fmt"
-	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/newrelic/infra-integrations-sdk/data/metric"
 	"github.com/newrelic/infra-integrations-sdk/integration"
@@ -11,14 +12,152 @@ import (
 	"github.com/soniah/gosnmp"
 )
 
-func populateTableMetrics(device string, metricSet metricSet, entity *integration.Entity) error {
+// defaultPageSize is the number of rows requested per GETBULK of the tables
+// whose metric sets set no page_size
+const defaultPageSize = 10
+
+// populateTableGroupMetrics walks once the tables of metric sets sharing the
+// same root OID and hands every row to each of them. The configured columns
+// are walked side by side in pages of the smallest page_size of the metric
+// sets, and every row is reported as soon as all of its columns have been
+// received, so memory use is bounded by a page of rows rather than by the
+// size of the table. The
+// fallback_oid of a metric is walked only in place of its column, on targets
+// having no value for it
+func populateTableGroupMetrics(client *gosnmp.GoSNMP, device string, metricSets []metricSet, entity *integration.Entity) error {
+	var consumers []*tableConsumer
+	pageSize := 0
+	collectAllColumns := false
+	//the metric sets sharing a walk wait for the longest of their timeouts
+	var timeout time.Duration
+	for _, metricSet := range metricSets {
+		consumers = append(consumers, &tableConsumer{device: device, metricSet: metricSet, entity: entity})
+		if metricSet.Timeout > timeout {
+			timeout = metricSet.Timeout
+		}
+		if metricSet.PageSize > 0 && (pageSize == 0 || metricSet.PageSize < pageSize) {
+			pageSize = metricSet.PageSize
+		}
+		collectAllColumns = collectAllColumns || metricSet.CollectAllColumns
+	}
+	defer setTimeout(client, timeout)()
+	stats, stopStats := startWalkStats(client, metricSets[0].RootOid)
 	var err error
+	if collectAllColumns {
+		err = populateWalkedTableMetrics(client, consumers, stats)
+	} else {
+		fallbacks := make(map[string]bool)
+		columns := walkedColumns(metricSets, func(column string) bool {
+			fallback, ok := fallbacks[column]
+			if !ok {
+				fallback = useFallback(client, column)
+				fallbacks[column] = fallback
+			}
+			return fallback
+		})
+		valued := make(map[string]bool)
+		walkStart := time.Now()
+		err = walkCachedColumns(client, metricSets, columns, pageSize, stats, func(row *tableRow) error {
+			for column, pdu := range row.pdus {
+				if !isNullPDU(pdu) {
+					valued[column] = true
+				}
+			}
+			for _, consumer := range consumers {
+				consumer.consume(row, nil)
+			}
+			return nil
+		})
+		for _, consumer := range consumers {
+			consumer.finish(time.Since(walkStart))
+		}
+		if err == nil {
+			forgetEmptyFallbackChoices(metricSets, fallbacks, valued)
+		}
+	}
+	stopStats()
+	reportWalkStats(device, metricSets, entity, stats)
+	return err
+}
+
+// walkCachedColumns walks the columns of a table like walkColumns, except for
+// the columns with a cache_ttl whose values were stored less than cache_ttl
+// ago: those are merged into the walked rows from the state store. The cached
+// columns that had to be walked are stored again once the walk succeeds
+func walkCachedColumns(client *gosnmp.GoSNMP, metricSets []metricSet, columns []string, pageSize int, stats *walkStats, rowFn func(row *tableRow) error) error {
+	cached := make(map[string]map[string]gosnmp.SnmpPDU)
+	refreshed := make(map[string]map[string]gosnmp.SnmpPDU)
+	var walked []string
+	for _, column := range columns {
+		ttl := columnCacheTTL(metricSets, column)
+		if ttl > 0 {
+			if pdus, ok := loadCachedColumn(column, ttl); ok {
+				cached[column] = pdus
+				continue
+			}
+			refreshed[column] = make(map[string]gosnmp.SnmpPDU)
+		}
+		walked = append(walked, column)
+	}
+
+	if len(walked) == 0 {
+		//every column is cached, rows are rebuilt from the cache alone
+		indexKeySet := make(map[string]bool)
+		for _, pdus := range cached {
+			for indexKey := range pdus {
+				indexKeySet[indexKey] = true
+			}
+		}
+		indexKeys := make([]string, 0, len(indexKeySet))
+		for indexKey := range indexKeySet {
+			indexKeys = append(indexKeys, indexKey)
+		}
+		sort.Slice(indexKeys, func(i, j int) bool {
+			return compareOids(indexKeys[i], indexKeys[j]) < 0
+		})
+		collectedAt := time.Now()
+		for _, indexKey := range indexKeys {
+			row := &tableRow{indexKey: indexKey, pdus: make(map[string]gosnmp.SnmpPDU), collectedAt: collectedAt}
+			for column, pdus := range cached {
+				if pdu, ok := pdus[indexKey]; ok {
+					row.pdus[column] = pdu
+				}
+			}
+			stats.rows++
+			if err := rowFn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 
-	tableRootOid := metricSet.RootOid
-	if len(metricSet.Index) == 0 {
-		return fmt.Errorf("Table index not specified for table OID `" + tableRootOid + "`")
+	err := walkColumns(client, client.MaxOids, walked, pageSize, stats, func(row *tableRow) error {
+		for column, pdus := range refreshed {
+			if pdu, ok := row.pdus[column]; ok {
+				pdus[row.indexKey] = pdu
+			}
+		}
+		for column, pdus := range cached {
+			if pdu, ok := pdus[row.indexKey]; ok {
+				row.pdus[column] = pdu
+			}
+		}
+		return rowFn(row)
+	})
+	if err != nil {
+		return err
 	}
+	for column, pdus := range refreshed {
+		storeCachedColumn(column, pdus)
+	}
+	return nil
+}
 
+// populateWalkedTableMetrics walks the whole subtree under the table root OID
+// before building any row. It is used when every column of the table must be
+// discovered, as the columns to walk side by side are not known upfront
+func populateWalkedTableMetrics(client bulkClient, consumers []*tableConsumer, stats *walkStats) error {
+	rootOid := stats.rootOid
 	metrics := make(map[string]gosnmp.SnmpPDU)
 	snmpWalkCallback := func(pdu gosnmp.SnmpPDU) error {
 		oid := strings.TrimSpace(pdu.Name)
@@ -27,84 +166,443 @@ func populateTableMetrics(device string, metricSet metricSet, entity *integratio
 			return fmt.Errorf("Error Message: %s", errorMessage)
 		}
 		metrics[oid] = pdu
+		stats.pdus++
 		return nil
 	}
 
-	err = theSNMP.BulkWalk(tableRootOid, snmpWalkCallback)
+	walkStart := time.Now()
+	err := client.BulkWalk(rootOid, snmpWalkCallback)
 	if err != nil {
 		return err
 	}
-
-	//an `index` uniquely identifies a row in an SNMP table.
-	//an `index key` is my term for the OID portion that is appended to the index OID and metric OID to produce SNMP table column data
-	//an `index key map` holds column data (as name-value pairs) for a certain row (aka index key)
-	//The `index key maps` map the row identifier (aka index key) to its column data (aka index key map)
-	indexKeyMaps := make(map[string]map[string]string)
-	for _, index := range metricSet.Index {
-		//Index OID + "." + Index Key = Index Value
-		indexKeyPattern := index.oid + "\\.(.*)"
-		re, err := regexp.Compile(indexKeyPattern)
-		if err != nil {
-			log.Error("unable to compile index key search pattern", err)
-			continue
-		}
-		for oid, pdu := range metrics {
-			matches := re.FindStringSubmatch(oid)
-			if len(matches) > 1 {
-				indexKey := matches[1]
-				indexValue, err := extractIndexValue(pdu)
+	//index columns of an augmented table live outside the root OID
+	for _, consumer := range consumers {
+		for _, index := range consumer.metricSet.Index {
+			if _, walked := metrics[index.oid]; !walked && !strings.HasPrefix(index.oid, rootOid+".") {
+				err = client.BulkWalk(index.oid, snmpWalkCallback)
 				if err != nil {
-					log.Error("unable to extract index value for ", indexKey, err)
-					continue
+					return err
 				}
-				indexMap, ok := indexKeyMaps[indexKey]
-				if !ok {
-					indexMap = make(map[string]string)
-					indexKeyMaps[indexKey] = indexMap
+			}
+		}
+	}
+	collectedAt := time.Now()
+
+	for _, consumer := range consumers {
+		metricSet := consumer.metricSet
+		//an `index` uniquely identifies a row in an SNMP table.
+		//an `index key` is my term for the OID portion that is appended to the index OID and metric OID to produce SNMP table column data
+		//rows are identified from the index and the metric columns, so a row is still reported with
+		//its raw `index` attribute when its index columns are missing or cannot be decoded
+		indexKeySet := make(map[string]bool)
+		for oid := range metrics {
+			//Column OID + "." + Index Key = Column Value
+			if _, indexKey, ok := metricSet.ColumnTree.match(oid); ok {
+				indexKeySet[indexKey] = true
+			}
+		}
+
+		indexKeys := make([]string, 0, len(indexKeySet))
+		for indexKey := range indexKeySet {
+			indexKeys = append(indexKeys, indexKey)
+		}
+		sort.Slice(indexKeys, func(i, j int) bool {
+			return compareOids(indexKeys[i], indexKeys[j]) < 0
+		})
+
+		var extraColumns []string
+		if metricSet.CollectAllColumns {
+			extraColumns = unconfiguredColumns(metricSet, metrics)
+		}
+		columns := append(tableColumns(metricSet), extraColumns...)
+
+		if len(indexKeys) > stats.rows {
+			stats.rows = len(indexKeys)
+		}
+		for _, indexKey := range indexKeys {
+			row := &tableRow{indexKey: indexKey, pdus: make(map[string]gosnmp.SnmpPDU), collectedAt: collectedAt}
+			for _, column := range columns {
+				if pdu, ok := metrics[column+"."+indexKey]; ok {
+					row.pdus[column] = pdu
 				}
-				indexMap[index.name] = indexValue
 			}
+			consumer.consume(row, extraColumns)
+		}
+		consumer.finish(collectedAt.Sub(walkStart))
+	}
+	return nil
+}
+
+// tableConsumer reports the rows of one metric set out of a walk that may
+// be shared with other metric sets of the same table
+type tableConsumer struct {
+	device     string
+	metricSet  metricSet
+	entity     *integration.Entity
+	rows       int
+	rowSets    []*metric.Set
+	pivot      []*pivotColumn
+	aggregates *tableAggregates
+}
+
+// consume reports a row unless it is filtered out or over the max_rows limit
+func (c *tableConsumer) consume(row *tableRow, extraColumns []string) {
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	if !c.metricSet.RowFilter.matches(row.indexKey) {
+		return
+	}
+	//aggregates cover the rows over the max_rows limit too
+	if len(c.metricSet.Aggregates) > 0 {
+		if c.aggregates == nil {
+			c.aggregates = newTableAggregates(c.metricSet)
+		}
+		c.aggregates.add(c.metricSet, row)
+	}
+	c.rows++
+	if c.metricSet.MaxRows > 0 && c.rows > c.metricSet.MaxRows {
+		return
+	}
+	if c.metricSet.Output == outputPivot {
+		if c.pivot == nil {
+			c.pivot = newPivotColumns(c.metricSet)
+		}
+		rowId := pivotRowId(c.metricSet, row)
+		for _, column := range c.pivot {
+			column.add(rowId, row)
+		}
+		return
+	}
+	c.rowSets = append(c.rowSets, emitTableRow(c.device, c.metricSet, c.entity, row, extraColumns))
+}
+
+// finish completes the reported rows once the walk is over
+func (c *tableConsumer) finish(walkDuration time.Duration) {
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	for _, column := range c.pivot {
+		c.rowSets = append(c.rowSets, column.emit(c.device, c.metricSet, c.entity))
+	}
+	if len(c.metricSet.Aggregates) > 0 {
+		if c.aggregates == nil {
+			c.aggregates = newTableAggregates(c.metricSet)
 		}
+		c.rowSets = append(c.rowSets, c.aggregates.emit(c.device, c.metricSet, c.entity))
 	}
+	setWalkDuration(c.rowSets, walkDuration)
+	if c.metricSet.MaxRows > 0 && c.rows > c.metricSet.MaxRows {
+		log.Warn("table [%s] returned %d rows, only the first %d will be reported", c.metricSet.Name, c.rows, c.metricSet.MaxRows)
+		reportTruncation(c.device, c.metricSet, c.entity, c.rows-c.metricSet.MaxRows)
+	}
+}
+
+// tableColumns returns the distinct column OIDs of the index and metric definitions
+func tableColumns(metricSet metricSet) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, index := range metricSet.Index {
+		if !seen[index.oid] {
+			seen[index.oid] = true
+			columns = append(columns, index.oid)
+		}
+	}
+	for _, metric := range metricSet.Metrics {
+		for _, oid := range []string{strings.TrimSpace(metric.oid), metric.fallbackOid} {
+			if oid != "" && !seen[oid] {
+				seen[oid] = true
+				columns = append(columns, oid)
+			}
+		}
+	}
+	if oid := metricSet.DiscontinuityOid; oid != "" && !seen[oid] {
+		columns = append(columns, oid)
+	}
+	return columns
+}
+
+// walkedColumns returns the distinct columns walked for the metric sets
+// sharing a table: their index columns, the column of each metric, or its
+// fallback_oid in its place when useFallback says the target has no value
+// for it, and their discontinuity columns
+func walkedColumns(metricSets []metricSet, useFallback func(column string) bool) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	add := func(column string) {
+		if column != "" && !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+	for _, metricSet := range metricSets {
+		for _, index := range metricSet.Index {
+			add(index.oid)
+		}
+		for _, metric := range metricSet.Metrics {
+			column := strings.TrimSpace(metric.oid)
+			if metric.fallbackOid != "" && useFallback(column) {
+				column = metric.fallbackOid
+			}
+			add(column)
+		}
+		add(metricSet.DiscontinuityOid)
+	}
+	return columns
+}
+
+// columnProber sends the GETNEXT probing a table column
+type columnProber interface {
+	GetNext(oids []string) (*gosnmp.SnmpPacket, error)
+}
 
-	for indexKey, indexNVPairs := range indexKeyMaps {
-		ms := entity.NewMetricSet(metricSet.EventType, metric.Attr("IntegrationVersion", integrationVersion))
-		err = ms.SetMetric("device", device, metric.ATTRIBUTE)
+// useFallback tells whether the fallback_oid of the metrics reading column
+// must be walked in its place, because the target has no row or only null
+// values for it. The first row of the column is probed with a GETNEXT, and
+// the answer is remembered per device in the state store so that the probe
+// is only sent again once the state expires or the column stops answering
+func useFallback(client columnProber, column string) bool {
+	if answered, ok := loadColumnAnswered(column); ok {
+		return !answered
+	}
+	response, err := client.GetNext([]string{column})
+	if err != nil {
+		log.Warn("unable to probe column %s of target %s, walking it: %v", column, targetHost, err)
+		return false
+	}
+	answered := false
+	if response.Error == gosnmp.NoError && len(response.Variables) > 0 {
+		pdu := response.Variables[0]
+		answered = strings.HasPrefix(strings.TrimSpace(pdu.Name), column+".") && pdu.Type != gosnmp.EndOfMibView && !isNullPDU(pdu)
+	}
+	if !answered {
+		log.Debug("column %s has no value on target %s, walking its fallback_oid", column, targetHost)
+	}
+	storeColumnAnswered(column, answered)
+	return !answered
+}
+
+// forgetEmptyFallbackChoices has the columns having a fallback_oid probed
+// again on the next run when the column walked in their place returned no
+// value, so that a target starting to answer the column is noticed
+func forgetEmptyFallbackChoices(metricSets []metricSet, fallbacks map[string]bool, valued map[string]bool) {
+	for _, metricSet := range metricSets {
+		for _, metric := range metricSet.Metrics {
+			if metric.fallbackOid == "" {
+				continue
+			}
+			column := strings.TrimSpace(metric.oid)
+			walked := column
+			if fallbacks[column] {
+				walked = metric.fallbackOid
+			}
+			if !valued[walked] {
+				forgetColumnAnswered(column)
+			}
+		}
+	}
+}
+
+// emitTableRow creates the metric set of a single table row
+func emitTableRow(device string, metricSet metricSet, entity *integration.Entity, row *tableRow, extraColumns []string) *metric.Set {
+	ms := entity.NewMetricSet(metricSet.EventType, metric.Attr("IntegrationVersion", integrationVersion))
+	err := ms.SetMetric("device", device, metric.ATTRIBUTE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("name", metricSet.Name, metric.ATTRIBUTE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	indexValues := rowIndexValues(metricSet, row)
+	//row tags are set first so the values read from the device take precedence
+	for n, v := range metricSet.RowTags.lookup(row.indexKey, indexValues) {
+		err = ms.SetMetric(n, v, metric.ATTRIBUTE)
 		if err != nil {
 			log.Error(err.Error())
 		}
-		err = ms.SetMetric("name", metricSet.Name, metric.ATTRIBUTE)
+	}
+	for n, v := range indexValues {
+		err = ms.SetMetric(n, v, metric.ATTRIBUTE)
 		if err != nil {
 			log.Error(err.Error())
 		}
-		err = ms.SetMetric("index", indexKey, metric.ATTRIBUTE)
+	}
+	if metricSet.IndexTemplate != nil {
+		err = ms.SetMetric(metricSet.IndexTemplate.name, metricSet.IndexTemplate.render(indexValues), metric.ATTRIBUTE)
 		if err != nil {
 			log.Error(err.Error())
 		}
-		for n, v := range indexNVPairs {
-			err = ms.SetMetric(n, v, metric.ATTRIBUTE)
+	}
+	//the raw index key is always reported, after the named index values so it can't be overridden
+	err = ms.SetMetric("index", row.indexKey, metric.ATTRIBUTE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("collectedAt", row.collectedAt.UnixNano()/int64(time.Millisecond), metric.GAUGE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	if metricSet.DiscontinuityOid != "" {
+		resetRowSamples(metricSet, row)
+	}
+	values := make(map[string]float64)
+	for _, metric := range metricSet.Metrics {
+		baseOid := strings.TrimSpace(metric.oid)
+		metricName := metric.metricName
+		oid := baseOid + "." + row.indexKey
+		if metricName == "" {
+			metricName = mibs.objectName(oid)
+		}
+		pdu, ok := row.pdus[baseOid]
+		if (!ok || isNullPDU(pdu)) && metric.fallbackOid != "" {
+			pdu, ok = row.pdus[metric.fallbackOid]
+		}
+		if ok && !isNullPDU(pdu) {
+			if value, numeric := metric.numericValue(pdu); numeric {
+				values[metricName] = value
+				values[baseOid] = value
+			}
+			if metric.hidden {
+				continue
+			}
+			err = setMetric(metric, metricName, pdu, ms)
+		} else if metric.hidden {
+			continue
+		} else {
+			if metric.nullPolicy == nullSkip && metric.format != "present" {
+				log.Warn("No data for " + oid)
+			}
+			err = setNullMetric(metric, metricName, ms)
+		}
+		if err != nil {
+			log.Error(err.Error())
+		}
+	}
+	setDerivedMetrics(metricSet.Derived, values, metricSet.Name+"."+row.indexKey, ms)
+	for _, column := range extraColumns {
+		if pdu, ok := row.pdus[column]; ok {
+			metricName, metricType := column, unset
+			if node := mibs.node(column); node != nil {
+				metricName, metricType = node.name, mibs.mibMetricType(node)
+			}
+			err = createMetric(metricName, metricType, pdu, ms)
 			if err != nil {
 				log.Error(err.Error())
 			}
 		}
-		for _, metric := range metricSet.Metrics {
-			baseOid := strings.TrimSpace(metric.oid)
-			metricName := metric.metricName
-			oid := baseOid + "." + indexKey
-			if pdu, ok := metrics[oid]; ok {
-				if metricName == "" {
-					metricName = oid
-				}
-				err = createMetric(metricName, metric.metricType, pdu, ms)
-				if err != nil {
-					log.Error(err.Error())
-				}
-			} else {
-				log.Warn("No data for " + oid)
-			}
+	}
+	setTemplateAttributes(metricSet.Attributes, ms)
+	return ms
+}
+
+// rowIndexValues decodes the index components of a row and reads the values
+// of its index columns, keyed by index name
+func rowIndexValues(metricSet metricSet, row *tableRow) map[string]string {
+	indexValues := make(map[string]string)
+	if len(metricSet.IndexComponents) > 0 {
+		components, err := decodeIndexComponents(metricSet.IndexComponents, row.indexKey)
+		if err != nil {
+			log.Warn(err.Error())
+		}
+		for n, v := range components {
+			indexValues[n] = v
 		}
 	}
-	return nil
+	for _, index := range metricSet.Index {
+		pdu, ok := row.pdus[index.oid]
+		if !ok {
+			continue
+		}
+		var indexValue string
+		var err error
+		if index.transform != nil {
+			indexValue, err = index.transform(pdu)
+		} else {
+			indexValue, err = extractIndexValue(pdu)
+		}
+		if err != nil {
+			log.Error("unable to extract index value for %s: %v", row.indexKey, err)
+			continue
+		}
+		indexValues[index.name] = indexValue
+	}
+	return indexValues
+}
+
+// setWalkDuration records on every row of a table how long the walk that
+// produced it took, once the walk is over
+func setWalkDuration(rowSets []*metric.Set, duration time.Duration) {
+	for _, ms := range rowSets {
+		err := ms.SetMetric("walkDurationMs", duration.Seconds()*1000, metric.GAUGE)
+		if err != nil {
+			log.Error(err.Error())
+		}
+	}
+}
+
+// unconfiguredColumns returns, in OID order, the column OIDs present in the walk
+// results that are neither an index nor a configured metric of the metric set.
+// Columns are assumed to have the same depth as the first index or metric column
+func unconfiguredColumns(metricSet metricSet, metrics map[string]gosnmp.SnmpPDU) []string {
+	configured := make(map[string]bool)
+	for _, index := range metricSet.Index {
+		configured[normalizeOid(index.oid)] = true
+	}
+	for _, metric := range metricSet.Metrics {
+		configured[normalizeOid(metric.oid)] = true
+		if metric.fallbackOid != "" {
+			configured[metric.fallbackOid] = true
+		}
+	}
+	if metricSet.DiscontinuityOid != "" {
+		configured[metricSet.DiscontinuityOid] = true
+	}
+	var depth int
+	if len(metricSet.Index) > 0 {
+		depth = len(oidArcs(metricSet.Index[0].oid))
+	} else {
+		depth = len(oidArcs(metricSet.Metrics[0].oid))
+	}
+
+	seen := make(map[string]bool)
+	var columns []string
+	for oid := range metrics {
+		arcs := oidArcs(oid)
+		if len(arcs) <= depth {
+			continue
+		}
+		column := "." + strings.Join(arcs[:depth], ".")
+		if configured[column] || seen[column] {
+			continue
+		}
+		seen[column] = true
+		columns = append(columns, column)
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		return compareOids(columns[i], columns[j]) < 0
+	})
+	return columns
+}
+
+// reportTruncation emits a metric set recording how many rows of a table
+// were dropped because the table exceeded the configured max_rows
+func reportTruncation(device string, metricSet metricSet, entity *integration.Entity, truncated int) {
+	ms := entity.NewMetricSet(metricSet.EventType, metric.Attr("IntegrationVersion", integrationVersion))
+	err := ms.SetMetric("device", device, metric.ATTRIBUTE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("name", metricSet.Name, metric.ATTRIBUTE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("maxRows", metricSet.MaxRows, metric.GAUGE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("rowsTruncated", truncated, metric.GAUGE)
+	if err != nil {
+		log.Error(err.Error())
+	}
 }
 
 func extractIndexValue(pdu gosnmp.SnmpPDU) (string, error) {
@@ -112,10 +610,10 @@ func extractIndexValue(pdu gosnmp.SnmpPDU) (string, error) {
 	switch pdu.Type {
 	case gosnmp.OctetString:
 		if v, ok := pdu.Value.([]byte); ok {
-			indexValue = string(v)
+			indexValue = validUTF8(trimString(string(v)))
 			return indexValue, nil
 		}
-		return "", fmt.Errorf("unable to assert OctetString as []byte, Oid[" + pdu.Name + "]")
+		return "", fmt.Errorf("unable to assert OctetString as []byte, Oid[%s]", pdu.Name)
 	case gosnmp.Gauge32, gosnmp.Counter32, gosnmp.Counter64, gosnmp.Integer, gosnmp.Uinteger32:
 		indexValue = gosnmp.ToBigInt(pdu.Value).String()
 		return indexValue, nil
@@ -124,7 +622,7 @@ func extractIndexValue(pdu gosnmp.SnmpPDU) (string, error) {
 			indexValue = v
 			return indexValue, nil
 		}
-		return "", fmt.Errorf("unable to assert ObjectIdentifier or IPAddress as string, Oid[" + pdu.Name + "]")
+		return "", fmt.Errorf("unable to assert ObjectIdentifier or IPAddress as string, Oid[%s]", pdu.Name)
 	case gosnmp.Boolean:
 		return "", fmt.Errorf("unsupported PDU type[Boolean] for index")
 	case gosnmp.BitString:
@@ -136,7 +634,7 @@ func extractIndexValue(pdu gosnmp.SnmpPDU) (string, error) {
 	case gosnmp.OpaqueDouble:
 		return fmt.Sprintf("%f", pdu.Value.(float64)), nil
 	case gosnmp.Null:
-		return "", fmt.Errorf("null value for table index: [" + pdu.Name + "]")
+		return "", fmt.Errorf("null value for table index: [%s]", pdu.Name)
 	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance:
 		return "", fmt.Errorf("no such table index: [%v]", pdu.Name)
 	default:
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/integration"
+	"github.com/newrelic/infra-integrations-sdk/persist"
+	"github.com/soniah/gosnmp"
+)
+
+func TestTableConsumerMaxRows(t *testing.T) {
+	i, err := integration.New("test", "0.0.0", integration.InMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity := i.LocalEntity()
+	speed := &metricDef{oid: ".1.3.6.1.2.1.2.2.1.5", metricName: "ifSpeed", metricType: gauge}
+	metricSet := metricSet{Name: "interfaces", EventType: "SNMPSample", Metrics: []*metricDef{speed}, MaxRows: 2}
+	consumer := &tableConsumer{device: "router", metricSet: metricSet, entity: entity}
+	for _, indexKey := range []string{"1", "2", "3", "4", "5"} {
+		consumer.consume(&tableRow{indexKey: indexKey, pdus: map[string]gosnmp.SnmpPDU{
+			speed.oid: {Name: speed.oid + "." + indexKey, Type: gosnmp.Gauge32, Value: uint(100)},
+		}}, nil)
+	}
+	consumer.finish(time.Second)
+
+	var indexes []interface{}
+	truncations := 0
+	for _, ms := range entity.Metrics {
+		if truncated, ok := ms.Metrics["rowsTruncated"]; ok {
+			truncations++
+			if truncated != 3.0 || ms.Metrics["maxRows"] != 2.0 || ms.Metrics["name"] != "interfaces" {
+				t.Errorf("unexpected truncation report %v", ms.Metrics)
+			}
+			continue
+		}
+		indexes = append(indexes, ms.Metrics["index"])
+	}
+	if !reflect.DeepEqual(indexes, []interface{}{"1", "2"}) {
+		t.Errorf("expected the first 2 rows alone, got %v", indexes)
+	}
+	if truncations != 1 {
+		t.Errorf("expected the truncation to be reported once, got %d reports", truncations)
+	}
+}
+
+func TestCollectAllColumnsNames(t *testing.T) {
+	stateStore = persist.NewInMemoryStore()
+	defer func() { stateStore = nil }()
+	agent := newFakeIfTable()
+	agent.pdus = append(agent.pdus, gosnmp.SnmpPDU{Name: testIfOperStatus + ".1", Type: gosnmp.Integer, Value: 1})
+	descr := &metricDef{oid: testIfDescr, metricName: "description", metricType: attribute}
+	metricSets := []metricSet{{Name: "interfaces", Type: "table", EventType: "SNMPSample", RootOid: ".1.3.6.1.2.1.2.2", Metrics: []*metricDef{descr}, CollectAllColumns: true}}
+	buildColumnTrees(metricSets)
+	metricSet := metricSets[0]
+
+	cases := []struct {
+		mibs       *mibRegistry
+		operStatus string
+	}{
+		{nil, testIfOperStatus},
+		{loadTestMib(t), "ifOperStatus"},
+	}
+	for _, c := range cases {
+		mibs = c.mibs
+		i, err := integration.New("test", "0.0.0", integration.InMemoryStore())
+		if err != nil {
+			t.Fatal(err)
+		}
+		entity := i.LocalEntity()
+		consumer := &tableConsumer{device: "router", metricSet: metricSet, entity: entity}
+		if err := populateWalkedTableMetrics(agent, []*tableConsumer{consumer}, &walkStats{rootOid: metricSet.RootOid}); err != nil {
+			t.Fatal(err)
+		}
+		if len(entity.Metrics) != 5 {
+			t.Fatalf("expected 5 rows, got %d", len(entity.Metrics))
+		}
+		row := entity.Metrics[0]
+		if row.Metrics["index"] != "1" || row.Metrics["description"] != "eth1" {
+			t.Errorf("unexpected configured columns %v", row.Metrics)
+		}
+		if row.Metrics[c.operStatus] != 1.0 {
+			t.Errorf("expected the unconfigured column reported as %s, got %v", c.operStatus, row.Metrics)
+		}
+		if _, ok := row.Metrics[testIfDescr]; ok {
+			t.Errorf("configured column reported again under its OID: %v", row.Metrics)
+		}
+	}
+	mibs = nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math/big"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/integration"
+	"github.com/newrelic/infra-integrations-sdk/log"
+	"github.com/soniah/gosnmp"
+)
+
+// table output shapes accepted in yaml
+const (
+	outputRows  = "rows"
+	outputPivot = "pivot"
+)
+
+// pivotColumn aggregates the values of one numeric column across the rows of a table
+type pivotColumn struct {
+	columnSummary
+	def    *metricDef
+	name   string
+	rowIds []string
+	values map[string]float64
+}
+
+// newPivotColumns prepares the aggregation of every metric column of a table
+func newPivotColumns(metricSet metricSet) []*pivotColumn {
+	columns := make([]*pivotColumn, 0, len(metricSet.Metrics))
+	for _, def := range metricSet.Metrics {
+		if def.hidden {
+			continue
+		}
+		name := def.metricName
+		if name == "" {
+			name = mibs.objectName(def.oid)
+		}
+		columns = append(columns, &pivotColumn{columnSummary: newColumnSummary(), def: def, name: name, values: make(map[string]float64)})
+	}
+	return columns
+}
+
+// add accumulates the value of the column in the row identified by rowId.
+// Non numeric values are ignored
+func (c *pivotColumn) add(rowId string, row *tableRow) {
+	pdu, ok := row.pdus[c.def.oid]
+	if (!ok || isNullPDU(pdu)) && c.def.fallbackOid != "" {
+		pdu, ok = row.pdus[c.def.fallbackOid]
+	}
+	if !ok {
+		return
+	}
+	value, ok := pduFloat(c.def.unpack(pdu))
+	if !ok {
+		log.Debug("ignoring non numeric value of %s in pivoted table", pdu.Name)
+		return
+	}
+	c.columnSummary.add(value)
+	if _, seen := c.values[rowId]; !seen {
+		c.rowIds = append(c.rowIds, rowId)
+	}
+	c.values[rowId] = value
+}
+
+// emit reports the column as a metric set of its own, holding the summary of
+// the column and its value in every row as `row.<row id>`
+func (c *pivotColumn) emit(device string, metricSet metricSet, entity *integration.Entity) *metric.Set {
+	ms := entity.NewMetricSet(metricSet.EventType, metric.Attr("IntegrationVersion", integrationVersion), metric.Attr("column", c.name))
+	err := ms.SetMetric("device", device, metric.ATTRIBUTE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("name", metricSet.Name, metric.ATTRIBUTE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("rows", c.count, metric.GAUGE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	if c.count == 0 {
+		return ms
+	}
+	err = setSampledMetric(ms, "sum", metricSet.Name+"/"+c.name, c.sum, sampledType(c.def.metricType))
+	if err != nil {
+		log.Error(err.Error())
+	}
+	for _, name := range []string{"min", "max", "avg"} {
+		value := c.value(name)
+		err = ms.SetMetric(name, value, metric.GAUGE)
+		if err != nil {
+			log.Error(err.Error())
+		}
+		roundMetric(ms, name, args.FloatPrecision)
+	}
+	roundMetric(ms, "sum", args.FloatPrecision)
+	for _, rowId := range c.rowIds {
+		err = ms.SetMetric("row."+rowId, c.values[rowId], metric.GAUGE)
+		if err != nil {
+			log.Error(err.Error())
+		}
+	}
+	return ms
+}
+
+// pivotRowId identifies a row in pivoted output by its index template,
+// falling back to the raw index key
+func pivotRowId(metricSet metricSet, row *tableRow) string {
+	if metricSet.IndexTemplate != nil {
+		if id := metricSet.IndexTemplate.render(rowIndexValues(metricSet, row)); id != "" {
+			return id
+		}
+	}
+	return row.indexKey
+}
+
+// pduFloat returns the numeric value of a PDU
+func pduFloat(pdu gosnmp.SnmpPDU) (float64, bool) {
+	switch pdu.Type {
+	case gosnmp.Gauge32, gosnmp.Counter32, gosnmp.Counter64, gosnmp.Integer, gosnmp.Uinteger32:
+		value, _ := new(big.Float).SetInt(gosnmp.ToBigInt(pdu.Value)).Float64()
+		return value, true
+	case gosnmp.TimeTicks:
+		return float64(gosnmp.ToBigInt(pdu.Value).Uint64()) / 100, true
+	case gosnmp.OpaqueFloat, gosnmp.OpaqueDouble:
+		value, err := opaqueValue(pdu)
+		return value, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/integration"
+	"github.com/soniah/gosnmp"
+)
+
+func TestPivotColumnSummary(t *testing.T) {
+	args.FloatPrecision = -1
+	defer func() { args = argumentList{} }()
+	i, err := integration.New("test", "0.0.0", integration.InMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity := i.LocalEntity()
+	speed := &metricDef{oid: ".1.3.6.1.2.1.2.2.1.5", metricName: "ifSpeed", metricType: gauge}
+	descr := &metricDef{oid: ".1.3.6.1.2.1.2.2.1.2", metricName: "ifDescr", metricType: attribute, hidden: true}
+	metricSet := metricSet{Name: "interfaces", EventType: "SNMPSample", Metrics: []*metricDef{speed, descr}}
+	columns := newPivotColumns(metricSet)
+	if len(columns) != 1 || columns[0].name != "ifSpeed" {
+		t.Fatalf("expected the ifSpeed column alone, hidden columns are not pivoted")
+	}
+	rows := map[string]gosnmp.SnmpPDU{
+		"1": {Name: speed.oid + ".1", Type: gosnmp.Gauge32, Value: uint(100)},
+		"2": {Name: speed.oid + ".2", Type: gosnmp.Gauge32, Value: uint(1000)},
+		"3": {Name: speed.oid + ".3", Type: gosnmp.OctetString, Value: []byte("n/a")},
+		"4": {Name: speed.oid + ".4", Type: gosnmp.Gauge32, Value: uint(10)},
+	}
+	for _, rowId := range []string{"1", "2", "3", "4"} {
+		columns[0].add(rowId, &tableRow{indexKey: rowId, pdus: map[string]gosnmp.SnmpPDU{speed.oid: rows[rowId]}})
+	}
+	//a row without the column is not counted
+	columns[0].add("5", &tableRow{indexKey: "5", pdus: map[string]gosnmp.SnmpPDU{}})
+
+	ms := columns[0].emit("router", metricSet, entity)
+	expected := map[string]interface{}{
+		"rows":       3.0,
+		"sum":        1110.0,
+		"min":        10.0,
+		"max":        1000.0,
+		"avg":        370.0,
+		"row.1":      100.0,
+		"row.2":      1000.0,
+		"row.4":      10.0,
+		"column":     "ifSpeed",
+		"device":     "router",
+		"name":       "interfaces",
+		"event_type": "SNMPSample",
+	}
+	for name, value := range expected {
+		if !reflect.DeepEqual(ms.Metrics[name], value) {
+			t.Errorf("%s = %#v, expected %#v", name, ms.Metrics[name], value)
+		}
+	}
+	if _, ok := ms.Metrics["row.3"]; ok {
+		t.Error("a non numeric value was pivoted")
+	}
+	if !reflect.DeepEqual(columns[0].rowIds, []string{"1", "2", "4"}) {
+		t.Errorf("rows reported in order %v", columns[0].rowIds)
+	}
+}
+
+func TestPivotColumnWithoutRows(t *testing.T) {
+	i, err := integration.New("test", "0.0.0", integration.InMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	metricSet := metricSet{Name: "interfaces", EventType: "SNMPSample", Metrics: []*metricDef{{oid: ".1.3.6.1.2.1.2.2.1.5", metricName: "ifSpeed", metricType: gauge}}}
+	ms := newPivotColumns(metricSet)[0].emit("router", metricSet, i.LocalEntity())
+	if ms.Metrics["rows"] != 0.0 {
+		t.Errorf("rows = %v, expected 0", ms.Metrics["rows"])
+	}
+	for _, name := range []string{"sum", "min", "max", "avg"} {
+		if _, ok := ms.Metrics[name]; ok {
+			t.Errorf("%s reported for a column without values", name)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/log"
+	"github.com/soniah/gosnmp"
+)
+
+// tableRow holds the column values of a single table row, keyed by column OID
+type tableRow struct {
+	indexKey    string
+	pdus        map[string]gosnmp.SnmpPDU
+	collectedAt time.Time
+}
+
+// bulkClient sends the requests tables are walked with, as gosnmp.GoSNMP does
+type bulkClient interface {
+	GetBulk(oids []string, nonRepeaters uint8, maxRepetitions uint8) (*gosnmp.SnmpPacket, error)
+	BulkWalk(rootOid string, walkFn gosnmp.WalkFunc) error
+}
+
+// walkColumns walks the given columns of a table side by side and hands their
+// rows to rowFn in index order. Each GETBULK requests pageSize rows of every
+// column, defaultPageSize without a pageSize, and at most maxOids columns.
+// Rows are handed to rowFn as soon as every column still being walked has
+// moved past them, so only the rows of the current page are held in memory.
+// When the agent answers that a page is too big, the columns still being
+// walked are finished with BulkWalk instead
+func walkColumns(client bulkClient, maxOids int, columns []string, pageSize int, stats *walkStats, rowFn func(row *tableRow) error) error {
+	pending := make(map[string]*tableRow)
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > 255 {
+		pageSize = 255
+	}
+	if maxOids <= 0 {
+		maxOids = gosnmp.MaxOids
+	}
+	cursors := make([]string, len(columns))
+	copy(cursors, columns)
+	active := make([]bool, len(columns))
+	for i := range active {
+		active[i] = true
+	}
+	// lastKeys holds the last index key reached by each column, "" until the first response
+	lastKeys := make([]string, len(columns))
+
+	for {
+		var requested []int
+		for i := range columns {
+			if active[i] {
+				requested = append(requested, i)
+			}
+		}
+		if len(requested) == 0 {
+			break
+		}
+
+		//a page of more than maxOids columns takes one GETBULK per chunk of columns
+		for len(requested) > 0 {
+			chunk := requested
+			if len(chunk) > maxOids {
+				chunk = chunk[:maxOids]
+			}
+			requested = requested[len(chunk):]
+			oids := make([]string, len(chunk))
+			for i, c := range chunk {
+				oids[i] = cursors[c]
+			}
+
+			response, err := client.GetBulk(oids, 0, uint8(pageSize))
+			if err != nil {
+				return err
+			}
+			if response.Error == gosnmp.TooBig {
+				log.Debug("page of %d rows of %d columns too big for the agent, walking the rest of the table with BulkWalk", pageSize, len(chunk))
+				var rest, after []string
+				for c := range columns {
+					if active[c] {
+						rest = append(rest, columns[c])
+						after = append(after, lastKeys[c])
+					}
+				}
+				if err := bulkWalkColumns(client, rest, after, pending, stats); err != nil {
+					return err
+				}
+				return flushRows(pending, "", false, rowFn)
+			}
+			if response.Error != gosnmp.NoError && response.Error != gosnmp.NoSuchName {
+				return fmt.Errorf("%s: %s", getErrorCode(response.Error), getErrorMessage(response.Error))
+			}
+			if response.Error == gosnmp.NoSuchName || len(response.Variables) == 0 {
+				for _, c := range chunk {
+					active[c] = false
+				}
+				continue
+			}
+
+			for i, pdu := range response.Variables {
+				c := chunk[i%len(chunk)]
+				if !active[c] {
+					continue
+				}
+				oid := strings.TrimSpace(pdu.Name)
+				if errorMessage, ok := knownErrorOids[oid]; ok {
+					return fmt.Errorf("Error Message: %s", errorMessage)
+				}
+				column := columns[c]
+				if pdu.Type == gosnmp.EndOfMibView || !strings.HasPrefix(oid, column+".") {
+					active[c] = false
+					continue
+				}
+				if oid == cursors[c] {
+					return fmt.Errorf("OID not increasing: %s", oid)
+				}
+				cursors[c] = oid
+				indexKey := oid[len(column)+1:]
+				lastKeys[c] = indexKey
+				pendingRow(pending, indexKey, stats).pdus[column] = pdu
+				stats.pdus++
+			}
+		}
+
+		// a row is complete once every column still being walked has reached or passed it
+		boundary := ""
+		bounded := false
+		for c := range columns {
+			if !active[c] {
+				continue
+			}
+			if lastKeys[c] == "" {
+				boundary, bounded = "", true
+				break
+			}
+			if !bounded || compareOids(lastKeys[c], boundary) < 0 {
+				boundary, bounded = lastKeys[c], true
+			}
+		}
+		if err := flushRows(pending, boundary, bounded, rowFn); err != nil {
+			return err
+		}
+	}
+	return flushRows(pending, "", false, rowFn)
+}
+
+// bulkWalkColumns walks every column with BulkWalk into the pending rows.
+// When after is given, the rows of each column up to the index key after it
+// were already received and are skipped
+func bulkWalkColumns(client bulkClient, columns []string, after []string, pending map[string]*tableRow, stats *walkStats) error {
+	for i, column := range columns {
+		err := client.BulkWalk(column, func(pdu gosnmp.SnmpPDU) error {
+			oid := strings.TrimSpace(pdu.Name)
+			if errorMessage, ok := knownErrorOids[oid]; ok {
+				return fmt.Errorf("Error Message: %s", errorMessage)
+			}
+			if !strings.HasPrefix(oid, column+".") {
+				return nil
+			}
+			indexKey := oid[len(column)+1:]
+			if after != nil && after[i] != "" && compareOids(indexKey, after[i]) <= 0 {
+				return nil
+			}
+			pendingRow(pending, indexKey, stats).pdus[column] = pdu
+			stats.pdus++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pendingRow returns the pending row of an index key, adding it on its first column
+func pendingRow(pending map[string]*tableRow, indexKey string, stats *walkStats) *tableRow {
+	row, ok := pending[indexKey]
+	if !ok {
+		row = &tableRow{indexKey: indexKey, pdus: make(map[string]gosnmp.SnmpPDU), collectedAt: time.Now()}
+		pending[indexKey] = row
+		stats.rows++
+	}
+	return row
+}
+
+// flushRows hands the pending rows up to and including boundary to rowFn and
+// removes them. When bounded is false every pending row is flushed
+func flushRows(pending map[string]*tableRow, boundary string, bounded bool, rowFn func(row *tableRow) error) error {
+	var keys []string
+	for indexKey := range pending {
+		if !bounded || (boundary != "" && compareOids(indexKey, boundary) <= 0) {
+			keys = append(keys, indexKey)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return compareOids(keys[i], keys[j]) < 0
+	})
+	for _, indexKey := range keys {
+		row := pending[indexKey]
+		delete(pending, indexKey)
+		if err := rowFn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/soniah/gosnmp"
+)
+
+const (
+	testIfDescr      = ".1.3.6.1.2.1.2.2.1.2"
+	testIfOperStatus = ".1.3.6.1.2.1.2.2.1.8"
+	testIfInOctets   = ".1.3.6.1.2.1.2.2.1.10"
+)
+
+// fakeAgent answers GETBULK requests and walks from a sorted list of PDUs
+type fakeAgent struct {
+	pdus     []gosnmp.SnmpPDU
+	requests int
+	// maxRepetitions is the max-repetitions of the last GETBULK
+	maxRepetitions uint8
+	// tooBig is the number of the first GETBULK answered with a tooBig error, 0 for none
+	tooBig int
+}
+
+// newFakeIfTable returns an agent holding the ifDescr and ifInOctets columns
+// of 5 interfaces. ifInOctets has no row 3 and is the last column of the agent
+func newFakeIfTable() *fakeAgent {
+	agent := &fakeAgent{}
+	for _, index := range []string{"1", "2", "3", "10", "11"} {
+		agent.pdus = append(agent.pdus, gosnmp.SnmpPDU{Name: testIfDescr + "." + index, Type: gosnmp.OctetString, Value: []byte("eth" + index)})
+	}
+	for _, index := range []string{"1", "2", "10", "11"} {
+		agent.pdus = append(agent.pdus, gosnmp.SnmpPDU{Name: testIfInOctets + "." + index, Type: gosnmp.Counter32, Value: uint(100)})
+	}
+	return agent
+}
+
+// next returns the PDU following oid, or endOfMibView past the last one
+func (a *fakeAgent) next(oid string) gosnmp.SnmpPDU {
+	for _, pdu := range a.pdus {
+		if compareOids(pdu.Name, oid) > 0 {
+			return pdu
+		}
+	}
+	return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.EndOfMibView}
+}
+
+func (a *fakeAgent) GetBulk(oids []string, nonRepeaters uint8, maxRepetitions uint8) (*gosnmp.SnmpPacket, error) {
+	a.requests++
+	a.maxRepetitions = maxRepetitions
+	if a.requests == a.tooBig {
+		return &gosnmp.SnmpPacket{Error: gosnmp.TooBig}, nil
+	}
+	cursors := append([]string(nil), oids...)
+	response := &gosnmp.SnmpPacket{}
+	for r := 0; r < int(maxRepetitions); r++ {
+		for i := range cursors {
+			pdu := a.next(cursors[i])
+			cursors[i] = pdu.Name
+			response.Variables = append(response.Variables, pdu)
+		}
+	}
+	return response, nil
+}
+
+func (a *fakeAgent) BulkWalk(rootOid string, walkFn gosnmp.WalkFunc) error {
+	a.requests++
+	for _, pdu := range a.pdus {
+		if oidHasPrefix(pdu.Name, rootOid) {
+			if err := walkFn(pdu); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// walkFakeIfTable walks the table of the agent and returns the index keys of
+// the rows in the order they were handed over, and the number of requests
+// sent when each of them was
+func walkFakeIfTable(t *testing.T, agent *fakeAgent, maxOids int, pageSize int) ([]string, []int) {
+	var indexKeys []string
+	var requests []int
+	stats := &walkStats{}
+	err := walkColumns(agent, maxOids, []string{testIfDescr, testIfInOctets}, pageSize, stats, func(row *tableRow) error {
+		indexKeys = append(indexKeys, row.indexKey)
+		requests = append(requests, agent.requests)
+		if _, ok := row.pdus[testIfDescr]; !ok {
+			t.Errorf("row %s misses ifDescr", row.indexKey)
+		}
+		if _, ok := row.pdus[testIfInOctets]; ok == (row.indexKey == "3") {
+			t.Errorf("unexpected ifInOctets of row %s: %v", row.indexKey, row.pdus)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.rows != 5 || stats.pdus != 9 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	return indexKeys, requests
+}
+
+func TestWalkColumnsPages(t *testing.T) {
+	agent := newFakeIfTable()
+	indexKeys, requests := walkFakeIfTable(t, agent, 0, 2)
+	//rows are handed over as soon as both columns have moved past them
+	if expected := []string{"1", "2", "3", "10", "11"}; !reflect.DeepEqual(indexKeys, expected) {
+		t.Errorf("rows %v, expected %v", indexKeys, expected)
+	}
+	if expected := []int{1, 1, 2, 2, 3}; !reflect.DeepEqual(requests, expected) {
+		t.Errorf("rows handed over after requests %v, expected %v", requests, expected)
+	}
+	if agent.requests != 3 {
+		t.Errorf("walk ended after %d requests, expected 3", agent.requests)
+	}
+}
+
+func TestWalkColumnsChunks(t *testing.T) {
+	agent := newFakeIfTable()
+	indexKeys, _ := walkFakeIfTable(t, agent, 1, 2)
+	if expected := []string{"1", "2", "3", "10", "11"}; !reflect.DeepEqual(indexKeys, expected) {
+		t.Errorf("rows %v, expected %v", indexKeys, expected)
+	}
+	//one GETBULK per column and page
+	if agent.requests != 6 {
+		t.Errorf("walk ended after %d requests, expected 6", agent.requests)
+	}
+}
+
+func TestWalkColumnsTooBig(t *testing.T) {
+	agent := newFakeIfTable()
+	agent.tooBig = 2
+	indexKeys, requests := walkFakeIfTable(t, agent, 0, 2)
+	//the rows of the first page are not handed over again by the BulkWalk of each column
+	if expected := []string{"1", "2", "3", "10", "11"}; !reflect.DeepEqual(indexKeys, expected) {
+		t.Errorf("rows %v, expected %v", indexKeys, expected)
+	}
+	if expected := []int{1, 1, 4, 4, 4}; !reflect.DeepEqual(requests, expected) {
+		t.Errorf("rows handed over after requests %v, expected %v", requests, expected)
+	}
+}
+
+func TestWalkColumnsDefaultPageSize(t *testing.T) {
+	agent := newFakeIfTable()
+	indexKeys, _ := walkFakeIfTable(t, agent, 0, 0)
+	if expected := []string{"1", "2", "3", "10", "11"}; !reflect.DeepEqual(indexKeys, expected) {
+		t.Errorf("rows %v, expected %v", indexKeys, expected)
+	}
+	//the table fits in a single page of defaultPageSize rows
+	if agent.requests != 1 || agent.maxRepetitions != defaultPageSize {
+		t.Errorf("walk ended after %d requests of %d rows, expected 1 of %d", agent.requests, agent.maxRepetitions, defaultPageSize)
+	}
+}
+
+func TestWalkColumnsNotIncreasing(t *testing.T) {
+	agent := &loopingAgent{pdu: gosnmp.SnmpPDU{Name: testIfDescr + ".1", Type: gosnmp.OctetString, Value: []byte("eth0")}}
+	err := walkColumns(agent, 0, []string{testIfDescr}, 5, &walkStats{}, func(row *tableRow) error {
+		return nil
+	})
+	if err == nil {
+		t.Error("expected error for an agent returning the same OID again")
+	}
+}
+
+// loopingAgent answers every GETBULK with the same PDU
+type loopingAgent struct {
+	pdu gosnmp.SnmpPDU
+}
+
+func (a *loopingAgent) GetBulk(oids []string, nonRepeaters uint8, maxRepetitions uint8) (*gosnmp.SnmpPacket, error) {
+	return &gosnmp.SnmpPacket{Variables: []gosnmp.SnmpPDU{a.pdu}}, nil
+}
+
+func (a *loopingAgent) BulkWalk(rootOid string, walkFn gosnmp.WalkFunc) error {
+	return walkFn(a.pdu)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// telegrafInput is an `[[inputs.snmp]]` plugin of a Telegraf configuration
+type telegrafInput struct {
+	name   string
+	agents []string
+	fields []*telegrafField
+	tables []*telegrafTable
+}
+
+// telegrafTable is an `[[inputs.snmp.table]]` of an snmp input
+type telegrafTable struct {
+	name   string
+	oid    string
+	fields []*telegrafField
+}
+
+// telegrafField is an `[[inputs.snmp.field]]` or `[[inputs.snmp.table.field]]`
+type telegrafField struct {
+	name       string
+	oid        string
+	conversion string
+	isTag      bool
+}
+
+// importTelegrafConfig converts the snmp inputs of a Telegraf configuration
+// into a collection file with a device per input, a scalar metric set for the
+// fields of the input and a table metric set for each of its tables
+func importTelegrafConfig(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	inputs, err := parseTelegrafConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no [[inputs.snmp]] found in %s", path)
+	}
+	return yaml.Marshal(convertTelegrafInputs(inputs))
+}
+
+// parseTelegrafConfig reads the snmp inputs of a Telegraf configuration. Only
+// the TOML used by these inputs is understood: array of tables headers and
+// `key = value` pairs of strings, numbers, booleans and arrays of strings.
+// The other plugins and tables are skipped
+func parseTelegrafConfig(r io.Reader) ([]*telegrafInput, error) {
+	var inputs []*telegrafInput
+	var input *telegrafInput
+	var table *telegrafTable
+	var field *telegrafField
+	section := ""
+	pending := ""
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := pending + stripTomlComment(scanner.Text())
+		pending = ""
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section = strings.TrimSpace(strings.Trim(line, "[]"))
+			switch section {
+			case "inputs.snmp":
+				input = &telegrafInput{name: "snmp"}
+				inputs = append(inputs, input)
+				table, field = nil, nil
+			case "inputs.snmp.field":
+				if input == nil {
+					return nil, fmt.Errorf("line %d: %s outside of [[inputs.snmp]]", lineNumber, line)
+				}
+				field = &telegrafField{}
+				input.fields = append(input.fields, field)
+				table = nil
+			case "inputs.snmp.table":
+				if input == nil {
+					return nil, fmt.Errorf("line %d: %s outside of [[inputs.snmp]]", lineNumber, line)
+				}
+				table = &telegrafTable{}
+				input.tables = append(input.tables, table)
+				field = nil
+			case "inputs.snmp.table.field":
+				if table == nil {
+					return nil, fmt.Errorf("line %d: %s outside of [[inputs.snmp.table]]", lineNumber, line)
+				}
+				field = &telegrafField{}
+				table.fields = append(table.fields, field)
+			}
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNumber)
+		}
+		key, raw := strings.TrimSpace(line[:eq]), strings.TrimSpace(line[eq+1:])
+		if strings.HasPrefix(raw, "[") && strings.Count(raw, "[") > strings.Count(raw, "]") {
+			//multi-line array
+			pending = line + " "
+			continue
+		}
+		value, err := parseTomlValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		switch section {
+		case "inputs.snmp":
+			switch key {
+			case "name":
+				input.name = tomlString(value)
+			case "agents":
+				input.agents, _ = value.([]string)
+			}
+		case "inputs.snmp.table":
+			switch key {
+			case "name":
+				table.name = tomlString(value)
+			case "oid":
+				table.oid = tomlString(value)
+			}
+		case "inputs.snmp.field", "inputs.snmp.table.field":
+			switch key {
+			case "name":
+				field.name = tomlString(value)
+			case "oid":
+				field.oid = tomlString(value)
+			case "conversion":
+				field.conversion = tomlString(value)
+			case "is_tag":
+				field.isTag, _ = value.(bool)
+			}
+		}
+	}
+	if pending != "" {
+		return nil, fmt.Errorf("unterminated array %s", pending)
+	}
+	return inputs, scanner.Err()
+}
+
+// stripTomlComment drops a `#` comment that is not inside a string
+func stripTomlComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseTomlValue parses a string, boolean, array of strings or, as a string, any other value
+func parseTomlValue(raw string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		return strconv.Unquote(raw)
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return nil, fmt.Errorf("unterminated string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	case raw == "true" || raw == "false":
+		return raw == "true", nil
+	case strings.HasPrefix(raw, "["):
+		var values []string
+		for _, item := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(raw, "["), "]"), ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			value, err := parseTomlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, tomlString(value))
+		}
+		return values, nil
+	}
+	return raw, nil
+}
+
+func tomlString(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+func convertTelegrafInputs(inputs []*telegrafInput) generatedCollection {
+	var collection generatedCollection
+	for i, input := range inputs {
+		device := generatedDevice{Device: telegrafDeviceName(input, i)}
+		scalars := generatedMetricSet{Name: input.name, Type: "scalar", EventType: sampleEventType(input.name)}
+		for _, field := range input.fields {
+			scalars.Metrics = append(scalars.Metrics, telegrafFieldMetric(field))
+		}
+		if len(scalars.Metrics) > 0 {
+			device.MetricSets = append(device.MetricSets, scalars)
+		}
+		for _, table := range input.tables {
+			device.MetricSets = append(device.MetricSets, telegrafTableMetricSet(table))
+		}
+		collection.Collect = append(collection.Collect, device)
+	}
+	return collection
+}
+
+// telegrafDeviceName names a device after the host of the first agent of its input
+func telegrafDeviceName(input *telegrafInput, i int) string {
+	for _, agent := range input.agents {
+		if !strings.Contains(agent, "://") {
+			agent = "udp://" + agent
+		}
+		if u, err := url.Parse(agent); err == nil && u.Hostname() != "" {
+			return u.Hostname()
+		}
+	}
+	return fmt.Sprintf("%s%d", input.name, i+1)
+}
+
+// telegrafTableMetricSet converts a table. Telegraf collects every column of a
+// table given by its MIB name, which the table metric set option does as well
+func telegrafTableMetricSet(table *telegrafTable) generatedMetricSet {
+	name := table.name
+	if name == "" {
+		name = telegrafObjectName(table.oid)
+	}
+	metricSet := generatedMetricSet{Name: name, Type: "table", EventType: sampleEventType(name)}
+	switch {
+	case table.oid == "":
+	case strings.Trim(table.oid, ".0123456789") == "":
+		metricSet.RootOid = normalizeOid(table.oid)
+	default:
+		metricSet.Table = table.oid
+	}
+	for _, field := range table.fields {
+		metricSet.Metrics = append(metricSet.Metrics, telegrafFieldMetric(field))
+	}
+	if metricSet.RootOid == "" && metricSet.Table == "" && len(metricSet.Metrics) > 0 {
+		metricSet.RootOid = metricSet.Metrics[0].Oid
+	}
+	return metricSet
+}
+
+// telegrafFieldMetric converts a field: tags are attributes, conversions map
+// to formats and scales, and the other fields are typed from their PDU
+func telegrafFieldMetric(field *telegrafField) generatedMetric {
+	generated := generatedMetric{MetricName: field.name, Oid: field.oid, MetricType: "auto"}
+	if generated.MetricName == "" {
+		generated.MetricName = telegrafObjectName(field.oid)
+	}
+	if field.oid != "" && strings.Trim(field.oid, ".0123456789") == "" {
+		generated.Oid = normalizeOid(field.oid)
+	}
+	if field.isTag {
+		generated.MetricType = "attribute"
+	}
+	conversion := field.conversion
+	switch {
+	case conversion == "hwaddr":
+		generated.MetricType, generated.Format = "", "mac"
+	case conversion == "ipaddr" || conversion == "string":
+		generated.MetricType = "attribute"
+	case conversion == "float" || conversion == "int":
+		if !field.isTag {
+			generated.MetricType = "gauge"
+		}
+	case strings.HasPrefix(conversion, "float(") && strings.HasSuffix(conversion, ")"):
+		if decimals, err := strconv.Atoi(conversion[len("float(") : len(conversion)-1]); err == nil {
+			scale := math.Pow10(-decimals)
+			generated.Scale = &scale
+		}
+		if !field.isTag {
+			generated.MetricType = "gauge"
+		}
+	}
+	return generated
+}
+
+// telegrafObjectName is the name Telegraf gives a field or table without one:
+// the object name of a MIB OID, or the OID itself
+func telegrafObjectName(oid string) string {
+	name := oid
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		name = name[i+2:]
+	} else if strings.Trim(oid, ".0123456789") == "" {
+		name = mibs.objectName(oid)
+	}
+	return strings.TrimSuffix(name, ".0")
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/integration"
+	"github.com/newrelic/infra-integrations-sdk/log"
+	"github.com/soniah/gosnmp"
+)
+
+// typeValidationEventType is the event type of the metric type mismatches
+// found in strict_types mode
+const typeValidationEventType = "SNMPValidationSample"
+
+// typeMismatch is a metric whose configured metric_type cannot be applied
+// to the type of the PDU returned by the target
+type typeMismatch struct {
+	metricName string
+	oid        string
+	metricType metricSourceType
+	pduType    gosnmp.Asn1BER
+}
+
+var (
+	typeMismatches []typeMismatch
+	typeCheckLock  sync.Mutex
+)
+
+// pduTypeNames maps the PDU types to their names in reported mismatches
+var pduTypeNames = map[gosnmp.Asn1BER]string{
+	gosnmp.Boolean:          "Boolean",
+	gosnmp.Integer:          "Integer",
+	gosnmp.BitString:        "BitString",
+	gosnmp.OctetString:      "OctetString",
+	gosnmp.Null:             "Null",
+	gosnmp.ObjectIdentifier: "ObjectIdentifier",
+	gosnmp.IPAddress:        "IPAddress",
+	gosnmp.Counter32:        "Counter32",
+	gosnmp.Gauge32:          "Gauge32",
+	gosnmp.TimeTicks:        "TimeTicks",
+	gosnmp.Opaque:           "Opaque",
+	gosnmp.NsapAddress:      "NsapAddress",
+	gosnmp.Counter64:        "Counter64",
+	gosnmp.Uinteger32:       "Uinteger32",
+	gosnmp.OpaqueFloat:      "OpaqueFloat",
+	gosnmp.OpaqueDouble:     "OpaqueDouble",
+}
+
+func pduTypeName(t gosnmp.Asn1BER) string {
+	if name, ok := pduTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", byte(t))
+}
+
+// pduTypeByName returns the PDU type of a name, ignoring case
+func pduTypeByName(name string) (gosnmp.Asn1BER, bool) {
+	for t, typeName := range pduTypeNames {
+		if strings.EqualFold(typeName, strings.TrimSpace(name)) {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// metricTypeNames maps the metric types to their names in yaml, the reverse of metricTypes
+var metricTypeNames = map[metricSourceType]string{
+	auto:      "auto",
+	gauge:     "gauge",
+	delta:     "delta",
+	attribute: "attribute",
+	rate:      "rate",
+	pdelta:    "pdelta",
+	prate:     "prate",
+}
+
+func metricTypeName(t metricSourceType) string {
+	if name, ok := metricTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// checkMetricType reports whether the configured type of a metric can be
+// applied to a PDU. Numeric metric types only apply to numeric PDUs unless a
+// format converts the value. Mismatches are recorded to be reported later
+func checkMetricType(def *metricDef, pdu gosnmp.SnmpPDU) bool {
+	switch def.metricType {
+	case gauge, delta, rate, pdelta, prate:
+	default:
+		return true
+	}
+	if def.format != "" || isNullPDU(pdu) {
+		return true
+	}
+	switch pdu.Type {
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.Counter64, gosnmp.Uinteger32,
+		gosnmp.TimeTicks, gosnmp.OpaqueFloat, gosnmp.OpaqueDouble:
+		return true
+	}
+	typeCheckLock.Lock()
+	typeMismatches = append(typeMismatches, typeMismatch{
+		metricName: def.metricName,
+		oid:        pdu.Name,
+		metricType: def.metricType,
+		pduType:    pdu.Type,
+	})
+	typeCheckLock.Unlock()
+	return false
+}
+
+// reportTypeMismatches reports the type mismatches recorded while
+// collecting a device as validation events and clears them
+func reportTypeMismatches(device string, entity *integration.Entity) {
+	typeCheckLock.Lock()
+	mismatches := typeMismatches
+	typeMismatches = nil
+	typeCheckLock.Unlock()
+	for _, m := range mismatches {
+		ms := entity.NewMetricSet(typeValidationEventType, metric.Attr("IntegrationVersion", integrationVersion))
+		attributes := map[string]string{
+			"device":     device,
+			"metricName": m.metricName,
+			"oid":        m.oid,
+			"metricType": metricTypeName(m.metricType),
+			"pduType":    pduTypeName(m.pduType),
+		}
+		for name, value := range attributes {
+			if err := ms.SetMetric(name, value, metric.ATTRIBUTE); err != nil {
+				log.Error(err.Error())
+			}
+		}
+	}
+}
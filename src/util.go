@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -9,6 +10,54 @@ import (
 	"github.com/soniah/gosnmp"
 )
 
+// v2cMaxOids is the number of OIDs a single GET of an SNMPv2c connection accepts
+const v2cMaxOids = 8900
+
+// connectionTimeout is the timeout of the requests sent to the target, from
+// the timeout argument
+var connectionTimeout = 10 * time.Second
+
+// profileRepositoryRefresh is how long the profiles of an unpinned
+// profile_repository are cached, from the profile_repository_refresh argument
+var profileRepositoryRefresh = time.Hour
+
+// parseDurationArguments reads the durations of the arguments, such as `5s`.
+// profile_repository_refresh also accepts the bare number of seconds it used
+// to be configured with
+func parseDurationArguments() error {
+	timeout, err := parseDuration(args.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %v", err)
+	}
+	if timeout > 0 {
+		connectionTimeout = timeout
+	}
+	refresh := strings.TrimSpace(args.ProfileRepositoryRefresh)
+	if seconds, err := strconv.Atoi(refresh); err == nil && seconds > 0 {
+		refresh += "s"
+	}
+	refreshDuration, err := parseDuration(refresh)
+	if err != nil {
+		return fmt.Errorf("invalid profile_repository_refresh: %v", err)
+	}
+	if refreshDuration > 0 {
+		profileRepositoryRefresh = refreshDuration
+	}
+	return nil
+}
+
+// setTimeout sets the timeout of the requests of a connection, unless it is
+// 0, and returns the function restoring the previous timeout
+func setTimeout(client *gosnmp.GoSNMP, timeout time.Duration) func() {
+	previous := client.Timeout
+	if timeout > 0 {
+		client.Timeout = timeout
+	}
+	return func() {
+		client.Timeout = previous
+	}
+}
+
 func connect(targetHost string, targetPort int) error {
 	if args.V3 {
 		// Ensure a collection file is specified
@@ -24,7 +73,7 @@ func connect(targetHost string, targetPort int) error {
 				Target:             targetHost,
 				Port:               uint16(targetPort),
 				Version:            gosnmp.Version3,
-				Timeout:            time.Duration(10) * time.Second,
+				Timeout:            connectionTimeout,
 				SecurityModel:      gosnmp.UserSecurityModel,
 				MsgFlags:           msgFlags,
 				SecurityParameters: &gosnmp.UsmSecurityParameters{UserName: args.Username},
@@ -47,7 +96,7 @@ func connect(targetHost string, targetPort int) error {
 				Target:        targetHost,
 				Port:          uint16(targetPort),
 				Version:       gosnmp.Version3,
-				Timeout:       time.Duration(10) * time.Second,
+				Timeout:       connectionTimeout,
 				SecurityModel: gosnmp.UserSecurityModel,
 				MsgFlags:      msgFlags,
 				SecurityParameters: &gosnmp.UsmSecurityParameters{UserName: args.Username,
@@ -82,7 +131,7 @@ func connect(targetHost string, targetPort int) error {
 				Target:        targetHost,
 				Port:          uint16(targetPort),
 				Version:       gosnmp.Version3,
-				Timeout:       time.Duration(10) * time.Second,
+				Timeout:       connectionTimeout,
 				SecurityModel: gosnmp.UserSecurityModel,
 				MsgFlags:      msgFlags,
 				SecurityParameters: &gosnmp.UsmSecurityParameters{UserName: args.Username,
@@ -103,11 +152,14 @@ func connect(targetHost string, targetPort int) error {
 			Port:      uint16(targetPort),
 			Version:   gosnmp.Version2c,
 			Community: community,
-			Timeout:   time.Duration(10 * time.Second), // Timeout better suited to walking
-			MaxOids:   8900,
+			Timeout:   connectionTimeout,
+			MaxOids:   v2cMaxOids,
 		}
 	}
 
+	if args.WalkTelemetry {
+		theSNMP.Logger = &requestCounter{}
+	}
 	err := theSNMP.Connect()
 	if err != nil {
 		log.Error(err.Error())
@@ -117,6 +169,33 @@ func connect(targetHost string, targetPort int) error {
 	return nil
 }
 
+// cloneSNMP returns an unconnected copy of the connection settings of x
+func cloneSNMP(x *gosnmp.GoSNMP) *gosnmp.GoSNMP {
+	clone := &gosnmp.GoSNMP{
+		Target:          x.Target,
+		Port:            x.Port,
+		Community:       x.Community,
+		Version:         x.Version,
+		Timeout:         x.Timeout,
+		Retries:         x.Retries,
+		MaxOids:         x.MaxOids,
+		MaxRepetitions:  x.MaxRepetitions,
+		NonRepeaters:    x.NonRepeaters,
+		MsgFlags:        x.MsgFlags,
+		SecurityModel:   x.SecurityModel,
+		ContextEngineID: x.ContextEngineID,
+		ContextName:     x.ContextName,
+	}
+	if x.SecurityParameters != nil {
+		clone.SecurityParameters = x.SecurityParameters.Copy()
+	}
+	//each connection counts its own requests
+	if _, ok := x.Logger.(*requestCounter); ok {
+		clone.Logger = &requestCounter{}
+	}
+	return clone
+}
+
 func disconnect() {
 	err := theSNMP.Conn.Close()
 	if err != nil {
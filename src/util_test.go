@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDurationArguments(t *testing.T) {
+	defer func() {
+		args.Timeout, args.ProfileRepositoryRefresh = "", ""
+		connectionTimeout, profileRepositoryRefresh = 10*time.Second, time.Hour
+	}()
+	for _, test := range []struct {
+		timeout, refresh string
+		valid            bool
+		expectedTimeout  time.Duration
+		expectedRefresh  time.Duration
+	}{
+		{"5s", "30m", true, 5 * time.Second, 30 * time.Minute},
+		{"1m30s", "3600", true, 90 * time.Second, time.Hour},
+		{"5", "1h", false, 0, 0},
+		{"-5s", "1h", false, 0, 0},
+		{"5s", "soon", false, 0, 0},
+	} {
+		args.Timeout, args.ProfileRepositoryRefresh = test.timeout, test.refresh
+		err := parseDurationArguments()
+		switch {
+		case !test.valid && err == nil:
+			t.Errorf("expected error for timeout %s and profile_repository_refresh %s", test.timeout, test.refresh)
+		case test.valid && err != nil:
+			t.Error(err)
+		case test.valid && (connectionTimeout != test.expectedTimeout || profileRepositoryRefresh != test.expectedRefresh):
+			t.Errorf("unexpected durations %s and %s", connectionTimeout, profileRepositoryRefresh)
+		}
+	}
+}
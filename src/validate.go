@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// diagnostic is a problem found in a collection file or in the arguments.
+// line is 0 when the problem can not be located in the file
+type diagnostic struct {
+	file    string
+	line    int
+	column  int
+	warning bool
+	message string
+}
+
+func (d diagnostic) String() string {
+	location := d.file
+	if location == "" {
+		location = "arguments"
+	}
+	if d.line > 0 {
+		location += ":" + strconv.Itoa(d.line)
+		if d.column > 0 {
+			location += ":" + strconv.Itoa(d.column)
+		}
+	}
+	if d.warning {
+		return location + ": warning: " + d.message
+	}
+	return location + ": error: " + d.message
+}
+
+// yamlErrorLine matches the line number of a YAML syntax error
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// validateConfiguration checks the arguments and every collection file and
+// profile they configure, without connecting to the target
+func validateConfiguration() []diagnostic {
+	var diagnostics []diagnostic
+	argumentError := func(format string, v ...interface{}) {
+		diagnostics = append(diagnostics, diagnostic{message: fmt.Sprintf(format, v...)})
+	}
+	if _, ok := negativeDeltaPolicies[args.NegativeDeltas]; !ok {
+		argumentError("invalid negative_deltas %s, valid values are drop, zero and attribute", args.NegativeDeltas)
+	}
+	if args.FloatPrecision < -1 {
+		argumentError("invalid float_precision %d", args.FloatPrecision)
+	}
+	if err := parseDurationArguments(); err != nil {
+		argumentError("%v", err)
+	}
+	if _, err := parseMetricFilter(args.MetricAllowlist, args.MetricBlocklist); err != nil {
+		argumentError("%v", err)
+	}
+	if !validNamespace.MatchString(args.EventTypeNamespace) {
+		argumentError("invalid event_type_namespace %s, only letters, digits, underscores and colons are allowed", args.EventTypeNamespace)
+	}
+	if !validNamespace.MatchString(args.MetricPrefix) {
+		argumentError("invalid metric_prefix %s, only letters, digits, underscores and colons are allowed", args.MetricPrefix)
+	}
+	if args.TableWorkers < 1 {
+		argumentError("invalid table_workers %d", args.TableWorkers)
+	}
+	if err := loadMibDirs(); err != nil {
+		argumentError("invalid mib_dirs: %v", err)
+	}
+
+	var files []string
+	collectionFiles, err := collectionFilePaths(args.CollectionFiles)
+	if err != nil {
+		argumentError("%v", err)
+	}
+	for _, file := range collectionFiles {
+		if !filepath.IsAbs(file) {
+			argumentError("invalid metrics collection path %s, collection files must be specified as absolute paths", file)
+			continue
+		}
+		files = append(files, file)
+	}
+	for _, name := range strings.Split(enabledProfiles(args.Profiles), ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+		case autoProfile:
+			diagnostics = append(diagnostics, diagnostic{warning: true, message: "the profile selected by profiles: auto depends on the target and is not validated"})
+		default:
+			file, err := profilePath(name)
+			if err != nil {
+				argumentError("%v", err)
+				continue
+			}
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 && !args.HardwareInventory {
+		argumentError("must specify at least one collection file, profile, enabled MIB or hardware_inventory")
+	}
+	for _, file := range files {
+		diagnostics = append(diagnostics, validateCollectionFile(file)...)
+	}
+	return diagnostics
+}
+
+// validateCollectionFile checks the syntax of a collection file, the OIDs,
+// types and options of its metrics, and the names of its metric sets and
+// metrics, locating problems on the line of the metric set or metric
+func validateCollectionFile(file string) []diagnostic {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return []diagnostic{{file: file, message: err.Error()}}
+	}
+	lines := strings.Split(string(src), "\n")
+	parser, err := loadCollectionFile(file)
+	if errors, ok := err.(schemaErrors); ok {
+		var diagnostics []diagnostic
+		for _, e := range errors {
+			diagnostics = append(diagnostics, diagnostic{file: e.file, line: e.line, column: e.column, message: e.message})
+		}
+		return diagnostics
+	}
+	if err != nil {
+		d := diagnostic{file: file, message: err.Error()}
+		if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			d.line, _ = strconv.Atoi(match[1])
+		}
+		return []diagnostic{d}
+	}
+
+	var diagnostics []diagnostic
+	report := func(line int, warning bool, format string, v ...interface{}) {
+		diagnostics = append(diagnostics, diagnostic{file: file, line: line, warning: warning, message: fmt.Sprintf(format, v...)})
+	}
+	setLines := make(map[string]int)
+	setLine := 0
+	for _, device := range parser.Collect {
+		setNames := make(map[string]bool)
+		eventTypes := make(map[string]string)
+		for _, set := range device.MetricSets {
+			setLine = findLine(lines, setLine, "name:", set.Name)
+			setLines[set.Name] = setLine
+			if setNames[set.Name] {
+				report(setLine, false, "duplicate metric set %s", set.Name)
+			}
+			setNames[set.Name] = true
+			if set.Type != "scalar" && set.Type != "table" {
+				report(setLine, false, "invalid type %q of metric set %s, valid values are scalar and table", set.Type, set.Name)
+			}
+			if set.EventType == "" {
+				report(setLine, false, "metric set %s has no event_type", set.Name)
+			} else if other, ok := eventTypes[set.EventType]; ok {
+				report(setLine, true, "metric sets %s and %s both report %s events", other, set.Name, set.EventType)
+			} else {
+				eventTypes[set.EventType] = set.Name
+			}
+			metricNames := make(map[string]bool)
+			for _, metricParser := range set.Metrics {
+				metricLine := findLine(lines, setLine, "oid:", metricParser.Oid)
+				if err := checkOidSyntax(metricParser.Oid); err != nil {
+					report(metricLine, false, "%v in metric set %s", err, set.Name)
+					continue
+				}
+				if _, err := parseMetric(metricParser); err != nil {
+					report(metricLine, false, "%v in metric set %s", err, set.Name)
+					continue
+				}
+				if name := metricParser.MetricName; name != "" {
+					if metricNames[name] {
+						report(metricLine, false, "duplicate metric %s in metric set %s", name, set.Name)
+					}
+					metricNames[name] = true
+				}
+			}
+		}
+	}
+	if len(diagnostics) > 0 {
+		return diagnostics
+	}
+	//the checks across metric sets, such as augments and derived metrics, are
+	//left to the parser of the collection
+	if _, err := parseCollection(parser); err != nil {
+		line := 0
+		for name, setLine := range setLines {
+			if strings.Contains(err.Error(), "metric set "+name) {
+				line = setLine
+			}
+		}
+		report(line, false, "%v", err)
+	}
+	return diagnostics
+}
+
+// checkOidSyntax rejects numeric OIDs with empty arcs, such as `1.3..6`.
+// Symbolic OIDs are checked when they are resolved
+func checkOidSyntax(oid string) error {
+	oid = strings.TrimSpace(oid)
+	if oid == "" {
+		return fmt.Errorf("missing oid")
+	}
+	if strings.Trim(oid, ".0123456789") != "" {
+		return nil
+	}
+	for _, arc := range strings.Split(strings.TrimPrefix(oid, "."), ".") {
+		if arc == "" {
+			return fmt.Errorf("invalid OID %s", oid)
+		}
+	}
+	return nil
+}
+
+// findLine returns the number of the first line after line `after` holding
+// both a key, not as the suffix of another key, and a value, or `after` when
+// there is none. The key is found in YAML or JSON collection files
+func findLine(lines []string, after int, key string, value string) int {
+	if value == "" {
+		return after
+	}
+	jsonKey := `"` + strings.TrimSuffix(key, ":") + `":`
+	for i := after; i < len(lines); i++ {
+		hasKey := strings.Contains(lines[i], key) && !strings.Contains(lines[i], "_"+key) || strings.Contains(lines[i], jsonKey)
+		if hasKey && strings.Contains(lines[i], value) {
+			return i + 1
+		}
+	}
+	return after
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateCollectionFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "device.yml")
+	source := `collect:
+- device: router
+  metric_sets:
+  - name: system
+    type: scalar
+    event_type: SNMPSample
+    metrics:
+    - metric_name: sysName
+      oid: .1.3.6.1.2.1.1.5.0
+    - metric_name: sysName
+      oid: .1.3.6.1.2.1.1.6.0
+    - metric_name: uptime
+      oid: .1.3.6..1.2.1.1.3.0
+  - name: interfaces
+    type: table
+    event_type: SNMPSample
+    metrics:
+    - metric_name: ifInOctets
+      oid: .1.3.6.1.2.1.2.2.1.10
+      metric_type: rat
+`
+	if err := ioutil.WriteFile(file, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var reported []string
+	for _, d := range validateCollectionFile(file) {
+		reported = append(reported, d.String())
+	}
+	expected := []string{
+		file + ":11: error: duplicate metric sysName",
+		file + ":13: error: invalid OID .1.3.6..1.2.1.1.3.0",
+		file + ":14: warning: metric sets system and interfaces both report SNMPSample events",
+		file + ":19: error: Invalid metric type rat",
+	}
+	if len(reported) != len(expected) {
+		t.Fatalf("unexpected diagnostics %v", reported)
+	}
+	for i, prefix := range expected {
+		if !strings.HasPrefix(reported[i], prefix) {
+			t.Errorf("expected %q, got %q", prefix, reported[i])
+		}
+	}
+
+	if err := ioutil.WriteFile(file, []byte("collect:\n- device: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if diagnostics := validateCollectionFile(file); len(diagnostics) != 1 || diagnostics[0].line == 0 {
+		t.Errorf("expected a located syntax error, got %v", diagnostics)
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sources := map[string]string{
+		"device.yml": `collect:
+- device: router
+  metric_sets:
+  - name: interfaces
+    type: table
+    max_rows: many
+    event_type: SNMPInterfaceSample
+    metrics:
+    - metric_name: ifInOctets
+      oid: .1.3.6.1.2.1.2.2.1.10
+      metric_tipe: rate
+`,
+		"device.json": `{
+  "collect": [{
+    "device": "router",
+    "metric_sets": [{
+      "name": "system", "type": "scalar", "event_type": "SNMPSample",
+      "metrics": [{"metric_name": "sysName", "oid": ".1.3.6.1.2.1.1.5.0", "metric_tipe": "attribute"}]
+    }]
+  }]
+}`,
+	}
+	expected := map[string][]string{
+		"device.yml": {
+			":6:15: error: invalid value many, a string where an integer is expected",
+			":11:7: error: unknown key metric_tipe in metric",
+		},
+		"device.json": {
+			":6:75: error: unknown key metric_tipe in metric",
+		},
+	}
+	for name, source := range sources {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var reported []string
+		for _, d := range validateCollectionFile(file) {
+			reported = append(reported, d.String())
+		}
+		if len(reported) != len(expected[name]) {
+			t.Fatalf("unexpected diagnostics %v", reported)
+		}
+		for i, suffix := range expected[name] {
+			if reported[i] != file+suffix {
+				t.Errorf("expected %q, got %q", file+suffix, reported[i])
+			}
+		}
+		if _, err := loadCollectionFile(file); err == nil || !strings.Contains(err.Error(), "metric_tipe") {
+			t.Errorf("expected the unknown key to be rejected, got %v", err)
+		}
+	}
+}
+
+func TestValidateBundledProfiles(t *testing.T) {
+	args.ProfileDirs = "../profiles"
+	defer func() { args.ProfileDirs = "" }()
+	files, err := filepath.Glob("../profiles/*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		for _, d := range validateCollectionFile(file) {
+			if !d.warning {
+				t.Error(d)
+			}
+		}
+	}
+}
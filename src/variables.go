@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// templateVariables are the variables of the `variables` argument, substituted
+// for their `${NAME}` references in collection files and profiles
+var templateVariables map[string]string
+
+// variableReference matches a `${NAME}` reference to a template variable,
+// with an optional `${NAME:-default}` inline default, and the `$${NAME}`
+// escape of a literal reference
+var variableReference = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// variableName matches the name of a template variable
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// variableDefaults is the part of a collection file read before its
+// variables are substituted: the defaults of the variables it references
+type variableDefaults struct {
+	Variables map[string]string `yaml:"variables"`
+}
+
+// parseTemplateVariables reads the YAML mapping of the `variables` argument,
+// such as `{WAN_IF_REGEX: "^ge-", TEMP_WARN: 75}`
+func parseTemplateVariables(mapping string) (map[string]string, error) {
+	if strings.TrimSpace(mapping) == "" {
+		return nil, nil
+	}
+	var variables map[string]string
+	if err := yaml.Unmarshal([]byte(mapping), &variables); err != nil {
+		return nil, fmt.Errorf("variables must be a YAML mapping of names to values: %v", err)
+	}
+	for name := range variables {
+		if !variableName.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable name %s", name)
+		}
+	}
+	return variables, nil
+}
+
+// expandVariables substitutes the `${NAME}` references of a collection file.
+// A variable is looked up in the `variables` argument, then in the
+// environment, then in the `variables` defaults declared by the file itself.
+// A `${NAME:-default}` reference falls back to its inline default, before the
+// defaults of the file, when the variable is unset or empty, as in the shell.
+// `$${NAME}` is kept as the literal `${NAME}`. A reference to a variable
+// defined nowhere is an error
+func expandVariables(src []byte) ([]byte, error) {
+	if !variableReference.Match(src) {
+		return src, nil
+	}
+	//the defaults are read from the file before substitution, references
+	//elsewhere in the file do not prevent reading them
+	var defaults variableDefaults
+	_ = yaml.Unmarshal(src, &defaults)
+	undefined := make(map[string]bool)
+	expanded := variableReference.ReplaceAllFunc(src, func(reference []byte) []byte {
+		if reference[1] == '$' {
+			return reference[1:]
+		}
+		match := variableReference.FindSubmatch(reference)
+		name := string(match[1])
+		hasDefault := len(match[2]) > 0
+		value, ok := templateVariables[name]
+		if !ok {
+			value, ok = os.LookupEnv(name)
+		}
+		if ok && (value != "" || !hasDefault) {
+			return []byte(value)
+		}
+		if hasDefault {
+			return match[2][len(":-"):]
+		}
+		if value, ok := defaults.Variables[name]; ok {
+			return []byte(value)
+		}
+		undefined[name] = true
+		return reference
+	})
+	if len(undefined) > 0 {
+		var names []string
+		for name := range undefined {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("undefined variables %s, set them in the variables argument or the environment", strings.Join(names, ", "))
+	}
+	return expanded, nil
+}
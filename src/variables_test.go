@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExpandVariables(t *testing.T) {
+	variables, err := parseTemplateVariables(`{WAN_IF_REGEX: "^ge-", TEMP_WARN: 75}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templateVariables = variables
+	os.Setenv("NRI_SNMP_TEST_SITE", "paris")
+	defer func() {
+		templateVariables = nil
+		os.Unsetenv("NRI_SNMP_TEST_SITE")
+	}()
+
+	source := `variables:
+  TEMP_WARN: 60
+  TEMP_CRIT: 90
+collect:
+- device: ${NRI_SNMP_TEST_SITE}
+  metric_sets:
+  - name: interfaces
+    indexes: ["${WAN_IF_REGEX}"]
+    derived:
+    - {metric_name: tempWarning, expression: "temperature > ${TEMP_WARN}"}
+    - {metric_name: tempCritical, expression: "temperature > ${TEMP_CRIT}"}
+`
+	expanded, err := expandVariables([]byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"device: paris", `["^ge-"]`, "temperature > 75", "temperature > 90"} {
+		if !strings.Contains(string(expanded), expected) {
+			t.Errorf("expected %q in %s", expected, expanded)
+		}
+	}
+
+	os.Setenv("NRI_SNMP_TEST_EMPTY", "")
+	defer os.Unsetenv("NRI_SNMP_TEST_EMPTY")
+	expanded, err = expandVariables([]byte(`community: ${NRI_SNMP_TEST_UNDEFINED:-public}
+site: ${NRI_SNMP_TEST_SITE:-lyon}
+empty: "${NRI_SNMP_TEST_EMPTY:-none}"
+literal: $${NRI_SNMP_TEST_SITE}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"community: public", "site: paris", `empty: "none"`, "literal: ${NRI_SNMP_TEST_SITE}"} {
+		if !strings.Contains(string(expanded), expected) {
+			t.Errorf("expected %q in %s", expected, expanded)
+		}
+	}
+
+	if _, err := expandVariables([]byte("device: ${NRI_SNMP_TEST_UNDEFINED}")); err == nil || !strings.Contains(err.Error(), "NRI_SNMP_TEST_UNDEFINED") {
+		t.Errorf("expected error for an undefined variable, got %v", err)
+	}
+	for _, mapping := range []string{"{1-invalid: x}", "{\"NAME}x\": y}"} {
+		if _, err := parseTemplateVariables(mapping); err == nil {
+			t.Errorf("expected error for the invalid variable name of %s", mapping)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// walkVariable is one variable of an snmpwalk dump
+type walkVariable struct {
+	arcs      []string
+	valueType string
+	value     string
+	assigned  bool
+}
+
+// walkNode is a node of the prefix tree built over the OIDs of a dump.
+// Children are kept in the order they appear in the dump
+type walkNode struct {
+	arcs     []string
+	children []*walkNode
+	byArc    map[string]*walkNode
+	variable *walkVariable
+}
+
+var (
+	walkLine     = regexp.MustCompile(`^(\S+)\s+=\s+(?:([A-Za-z0-9 -]+):\s*)?(.*)$`)
+	walkEnumCode = regexp.MustCompile(`^(\S+)\((-?\d+)\)$`)
+)
+
+// generateWalkCollection renders a collection file proposing a scalar metric
+// set and a table metric set for every table detected in an snmpwalk dump,
+// either numeric (-On) or translated such as `IF-MIB::ifDescr.1 = STRING: eth0`
+func generateWalkCollection(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	variables, err := parseWalk(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(variables) == 0 {
+		return nil, fmt.Errorf("no variables found in %s", path)
+	}
+	device := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return yaml.Marshal(generatedCollection{Collect: []generatedDevice{{
+		Device:     device,
+		MetricSets: walkMetricSets(variables),
+	}}})
+}
+
+// parseWalk reads the variables of an snmpwalk dump. The continuation lines
+// of multi-line strings and the variables without a value are skipped
+func parseWalk(r io.Reader) ([]*walkVariable, error) {
+	var variables []*walkVariable
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		match := walkLine.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match == nil {
+			continue
+		}
+		variable := &walkVariable{valueType: strings.TrimSpace(match[2]), value: strings.TrimSpace(match[3])}
+		if variable.valueType == "" {
+			if !strings.HasPrefix(variable.value, "\"") {
+				//No Such Object, No Such Instance and No more variables
+				continue
+			}
+			variable.valueType = "STRING"
+		}
+		oid := match[1]
+		if strings.HasPrefix(oid, "iso.") {
+			oid = "1." + strings.TrimPrefix(oid, "iso.")
+		}
+		if mibs != nil && strings.Trim(oid, ".0123456789") != "" {
+			if resolved, err := resolveOid(oid); err == nil {
+				oid = resolved
+			}
+		}
+		variable.arcs = oidArcs(oid)
+		if len(variable.arcs) == 0 {
+			continue
+		}
+		variables = append(variables, variable)
+	}
+	return variables, scanner.Err()
+}
+
+// walkMetricSets groups the variables of a dump into metric sets. A variable
+// `X.0` alone under X is a scalar. A table is a run of sibling columns that
+// share at least half of their index suffixes. The remaining variables are
+// proposed as scalars read by their instance OID
+func walkMetricSets(variables []*walkVariable) []generatedMetricSet {
+	root := &walkNode{byArc: make(map[string]*walkNode)}
+	for _, variable := range variables {
+		root.insert(variable)
+	}
+
+	scalars := generatedMetricSet{Name: "scalars", Type: "scalar", EventType: "SNMPSample"}
+	for _, variable := range variables {
+		parent := root.find(variable.arcs[:len(variable.arcs)-1])
+		if variable.arcs[len(variable.arcs)-1] == "0" && len(parent.children) == 1 {
+			variable.assigned = true
+			scalars.Metrics = append(scalars.Metrics, walkMetric(variable.arcs, []*walkVariable{variable}))
+		}
+	}
+
+	var tables []generatedMetricSet
+	root.findTables(&tables)
+
+	for _, variable := range variables {
+		if !variable.assigned {
+			scalars.Metrics = append(scalars.Metrics, walkMetric(variable.arcs, []*walkVariable{variable}))
+		}
+	}
+	if len(scalars.Metrics) == 0 {
+		return tables
+	}
+	return append([]generatedMetricSet{scalars}, tables...)
+}
+
+func (n *walkNode) insert(variable *walkVariable) {
+	node := n
+	for i, arc := range variable.arcs {
+		child, ok := node.byArc[arc]
+		if !ok {
+			child = &walkNode{arcs: variable.arcs[:i+1], byArc: make(map[string]*walkNode)}
+			node.byArc[arc] = child
+			node.children = append(node.children, child)
+		}
+		node = child
+	}
+	node.variable = variable
+}
+
+func (n *walkNode) find(arcs []string) *walkNode {
+	node := n
+	for _, arc := range arcs {
+		node = node.byArc[arc]
+	}
+	return node
+}
+
+// suffixes returns the OID suffixes of the variables under the node that are
+// not assigned to a metric set yet, with the variables they belong to
+func (n *walkNode) suffixes() map[string]*walkVariable {
+	suffixes := make(map[string]*walkVariable)
+	var collect func(node *walkNode)
+	collect = func(node *walkNode) {
+		if node.variable != nil && !node.variable.assigned && len(node.arcs) > len(n.arcs) {
+			suffixes[strings.Join(node.arcs[len(n.arcs):], ".")] = node.variable
+		}
+		for _, child := range node.children {
+			collect(child)
+		}
+	}
+	for _, child := range n.children {
+		collect(child)
+	}
+	return suffixes
+}
+
+// findTables detects the tables whose entry is the node or one of its descendants
+func (n *walkNode) findTables(tables *[]generatedMetricSet) {
+	var run []*walkNode
+	var runSuffixes []map[string]*walkVariable
+	flush := func() {
+		if len(run) > 1 {
+			*tables = append(*tables, walkTable(n, run, runSuffixes, len(*tables)+1))
+		} else if len(run) == 1 {
+			run[0].findTables(tables)
+		}
+		run, runSuffixes = nil, nil
+	}
+	for _, child := range n.children {
+		suffixes := child.suffixes()
+		if len(suffixes) == 0 {
+			//a leaf cannot be a column
+			flush()
+			continue
+		}
+		if len(run) > 0 && !sharesSuffixes(runSuffixes[len(runSuffixes)-1], suffixes) {
+			flush()
+		}
+		run = append(run, child)
+		runSuffixes = append(runSuffixes, suffixes)
+	}
+	flush()
+}
+
+// sharesSuffixes reports whether two columns share at least half of the
+// index suffixes of the one with fewer rows
+func sharesSuffixes(a, b map[string]*walkVariable) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for suffix := range a {
+		if _, ok := b[suffix]; ok {
+			shared++
+		}
+	}
+	return shared*2 >= len(a)
+}
+
+// walkTable builds the metric set of a detected table, named after its MIB
+// object when MIBs are loaded
+func walkTable(entry *walkNode, columns []*walkNode, suffixes []map[string]*walkVariable, number int) generatedMetricSet {
+	name := fmt.Sprintf("table%d", number)
+	rootOid := walkOid(columns[0].arcs)
+	if len(entry.arcs) > 1 && strings.HasPrefix(walkOid(entry.arcs), ".") {
+		rootOid = walkOid(entry.arcs[:len(entry.arcs)-1])
+		if objectName := mibs.objectName(rootOid); objectName != rootOid && !strings.Contains(objectName, ".") {
+			name = objectName
+		}
+	}
+	metricSet := generatedMetricSet{
+		Name:      name,
+		Type:      "table",
+		EventType: sampleEventType(name),
+		RootOid:   rootOid,
+	}
+	for i, column := range columns {
+		var rows []*walkVariable
+		for _, variable := range suffixes[i] {
+			variable.assigned = true
+			rows = append(rows, variable)
+		}
+		metricSet.Metrics = append(metricSet.Metrics, walkMetric(column.arcs, rows))
+	}
+	return metricSet
+}
+
+// walkMetric proposes the metric of a column, or of a scalar, from the types
+// and values of its variables: counters are reported as rates, strings and
+// enumerations as attributes and anything else as gauges
+func walkMetric(arcs []string, variables []*walkVariable) generatedMetric {
+	oid := walkOid(arcs)
+	generated := generatedMetric{Oid: oid}
+	if strings.Contains(arcs[0], "::") {
+		generated.MetricName = arcs[0][strings.Index(arcs[0], "::")+2:]
+	} else if objectName := mibs.objectName(oid); objectName != oid {
+		generated.MetricName = strings.TrimSuffix(objectName, ".0")
+	}
+	switch variables[0].valueType {
+	case "Counter32", "Counter64":
+		generated.MetricType = "rate"
+	case "STRING", "Hex-STRING", "OID", "IpAddress", "Network Address", "BITS":
+		generated.MetricType = "attribute"
+	case "INTEGER":
+		generated.MetricType = "gauge"
+		for _, variable := range variables {
+			match := walkEnumCode.FindStringSubmatch(variable.value)
+			if match == nil {
+				continue
+			}
+			code, _ := strconv.Atoi(match[2])
+			if generated.Values == nil {
+				generated.Values = make(map[int]string)
+			}
+			generated.Values[code] = match[1]
+		}
+		if len(generated.Values) > 0 {
+			generated.MetricType = "attribute"
+		}
+	default:
+		generated.MetricType = "gauge"
+	}
+	return generated
+}
+
+// walkOid joins arcs back into an OID, numeric OIDs with their leading dot
+func walkOid(arcs []string) string {
+	oid := strings.Join(arcs, ".")
+	if len(arcs) > 0 && strings.Trim(arcs[0], "0123456789") == "" {
+		return "." + oid
+	}
+	return oid
+}
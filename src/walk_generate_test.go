@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWalkMetricSets(t *testing.T) {
+	dump := `.1.3.6.1.2.1.1.1.0 = STRING: "Linux
+multi-line description"
+.1.3.6.1.2.1.1.3.0 = Timeticks: (12345) 0:02:03.45
+.1.3.6.1.2.1.2.2.1.2.1 = STRING: "lo"
+.1.3.6.1.2.1.2.2.1.2.2 = STRING: "eth0"
+.1.3.6.1.2.1.2.2.1.8.1 = INTEGER: up(1)
+.1.3.6.1.2.1.2.2.1.8.2 = INTEGER: down(2)
+.1.3.6.1.2.1.2.2.1.10.1 = Counter32: 100
+.1.3.6.1.2.1.2.2.1.10.2 = Counter32: 200
+.1.3.6.1.2.1.4.20.1.2.10.0.0.1 = INTEGER: 2
+.1.3.6.1.2.1.4.20.1.3.10.0.0.1 = IpAddress: 255.0.0.0
+.1.3.6.1.2.1.25.1.1.0 = No Such Object available on this agent at this OID`
+	variables, err := parseWalk(strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variables) != 10 {
+		t.Fatalf("expected 10 variables, got %d", len(variables))
+	}
+	metricSets := walkMetricSets(variables)
+	if len(metricSets) != 3 {
+		t.Fatalf("expected scalars and 2 tables, got %+v", metricSets)
+	}
+	if scalars := metricSets[0]; scalars.Type != "scalar" || len(scalars.Metrics) != 2 {
+		t.Errorf("unexpected scalars %+v", scalars)
+	}
+	ifTable := metricSets[1]
+	if ifTable.RootOid != ".1.3.6.1.2.1.2.2" || len(ifTable.Metrics) != 3 {
+		t.Fatalf("unexpected table %+v", ifTable)
+	}
+	if status := ifTable.Metrics[1]; status.MetricType != "attribute" || status.Values[2] != "down" {
+		t.Errorf("unexpected enumeration %+v", status)
+	}
+	if octets := ifTable.Metrics[2]; octets.MetricType != "rate" {
+		t.Errorf("unexpected counter %+v", octets)
+	}
+	if ipTable := metricSets[2]; ipTable.RootOid != ".1.3.6.1.2.1.4.20" || len(ipTable.Metrics) != 2 {
+		t.Errorf("unexpected table %+v", ipTable)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/integration"
+	"github.com/newrelic/infra-integrations-sdk/log"
+	"github.com/soniah/gosnmp"
+)
+
+// walkTelemetryEventType is the event type of the per table walk statistics
+const walkTelemetryEventType = "SNMPWalkSample"
+
+// walkStats holds the statistics of a single table walk
+type walkStats struct {
+	rootOid  string
+	requests int64
+	retries  int64
+	pdus     int
+	rows     int
+	duration time.Duration
+}
+
+// requestCounter is installed as the logger of a connection when walk_telemetry
+// is enabled, to count the requests and retries gosnmp sends, which it does
+// not expose otherwise. It recognizes them from the debug messages of gosnmp,
+// so the counts depend on their wording. Setting a logger enables every
+// debug message of gosnmp, along with the work of building their arguments,
+// which is why it is not installed otherwise
+type requestCounter struct {
+	sent    int64
+	retries int64
+}
+
+func (c *requestCounter) Print(v ...interface{}) {}
+
+func (c *requestCounter) Printf(format string, v ...interface{}) {
+	if strings.HasPrefix(format, "SENDING PACKET") {
+		atomic.AddInt64(&c.sent, 1)
+	} else if strings.HasPrefix(format, "Retry number") {
+		atomic.AddInt64(&c.retries, 1)
+	}
+}
+
+// startWalkStats begins collecting the statistics of a walk over client.
+// The returned function completes them once the walk is over
+func startWalkStats(client *gosnmp.GoSNMP, rootOid string) (*walkStats, func()) {
+	stats := &walkStats{rootOid: rootOid}
+	counter, _ := client.Logger.(*requestCounter)
+	var sent, retries int64
+	if counter != nil {
+		sent, retries = atomic.LoadInt64(&counter.sent), atomic.LoadInt64(&counter.retries)
+	}
+	start := time.Now()
+	return stats, func() {
+		stats.duration = time.Since(start)
+		if counter != nil {
+			stats.retries = atomic.LoadInt64(&counter.retries) - retries
+			stats.requests = atomic.LoadInt64(&counter.sent) - sent - stats.retries
+		}
+	}
+}
+
+// reportWalkStats logs the statistics of a table walk and, when walk_telemetry
+// is enabled, reports them as an SNMPWalkSample
+func reportWalkStats(device string, metricSets []metricSet, entity *integration.Entity, stats *walkStats) {
+	names := make([]string, 0, len(metricSets))
+	for _, metricSet := range metricSets {
+		names = append(names, metricSet.Name)
+	}
+	if !args.WalkTelemetry {
+		log.Debug("walked table [%s] (%s): %d PDUs, %d rows in %s",
+			stats.rootOid, strings.Join(names, ","), stats.pdus, stats.rows, stats.duration)
+		return
+	}
+	log.Debug("walked table [%s] (%s): %d requests, %d retries, %d PDUs, %d rows in %s",
+		stats.rootOid, strings.Join(names, ","), stats.requests, stats.retries, stats.pdus, stats.rows, stats.duration)
+
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	ms := entity.NewMetricSet(walkTelemetryEventType, metric.Attr("IntegrationVersion", integrationVersion), metric.Attr("rootOid", stats.rootOid))
+	err := ms.SetMetric("device", device, metric.ATTRIBUTE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("metricSets", strings.Join(names, ","), metric.ATTRIBUTE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("requests", stats.requests, metric.GAUGE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("retries", stats.retries, metric.GAUGE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("pdus", stats.pdus, metric.GAUGE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("rows", stats.rows, metric.GAUGE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	err = ms.SetMetric("walkDurationMs", stats.duration.Seconds()*1000, metric.GAUGE)
+	if err != nil {
+		log.Error(err.Error())
+	}
+}
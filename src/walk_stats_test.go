@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/integration"
+	"github.com/soniah/gosnmp"
+)
+
+func TestWalkTelemetry(t *testing.T) {
+	defer func() { args = argumentList{} }()
+	for _, telemetry := range []bool{true, false} {
+		args.WalkTelemetry = telemetry
+		agent := &fakeAgent{}
+		for _, index := range []string{"1", "2", "3"} {
+			agent.pdus = append(agent.pdus, gosnmp.SnmpPDU{Name: testIfDescr + "." + index, Type: gosnmp.OctetString, Value: []byte("eth" + index)})
+		}
+		agent.pdus = append(agent.pdus, gosnmp.SnmpPDU{Name: ".1.3.6.1.2.1.31.1.1.1.1.1", Type: gosnmp.OctetString, Value: []byte("eth1")})
+		metricSets := []metricSet{{Name: "interfaces", Type: "table", EventType: "SNMPSample", RootOid: ".1.3.6.1.2.1.2.2", PageSize: 1,
+			Metrics: []*metricDef{{oid: testIfDescr, metricName: "ifDescr", metricType: attribute}}}}
+		buildColumnTrees(metricSets)
+
+		port, stop := serveFakeAgent(t, agent, 1)
+		client := &gosnmp.GoSNMP{Target: "127.0.0.1", Port: port, Community: "public", Version: gosnmp.Version2c, Timeout: 5 * time.Second}
+		if telemetry {
+			client.Logger = &requestCounter{}
+		}
+		if err := client.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		i, err := integration.New("test", "0.0.0", integration.InMemoryStore())
+		if err != nil {
+			t.Fatal(err)
+		}
+		entity := i.LocalEntity()
+		err = populateTableGroupMetrics(client, "router", metricSets, entity)
+		client.Conn.Close() // nolint: errcheck
+		stop()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var samples []map[string]interface{}
+		for _, ms := range entity.Metrics {
+			if ms.Metrics["event_type"] == walkTelemetryEventType {
+				samples = append(samples, ms.Metrics)
+			}
+		}
+		if !telemetry {
+			if len(samples) != 0 {
+				t.Errorf("walk statistics reported without walk_telemetry: %v", samples)
+			}
+			continue
+		}
+		if len(samples) != 1 {
+			t.Fatalf("expected one %s, got %v", walkTelemetryEventType, samples)
+		}
+		//a page of one row per request, and the request finding the end of the table
+		expected := map[string]interface{}{
+			"requests":   float64(agent.requests),
+			"retries":    0.0,
+			"pdus":       3.0,
+			"rows":       3.0,
+			"metricSets": "interfaces",
+			"device":     "router",
+		}
+		if agent.requests != 4 {
+			t.Errorf("the agent answered %d requests, expected 4", agent.requests)
+		}
+		for name, value := range expected {
+			if samples[0][name] != value {
+				t.Errorf("%s = %#v, expected %#v", name, samples[0][name], value)
+			}
+		}
+	}
+}